@@ -0,0 +1,92 @@
+// ABOUTME: Egress proxy for labs started with --network=allowlist.
+// ABOUTME: Forwards HTTP and CONNECT requests to ALLOWED_HOSTS and logs every blocked request as JSON.
+
+'use strict';
+
+const http = require('http');
+const net = require('net');
+
+const port = Number(process.env.PROXY_PORT || 3128);
+const allowed = (process.env.ALLOWED_HOSTS || '')
+  .split(',')
+  .map((h) => h.trim().toLowerCase())
+  .filter(Boolean);
+
+// isAllowed matches a host against the allowlist. "example.com" matches
+// only itself; "*.example.com" matches its subdomains.
+function isAllowed(host) {
+  host = host.toLowerCase().replace(/\.$/, '');
+  return allowed.some((pattern) =>
+    pattern.startsWith('*.') ? host.endsWith(pattern.slice(1)) : host === pattern
+  );
+}
+
+function logBlocked(method, target) {
+  console.log(JSON.stringify({ time: new Date().toISOString(), method, target }));
+}
+
+const server = http.createServer((req, res) => {
+  let url;
+  try {
+    url = new URL(req.url);
+  } catch (e) {
+    res.writeHead(400, { 'Content-Type': 'text/plain' });
+    res.end('claudeup-lab: only proxy requests are accepted\n');
+    return;
+  }
+
+  if (!isAllowed(url.hostname)) {
+    logBlocked(req.method, url.host);
+    res.writeHead(403, { 'Content-Type': 'text/plain' });
+    res.end(`claudeup-lab: ${url.hostname} is not in the network allowlist\n`);
+    return;
+  }
+
+  const headers = { ...req.headers };
+  delete headers['proxy-connection'];
+  delete headers['proxy-authorization'];
+
+  const upstream = http.request(
+    { host: url.hostname, port: url.port || 80, method: req.method, path: url.pathname + url.search, headers },
+    (up) => {
+      res.writeHead(up.statusCode, up.headers);
+      up.pipe(res);
+    }
+  );
+  upstream.on('error', () => {
+    if (!res.headersSent) res.writeHead(502);
+    res.end();
+  });
+  req.pipe(upstream);
+});
+
+server.on('connect', (req, socket, head) => {
+  let url;
+  try {
+    url = new URL(`http://${req.url}`);
+  } catch (e) {
+    socket.end('HTTP/1.1 400 Bad Request\r\n\r\n');
+    return;
+  }
+
+  if (!isAllowed(url.hostname)) {
+    logBlocked('CONNECT', req.url);
+    socket.end('HTTP/1.1 403 Forbidden\r\n\r\n');
+    return;
+  }
+
+  const upstream = net.connect(Number(url.port || 443), url.hostname, () => {
+    socket.write('HTTP/1.1 200 Connection Established\r\n\r\n');
+    upstream.write(head);
+    upstream.pipe(socket);
+    socket.pipe(upstream);
+  });
+  upstream.on('error', () => socket.end('HTTP/1.1 502 Bad Gateway\r\n\r\n'));
+  socket.on('error', () => upstream.destroy());
+});
+
+server.listen(port, () => {
+  process.stderr.write(`egress proxy listening on ${port}, allowing: ${allowed.join(', ')}\n`);
+});
+
+process.on('SIGTERM', () => process.exit(0));
@@ -19,3 +19,6 @@ var InitConfigRepo []byte
 
 //go:embed init-claudeup.sh
 var InitClaudeup []byte
+
+//go:embed egress-proxy.js
+var EgressProxy string
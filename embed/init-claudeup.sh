@@ -1,6 +1,6 @@
 #!/usr/bin/env bash
 # ABOUTME: Installs claudeup and applies the specified profile.
-# ABOUTME: Reads CLAUDE_PROFILE and CLAUDE_BASE_PROFILE env vars.
+# ABOUTME: Reads CLAUDE_PROFILE, CLAUDE_BASE_PROFILE, CLAUDEUP_LAB_OFFLINE, and CLAUDEUP_VERSION env vars.
 
 set -euo pipefail
 
@@ -22,13 +22,40 @@ fi
 
 mkdir -p "$CLAUDEUP_HOME/profiles"
 
-if ! command -v claudeup &> /dev/null; then
-    echo "Installing claudeup..."
-    curl -fsSL https://raw.githubusercontent.com/claudeup/claudeup/main/install.sh | bash
-    export PATH="$HOME/.local/bin:$PATH"
-    echo "[OK] claudeup installed"
-else
+# CLAUDEUP_VERSION pins a release; otherwise, or for a channel such as
+# stable, any installed claudeup will do
+WANTED_VERSION="${CLAUDEUP_VERSION#v}"
+case "$WANTED_VERSION" in
+    [0-9]*) ;;
+    *) WANTED_VERSION="" ;;
+esac
+
+# installed_version prints the release of the installed claudeup, if any
+installed_version() {
+    claudeup --version 2>/dev/null | grep -oE '[0-9]+(\.[0-9A-Za-z+-]+)+' | head -1 || true
+}
+
+if command -v claudeup &> /dev/null && { [ -z "$WANTED_VERSION" ] || [ "$(installed_version)" = "$WANTED_VERSION" ]; }; then
     echo "[SKIP] claudeup already installed"
+else
+    if [ "${CLAUDEUP_LAB_OFFLINE:-}" = "1" ]; then
+        echo "[FAIL] claudeup ${WANTED_VERSION:-} is not in the image and cannot be downloaded offline"
+        echo "       Rebuild the image with: claudeup-lab image build"
+        exit 1
+    fi
+    echo "Installing claudeup ${CLAUDEUP_VERSION:-latest}..."
+    curl -fsSL https://raw.githubusercontent.com/claudeup/claudeup/main/install.sh | VERSION="${CLAUDEUP_VERSION#v}" bash
+    export PATH="$HOME/.local/bin:$PATH"
+    if ! command -v claudeup &> /dev/null; then
+        echo "[FAIL] claudeup is not on PATH after installing"
+        exit 1
+    fi
+    got="$(installed_version)"
+    if [ -n "$WANTED_VERSION" ] && [ "$got" != "$WANTED_VERSION" ]; then
+        echo "[FAIL] claudeup ${got:-unknown} installed, wanted $WANTED_VERSION"
+        exit 1
+    fi
+    echo "[OK] claudeup ${got:-} installed"
 fi
 
 # Apply base profile at user scope (foundation layer)
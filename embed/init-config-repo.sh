@@ -17,6 +17,11 @@ if [ -z "${CLAUDE_CONFIG_REPO:-}" ]; then
     exit 0
 fi
 
+if [ "${CLAUDEUP_LAB_OFFLINE:-}" = "1" ]; then
+    echo "[FAIL] Cloning CLAUDE_CONFIG_REPO needs the network, and this lab was started offline"
+    exit 1
+fi
+
 BRANCH="${CLAUDE_CONFIG_BRANCH:-main}"
 TEMP_DIR=$(mktemp -d)
 trap 'rm -rf "$TEMP_DIR"' EXIT
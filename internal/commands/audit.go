@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/spf13/cobra"
+)
+
+func newAuditCmd() *cobra.Command {
+	var labName string
+
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Show host files a lab may have changed",
+		Long: `Compare the host paths a lab's default mounts expose -- ~/.claude.json,
+~/.claude/settings.json, ~/.claudeup/profiles, ~/.claude-mem and the
+rest -- with the fingerprint taken when the lab started, and list every
+file created, modified or deleted since. Changes made on the host itself
+are listed too; those under mounts the lab could only read cannot have
+come from it. Exits non-zero when anything changed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr := newManager()
+			resolver := lab.NewResolver(mgr.Store())
+
+			meta, err := resolveLab(resolver, labName)
+			if err != nil {
+				return err
+			}
+
+			report, err := mgr.Audit(meta)
+			if errors.Is(err, lab.ErrNoFingerprint) {
+				return fmt.Errorf("%w (labs started before host fingerprints were taken get one on resume)", err)
+			}
+			if err != nil {
+				return err
+			}
+
+			if format.Structured() {
+				if err := printResult(report); err != nil {
+					return err
+				}
+			} else {
+				fmt.Printf("Checked %d host file(s) exposed to lab %s since %s\n",
+					report.Files, report.DisplayName, report.Since.Local().Format(time.RFC1123))
+				for _, r := range report.Roots {
+					access := "read-only"
+					if r.Writable {
+						access = "writable"
+					}
+					fmt.Printf("  %-11s %s (%s)\n", r.Mount, r.Path, access)
+				}
+				fmt.Println()
+				if len(report.Changes) == 0 {
+					fmt.Println("No host files changed.")
+				}
+				printHostChanges(os.Stdout, report.Changes)
+			}
+			if len(report.Changes) > 0 {
+				return fmt.Errorf("%d host file(s) changed", len(report.Changes))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&labName, "lab", "", "Lab to audit (name, UUID, project, or profile)")
+
+	return cmd
+}
+
+// printHostChanges lists changed host files to w, one per line.
+func printHostChanges(w io.Writer, changes []lab.HostChange) {
+	for _, c := range changes {
+		line := fmt.Sprintf("  %-8s %s", c.Kind, c.Path)
+		if !c.Writable {
+			line += " (read-only in the lab)"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// warnHostChanges audits a lab on its way down, on stderr. Labs without a
+// fingerprint are skipped silently.
+func warnHostChanges(mgr *lab.Manager, meta *lab.Metadata) {
+	report, err := mgr.Audit(meta)
+	if errors.Is(err, lab.ErrNoFingerprint) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: host audit: %v\n", err)
+		return
+	}
+	if len(report.Changes) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %d host file(s) changed since lab %s started:\n", len(report.Changes), meta.DisplayName)
+	printHostChanges(os.Stderr, report.Changes)
+}
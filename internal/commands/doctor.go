@@ -3,84 +3,168 @@ package commands
 import (
 	"fmt"
 	"os"
-	"os/exec"
+	"path/filepath"
 
-	"github.com/claudeup/claudeup-lab/internal/docker"
-	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/claudeup/claudeup-lab/internal/devcontainer"
 	"github.com/spf13/cobra"
 )
 
+// Doctor check statuses.
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// doctorCheck is one doctor finding. Name identifies the check and stays
+// stable across releases; Message is for humans.
+type doctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// doctorReport is the structured output of doctor.
+type doctorReport struct {
+	Checks []doctorCheck `json:"checks"`
+	Issues int           `json:"issues"`
+}
+
+func (r *doctorReport) add(name, status, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, doctorCheck{Name: name, Status: status, Message: fmt.Sprintf(format, args...)})
+	if status == checkFail {
+		r.Issues++
+	}
+}
+
 func newDoctorCmd() *cobra.Command {
-	return &cobra.Command{
+	var fix bool
+
+	cmd := &cobra.Command{
 		Use:   "doctor",
 		Short: "Check system health and prerequisites",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			issues := 0
+			report := &doctorReport{Checks: []doctorCheck{}}
+			mgr := newManager()
+
+			// Config
+			if err := mgr.ConfigError(); err != nil {
+				report.add("config", checkFail, "%v", err)
+			}
 
-			// Docker
-			client := docker.NewClient()
-			if client.IsRunning() {
-				fmt.Println("[OK] Docker is running")
+			// Container runtime
+			runtime := mgr.Runtime()
+			report.add("runtime", checkOK, "Container runtime: %s", runtime)
+			if mgr.Docker().IsRunning() {
+				report.add("runtime-running", checkOK, "%s is running", runtime)
 			} else {
-				fmt.Println("[FAIL] Docker is not running")
-				issues++
+				report.add("runtime-running", checkFail, "%s is not running", runtime)
 			}
 
-			// devcontainer CLI
-			if path, err := exec.LookPath("devcontainer"); err == nil {
-				fmt.Printf("[OK] devcontainer CLI found: %s\n", path)
+			// Devcontainer engine
+			engine := mgr.Engine().Name()
+			report.add("engine", checkOK, "Devcontainer engine: %s", engine)
+			if path, err := mgr.Runner().LookPath("devcontainer"); err == nil {
+				report.add("devcontainer-cli", checkOK, "devcontainer CLI found: %s", path)
+			} else if engine == devcontainer.EngineCLI {
+				report.add("devcontainer-cli", checkFail, "devcontainer CLI not found (install: npm install -g @devcontainers/cli)")
 			} else {
-				fmt.Println("[FAIL] devcontainer CLI not found (install: npm install -g @devcontainers/cli)")
-				issues++
+				report.add("devcontainer-cli", checkOK, "devcontainer CLI not needed by the built-in engine")
 			}
 
 			// Git
-			if _, err := exec.LookPath("git"); err == nil {
-				fmt.Println("[OK] git found")
+			if _, err := mgr.Runner().LookPath("git"); err == nil {
+				report.add("git", checkOK, "git found")
 			} else {
-				fmt.Println("[FAIL] git not found")
-				issues++
+				report.add("git", checkFail, "git not found")
 			}
 
 			// claudeup (optional)
-			if _, err := exec.LookPath("claudeup"); err == nil {
-				fmt.Println("[OK] claudeup found")
+			if _, err := mgr.Runner().LookPath("claudeup"); err == nil {
+				report.add("claudeup", checkOK, "claudeup found")
 			} else {
-				fmt.Println("[WARN] claudeup not found (needed for profile snapshotting)")
+				report.add("claudeup", checkWarn, "claudeup not found (needed for profile snapshotting)")
 			}
 
 			// Base image
-			im := docker.NewImageManager()
-			image := docker.ImageTag()
-			if im.ExistsLocally(image) {
-				fmt.Printf("[OK] Base image available: %s\n", image)
+			image := mgr.DefaultImage()
+			if mgr.Images().ExistsLocally(image) {
+				stale, _ := mgr.Images().Stale(image)
+				switch {
+				case !stale:
+					report.add("base-image", checkOK, "Base image available: %s", image)
+				case !fix:
+					report.add("base-image", checkWarn, "Base image %s was built by another version of claudeup-lab (rebuild with: claudeup-lab doctor --fix)", image)
+				default:
+					if err := mgr.Images().Build(image, false); err != nil {
+						report.add("base-image", checkFail, "Could not rebuild stale base image %s: %v", image, err)
+					} else {
+						report.add("base-image", checkOK, "Rebuilt stale base image: %s", image)
+					}
+				}
 			} else {
-				fmt.Printf("[WARN] Base image not found locally: %s (will be pulled on first start)\n", image)
+				report.add("base-image", checkWarn, "Base image not found locally: %s (will be pulled on first start)", image)
 			}
 
 			// Orphaned labs
-			mgr := lab.NewManager(defaultBaseDir())
-			labs, _ := mgr.Store().List()
+			labs, problems, _ := mgr.Store().Scan()
+			for _, r := range problems {
+				report.add("lab-metadata", checkFail, "Unreadable lab metadata: %s (%v)", filepath.Base(r.File), r.Err)
+			}
 			orphaned := 0
 			for _, m := range labs {
 				if _, err := os.Stat(m.Worktree); os.IsNotExist(err) {
 					orphaned++
-					fmt.Printf("[WARN] Orphaned lab: %s (%s) -- worktree missing\n", m.DisplayName, m.ID[:8])
+					report.add("orphaned-lab", checkWarn, "Orphaned lab: %s (%s) -- worktree missing", m.DisplayName, m.ID[:8])
+				}
+			}
+			for _, m := range labs {
+				if mgr.RunsStaleImage(m) {
+					report.add("outdated-image", checkWarn, "Lab %s (%s) runs an image built by another version of claudeup-lab (recreate it to pick up the new one)", m.DisplayName, m.ID[:8])
 				}
 			}
 			if orphaned == 0 && len(labs) > 0 {
-				fmt.Printf("[OK] %d lab(s) found, no orphans\n", len(labs))
+				report.add("labs", checkOK, "%d lab(s) found, no orphans", len(labs))
 			} else if len(labs) == 0 {
-				fmt.Println("[OK] No labs found")
+				report.add("labs", checkOK, "No labs found")
+			}
+
+			// Interrupted lab creations
+			if journals, err := mgr.PendingJournals(); err != nil {
+				report.add("journals", checkWarn, "Could not read creation journals: %v", err)
+			} else if len(journals) > 0 {
+				report.add("journals", checkWarn, "%d interrupted lab creation(s) will be rolled back on next start", len(journals))
 			}
 
-			fmt.Println()
-			if issues > 0 {
-				fmt.Printf("%d issue(s) found.\n", issues)
-				return fmt.Errorf("doctor found %d issue(s)", issues)
+			if format.Structured() {
+				if err := printResult(report); err != nil {
+					return err
+				}
+			} else {
+				printDoctorReport(report)
+			}
+			if report.Issues > 0 {
+				return fmt.Errorf("doctor found %d issue(s)", report.Issues)
 			}
-			fmt.Println("All checks passed.")
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&fix, "fix", false, "Rebuild a base image built by another version of claudeup-lab")
+
+	return cmd
+}
+
+func printDoctorReport(report *doctorReport) {
+	labels := map[string]string{checkOK: "[OK]", checkWarn: "[WARN]", checkFail: "[FAIL]"}
+	for _, c := range report.Checks {
+		fmt.Printf("%s %s\n", labels[c.Status], c.Message)
+	}
+
+	fmt.Println()
+	if report.Issues > 0 {
+		fmt.Printf("%d issue(s) found.\n", report.Issues)
+		return
+	}
+	fmt.Println("All checks passed.")
 }
@@ -2,7 +2,6 @@ package commands
 
 import (
 	"os"
-	"os/exec"
 
 	"github.com/claudeup/claudeup-lab/internal/lab"
 	"github.com/spf13/cobra"
@@ -16,7 +15,7 @@ func newExecCmd() *cobra.Command {
 		Short: "Run a command inside a running lab",
 		Long:  "Run a command inside a running lab. Without arguments after --, opens an interactive bash shell.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			mgr := lab.NewManager(defaultBaseDir())
+			mgr := newManager()
 			resolver := lab.NewResolver(mgr.Store())
 
 			meta, err := resolveLab(resolver, labName)
@@ -24,22 +23,14 @@ func newExecCmd() *cobra.Command {
 				return err
 			}
 
-			execArgs := []string{"exec", "--workspace-folder", meta.Worktree}
-
 			// Args after "--" are the command to run
+			command := []string{"bash"}
 			dashIdx := cmd.ArgsLenAtDash()
 			if dashIdx >= 0 && dashIdx < len(args) {
-				execArgs = append(execArgs, args[dashIdx:]...)
-			} else {
-				execArgs = append(execArgs, "bash")
+				command = args[dashIdx:]
 			}
 
-			devCmd := exec.Command("devcontainer", execArgs...)
-			devCmd.Dir = meta.Worktree // avoid "CWD outside mount namespace" when host CWD isn't mapped
-			devCmd.Stdin = os.Stdin
-			devCmd.Stdout = os.Stdout
-			devCmd.Stderr = os.Stderr
-			return devCmd.Run()
+			return mgr.Exec(meta, command, os.Stdin, os.Stdout, os.Stderr)
 		},
 	}
 
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+func newFeaturesCmd() *cobra.Command {
+	var project string
+
+	cmd := &cobra.Command{
+		Use:   "features",
+		Short: "List the devcontainer features start --feature accepts",
+		Long: `List the named devcontainer features available to --feature and where
+each comes from: the built-in registry, the user registry
+(~/.claudeup-lab/features.json) or the project's
+(.claudeup-lab/features.json). Later registries replace entries of the
+same name. A full OCI reference such as
+ghcr.io/devcontainers/features/go:1 can be used without an entry.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if project == "" {
+				cwd, err := os.Getwd()
+				if err != nil {
+					return fmt.Errorf("get working directory: %w", err)
+				}
+				project = cwd
+			}
+			project, err := filepath.Abs(project)
+			if err != nil {
+				return fmt.Errorf("resolve project path: %w", err)
+			}
+
+			mgr := newManager()
+			registry, err := mgr.FeatureRegistry(project)
+			if err != nil {
+				return err
+			}
+			entries := registry.Entries()
+
+			if format.Structured() {
+				return printResult(entries)
+			}
+
+			fmt.Printf("%-12s %-45s %-10s %s\n", "NAME", "FEATURE", "DEFAULT", "SOURCE")
+			fmt.Printf("%-12s %-45s %-10s %s\n", "----", "-------", "-------", "------")
+			for _, e := range entries {
+				version := e.DefaultVersion
+				if version == "" {
+					version = "-"
+				}
+				source := e.Source
+				if e.Path != "" {
+					source += " (" + e.Path + ")"
+				}
+				fmt.Printf("%-12s %-45s %-10s %s\n", e.Name, e.Feature, version, source)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&project, "project", "", "Project whose registry to include (default: current directory)")
+
+	return cmd
+}
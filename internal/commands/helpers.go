@@ -23,3 +23,11 @@ func resolveLab(resolver *lab.Resolver, name string) (*lab.Metadata, error) {
 	}
 	return resolver.ResolveByCWD(cwd)
 }
+
+// warnUnreadableState points at metadata files that List had to skip.
+func warnUnreadableState(problems []*lab.StateFileReport) {
+	if len(problems) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\nWarning: %d lab metadata file(s) could not be read (run: claudeup-lab state check)\n", len(problems))
+}
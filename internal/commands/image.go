@@ -0,0 +1,258 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// imageResult is the structured output of image pull, build and pin.
+type imageResult struct {
+	Image  string `json:"image"`
+	Digest string `json:"digest,omitempty"`
+	Pinned bool   `json:"pinned"`
+}
+
+func newImageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "image",
+		Short: "Manage the base image labs are created from",
+		Long: `Manage the base image labs are created from. New labs use, in order:
+start --image, CLAUDEUP_LAB_IMAGE, the digest written by image pin, image
+from the config file, and the published ghcr.io/claudeup/claudeup-lab:latest.
+Each lab records the digest of the image it was created from.`,
+	}
+
+	cmd.AddCommand(newImagePullCmd())
+	cmd.AddCommand(newImageBuildCmd())
+	cmd.AddCommand(newImageLsCmd())
+	cmd.AddCommand(newImagePruneCmd())
+	cmd.AddCommand(newImagePinCmd())
+
+	return cmd
+}
+
+func newImagePullCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "pull [image]",
+		Short: "Pull the base image from its registry",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr := newManager()
+			image := mgr.ConfiguredImage()
+			if len(args) == 1 {
+				image = args[0]
+			}
+
+			images := mgr.Images()
+			if err := images.Pull(image); err != nil {
+				return err
+			}
+			digest, err := images.Digest(image)
+			if err != nil {
+				return err
+			}
+
+			if format.Structured() {
+				return printResult(&imageResult{Image: image, Digest: digest, Pinned: mgr.PinnedImage() != ""})
+			}
+			fmt.Printf("Pulled %s\n", image)
+			if digest != "" {
+				fmt.Printf("  Digest: %s\n", digest)
+			}
+			if pin := mgr.PinnedImage(); pin != "" {
+				fmt.Printf("New labs stay on the pinned image %s (claudeup-lab image pin to move it)\n", pin)
+			}
+			return nil
+		},
+	}
+}
+
+func newImageBuildCmd() *cobra.Command {
+	var tag string
+	var noCache bool
+
+	cmd := &cobra.Command{
+		Use:   "build",
+		Short: "Build the base image from the Dockerfile embedded in this binary",
+		Long: `Build the base image locally from the Dockerfile embedded in this
+binary, for hosts that cannot reach the registry or to test changes to the
+image. The result is tagged as the configured image unless --tag is given.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr := newManager()
+			if tag == "" {
+				tag = mgr.ConfiguredImage()
+			}
+
+			images := mgr.Images()
+			if err := images.Build(tag, noCache); err != nil {
+				return err
+			}
+			id, err := images.ID(tag)
+			if err != nil {
+				return err
+			}
+
+			if format.Structured() {
+				return printResult(&imageResult{Image: tag, Digest: id, Pinned: mgr.PinnedImage() != ""})
+			}
+			fmt.Printf("Built %s (%s)\n", tag, shortImageID(id))
+			if pin := mgr.PinnedImage(); pin != "" {
+				fmt.Printf("New labs stay on the pinned image %s (claudeup-lab image pin --clear to use this one)\n", pin)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&tag, "tag", "", "Tag for the built image (default: the configured image)")
+	cmd.Flags().BoolVar(&noCache, "no-cache", false, "Rebuild every layer")
+
+	return cmd
+}
+
+func newImageLsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "ls",
+		Short: "List local base images and the labs using them",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr := newManager()
+			images, err := mgr.LabImages()
+			if err != nil {
+				return err
+			}
+
+			if format.Structured() {
+				return printResult(images)
+			}
+
+			if len(images) == 0 {
+				fmt.Println("No local images. New labs pull", mgr.DefaultImage())
+				return nil
+			}
+			fmt.Printf("%-55s %-14s %-10s %-16s %s\n", "IMAGE", "ID", "SIZE", "CREATED", "LABS")
+			fmt.Printf("%-55s %-14s %-10s %-16s %s\n", "-----", "--", "----", "-------", "----")
+			for _, img := range images {
+				ref := img.Ref()
+				if img.Default {
+					ref += " *"
+				}
+				labs := "-"
+				if len(img.Labs) > 0 {
+					labs = strings.Join(img.Labs, ", ")
+				}
+				fmt.Printf("%-55s %-14s %-10s %-16s %s\n", ref, shortImageID(img.ID), humanSize(img.Size), img.Created, labs)
+			}
+			fmt.Println()
+			fmt.Println("* new labs are created from this image")
+			return nil
+		},
+	}
+}
+
+func newImagePruneCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove local images no lab uses",
+		Long: `Remove the local base, feature and project images that no lab's
+container was created from. The image new labs are created from is kept.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format.Structured() && !force {
+				return fmt.Errorf("image prune with --output %s cannot prompt; pass --force", format.Kind)
+			}
+
+			mgr := newManager()
+			unused, err := mgr.UnusedImages()
+			if err != nil {
+				return err
+			}
+
+			if !format.Structured() {
+				if len(unused) == 0 {
+					fmt.Println("No unused images.")
+					return nil
+				}
+				fmt.Println("This will remove:")
+				for _, img := range unused {
+					fmt.Printf("  - %s (%s)\n", img.Ref(), humanSize(img.Size))
+				}
+				fmt.Println()
+				if !force && !confirm("Continue?") {
+					fmt.Println("Aborted.")
+					return nil
+				}
+			}
+
+			if err := mgr.PruneImages(unused); err != nil {
+				return err
+			}
+			if format.Structured() {
+				return printResult(unused)
+			}
+			fmt.Printf("Removed %d image(s).\n", len(unused))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+
+	return cmd
+}
+
+func newImagePinCmd() *cobra.Command {
+	var clear bool
+
+	cmd := &cobra.Command{
+		Use:   "pin [image]",
+		Short: "Lock new labs to the current digest of the base image",
+		Long: `Lock new labs to the digest the base image has now, so a later push to
+its tag does not change what they are created from. The image is pulled
+first if it is not present. CLAUDEUP_LAB_IMAGE and start --image still
+take precedence. Use --clear to follow the tag again.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr := newManager()
+
+			if clear {
+				if len(args) > 0 {
+					return fmt.Errorf("--clear takes no image")
+				}
+				if err := mgr.UnpinImage(); err != nil {
+					return err
+				}
+				if format.Structured() {
+					return printResult(&imageResult{Image: mgr.DefaultImage()})
+				}
+				fmt.Printf("Unpinned. New labs use %s\n", mgr.DefaultImage())
+				return nil
+			}
+
+			image := mgr.ConfiguredImage()
+			if len(args) == 1 {
+				image = args[0]
+			}
+			pinned, err := mgr.PinImage(image)
+			if err != nil {
+				return err
+			}
+
+			if format.Structured() {
+				_, digest, _ := strings.Cut(pinned, "@")
+				return printResult(&imageResult{Image: pinned, Digest: digest, Pinned: true})
+			}
+			fmt.Printf("Pinned new labs to %s\n", pinned)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&clear, "clear", false, "Remove the pin")
+
+	return cmd
+}
+
+// shortImageID trims an image ID to the 12 hex digits the runtime shows.
+func shortImageID(id string) string {
+	return shortContainerID(strings.TrimPrefix(id, "sha256:"))
+}
@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/spf13/cobra"
+)
+
+func newInspectCmd() *cobra.Command {
+	var labName string
+
+	cmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "Show everything about one lab",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr := newManager()
+			resolver := lab.NewResolver(mgr.Store())
+
+			meta, err := resolveLab(resolver, labName)
+			if err != nil {
+				return err
+			}
+
+			details := mgr.Inspect(meta)
+			if format.Structured() {
+				return printResult(details)
+			}
+			printLabDetails(details)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&labName, "lab", "", "Lab to inspect (name, UUID, project, or profile)")
+
+	return cmd
+}
+
+func printLabDetails(d *lab.LabDetails) {
+	fmt.Printf("Name:       %s\n", d.DisplayName)
+	fmt.Printf("ID:         %s\n", d.ID)
+	fmt.Printf("Status:     %s\n", d.Status)
+	if d.LastError != "" {
+		fmt.Printf("Error:      %s\n", d.LastError)
+	}
+	fmt.Printf("Project:    %s\n", d.Project)
+	fmt.Printf("Profile:    %s\n", d.Profile)
+	if d.ClaudeVersion != "" {
+		fmt.Printf("Claude:     %s\n", d.ClaudeVersion)
+	}
+	if d.ClaudeupVersion != "" {
+		fmt.Printf("claudeup:   %s\n", d.ClaudeupVersion)
+	}
+	branch := d.Branch
+	if d.Git != nil {
+		branch += fmt.Sprintf(" (%d ahead, %d behind %s)", d.Git.Ahead, d.Git.Behind, d.Git.SourceBranch)
+	}
+	fmt.Printf("Branch:     %s\n", branch)
+	fmt.Printf("Worktree:   %s\n", d.Worktree)
+	fmt.Printf("Bare repo:  %s\n", d.BareRepo)
+	fmt.Printf("Created:    %s\n", d.Created.Local().Format(time.RFC1123))
+	fmt.Printf("Limits:     %s\n", d.Resources)
+	if d.Image != "" {
+		image := d.Image
+		if d.ImageDigest != "" && !strings.Contains(image, "@") {
+			image += " (" + d.ImageDigest + ")"
+		}
+		fmt.Printf("Image:      %s\n", image)
+	}
+	if d.LayerImage != "" {
+		fmt.Printf("Layer:      %s (built from %s)\n", d.LayerImage, d.Dockerfile)
+	}
+	network := d.Network
+	switch network {
+	case "":
+		network = lab.NetworkFull
+	case lab.NetworkAllowlist:
+		network += fmt.Sprintf(" (%s; %d blocked request(s))", strings.Join(d.Allowlist, ", "), len(d.Blocked))
+	}
+	fmt.Printf("Network:    %s\n", network)
+	if d.Isolation != "" {
+		fmt.Printf("Isolation:  %s\n", d.Isolation)
+	}
+	if d.Offline {
+		fmt.Println("Offline:    yes (provisioned from local images, without claude upgrade)")
+	}
+	if len(d.Ports) > 0 {
+		var ports []string
+		for _, p := range d.Ports {
+			mode := "forwarded"
+			if p.Published {
+				mode = "published"
+			}
+			ports = append(ports, fmt.Sprintf("127.0.0.1:%d -> %d (%s)", p.Host, p.Container, mode))
+		}
+		fmt.Printf("Ports:      %s\n", strings.Join(ports, ", "))
+	}
+	if len(d.Secrets) > 0 {
+		var secrets []string
+		for _, s := range d.Secrets {
+			source := s.Source
+			if source == "" {
+				source = "not set"
+			}
+			secrets = append(secrets, fmt.Sprintf("%s (%s)", s.Name, source))
+		}
+		fmt.Printf("Secrets:    %s\n", strings.Join(secrets, ", "))
+	}
+	if len(d.Env) > 0 {
+		fmt.Printf("Env:        %s\n", strings.Join(d.Env, ", "))
+	}
+
+	fmt.Println()
+	fmt.Println("Container:")
+	if d.Container == nil {
+		fmt.Println("  (none)")
+	} else {
+		c := d.Container
+		fmt.Printf("  ID:       %s\n", shortContainerID(c.ID))
+		fmt.Printf("  Image:    %s\n", c.Image)
+		if c.ImageDigest != "" {
+			fmt.Printf("  Digest:   %s\n", c.ImageDigest)
+		}
+		state := c.State
+		if c.UptimeSeconds > 0 {
+			state += fmt.Sprintf(" (up %s)", time.Duration(c.UptimeSeconds)*time.Second)
+		}
+		fmt.Printf("  State:    %s\n", state)
+	}
+
+	fmt.Println()
+	fmt.Println("Volumes:")
+	if len(d.Volumes) == 0 {
+		fmt.Println("  (none)")
+	}
+	volumes := append([]string(nil), d.Volumes...)
+	sort.Strings(volumes)
+	for _, v := range volumes {
+		size := "-"
+		if n, ok := d.VolumeSizes[v]; ok {
+			size = humanSize(n)
+		}
+		fmt.Printf("  %-50s %s\n", v, size)
+	}
+
+	fmt.Println()
+	fmt.Println("Mounts:")
+	for _, m := range d.Mounts {
+		status := "applied"
+		if !m.Applied {
+			status = "skipped"
+		}
+		line := fmt.Sprintf("  [%s] %-6s %s -> %s", status, m.Type, m.Source, m.Target)
+		if m.ReadOnly {
+			line += " (read-only)"
+		}
+		switch {
+		case m.Name != "":
+			line += " [" + m.Name + "]"
+		case m.Custom:
+			line += " [--mount]"
+		}
+		if m.Note != "" {
+			line += " -- " + m.Note
+		}
+		if m.Skipped != "" {
+			line += " -- " + m.Skipped
+		}
+		fmt.Println(line)
+	}
+
+	if len(d.Devcontainer) > 0 {
+		fmt.Println()
+		fmt.Println("devcontainer.json:")
+		var buf bytes.Buffer
+		if json.Indent(&buf, d.Devcontainer, "  ", "  ") == nil {
+			fmt.Printf("  %s\n", buf.String())
+		}
+	}
+
+	if len(d.Warnings) > 0 {
+		fmt.Println()
+		for _, w := range d.Warnings {
+			fmt.Printf("Warning: %s\n", w)
+		}
+	}
+}
+
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// humanSize formats a byte count with decimal units, as docker does.
+func humanSize(n int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	size := float64(n)
+	i := 0
+	for size >= 1000 && i < len(units)-1 {
+		size /= 1000
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.1f", size), "0"), ".") + " " + units[i]
+}
@@ -2,6 +2,8 @@ package commands
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/claudeup/claudeup-lab/internal/lab"
 	"github.com/spf13/cobra"
@@ -13,28 +15,85 @@ func newListCmd() *cobra.Command {
 		Short:   "Show all labs and their status",
 		Aliases: []string{"ls"},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			mgr := lab.NewManager(defaultBaseDir())
+			mgr := newManager()
 
-			labs, err := mgr.Store().List()
+			labs, problems, err := mgr.Store().Scan()
 			if err != nil {
 				return err
 			}
+			defer warnUnreadableState(problems)
+
+			if format.Structured() {
+				infos := []*lab.LabInfo{}
+				for _, m := range labs {
+					infos = append(infos, mgr.Info(m))
+				}
+				return printResult(infos)
+			}
 
 			if len(labs) == 0 {
 				fmt.Println("No labs found.")
 				return nil
 			}
 
-			fmt.Printf("%-30s %-10s %-20s %-15s %s\n", "NAME", "ID", "PROJECT", "PROFILE", "STATUS")
-			fmt.Printf("%-30s %-10s %-20s %-15s %s\n", "----", "--", "-------", "-------", "------")
+			fmt.Printf("%-30s %-10s %-20s %-15s %-24s %-20s %s\n", "NAME", "ID", "PROJECT", "PROFILE", "LIMITS", "CLAUDE/CLAUDEUP", "STATUS")
+			fmt.Printf("%-30s %-10s %-20s %-15s %-24s %-20s %s\n", "----", "--", "-------", "-------", "------", "---------------", "------")
 
+			resumable := 0
 			for _, m := range labs {
 				status := mgr.LabStatus(m)
-				fmt.Printf("%-30s %-10s %-20s %-15s %s\n",
-					m.DisplayName, m.ID[:8], m.ProjectName, m.Profile, status)
+				if status == "stopped" && mgr.CanResume(m) {
+					status = "stopped (resumable)"
+					resumable++
+				}
+				fmt.Printf("%-30s %-10s %-20s %-15s %-24s %-20s %s\n",
+					m.DisplayName, m.ID[:8], m.ProjectName, m.Profile, limitsColumn(m.Resources), versionsColumn(m), status)
+				if m.State == lab.StateFailed && m.LastError != "" {
+					fmt.Printf("  error: %s\n", m.LastError)
+				}
+			}
+
+			if resumable > 0 {
+				fmt.Println()
+				fmt.Println("Resume a stopped lab with: claudeup-lab resume --lab <name>")
 			}
 
 			return nil
 		},
 	}
 }
+
+// limitsColumn abbreviates a lab's resource limits for the list table,
+// e.g. "2cpu 4g 512pids".
+func limitsColumn(r lab.Resources) string {
+	if r.IsZero() {
+		return "-"
+	}
+	var parts []string
+	if r.CPUs > 0 {
+		parts = append(parts, strconv.FormatFloat(r.CPUs, 'f', -1, 64)+"cpu")
+	}
+	if r.Memory > 0 {
+		parts = append(parts, lab.FormatMemory(r.Memory))
+	}
+	if r.PidsLimit > 0 {
+		parts = append(parts, strconv.FormatInt(r.PidsLimit, 10)+"pids")
+	}
+	return strings.Join(parts, " ")
+}
+
+// versionsColumn shows the Claude Code and claudeup versions in a lab,
+// e.g. "2.0.14/1.3.0", with "?" for one that is not known.
+func versionsColumn(m *lab.Metadata) string {
+	claude, claudeup := m.ClaudeVersion, m.ClaudeupVersion
+	if claude == "" && claudeup == "" {
+		return "-"
+	}
+	if claude == "" {
+		claude = "?"
+	}
+	if claudeup == "" {
+		claudeup = "?"
+	}
+	return claude + "/" + claudeup
+}
@@ -0,0 +1,168 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/claudeup/claudeup-lab/internal/docker"
+	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/spf13/cobra"
+)
+
+// logsResult is the structured output of logs.
+type logsResult struct {
+	ID           string               `json:"id"`
+	DisplayName  string               `json:"display_name"`
+	LogFile      string               `json:"log_file"`
+	Provisioning []lab.LogEntry       `json:"provisioning"`
+	Blocked      []lab.BlockedRequest `json:"blocked,omitempty"`
+	Container    []string             `json:"container"`
+}
+
+func newLogsCmd() *cobra.Command {
+	var (
+		labName    string
+		follow     bool
+		since      string
+		tail       int
+		timestamps bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Show a lab's provisioning log and container output",
+		Long: `Show the provisioning log of a lab -- the output of creating its container
+and running postCreateCommand, kept for every start and resume -- followed
+by the container's own output. For labs started with --network=allowlist,
+the requests the egress proxy blocked are listed in between. Secret values
+are redacted. --since and --tail apply to every log; --follow keeps
+streaming the container output.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if follow && format.Structured() {
+				return fmt.Errorf("--follow cannot be combined with --output %s", format.Kind)
+			}
+
+			var sinceTime time.Time
+			if since != "" {
+				t, err := parseSince(since, time.Now())
+				if err != nil {
+					return err
+				}
+				sinceTime = t
+			}
+
+			mgr := newManager()
+			resolver := lab.NewResolver(mgr.Store())
+
+			meta, err := resolveLab(resolver, labName)
+			if err != nil {
+				return err
+			}
+
+			entries, err := mgr.ReadLog(meta.ID, sinceTime, tail)
+			if err != nil {
+				return err
+			}
+
+			blocked, err := mgr.BlockedRequests(meta, sinceTime)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: blocked requests: %v\n", err)
+			}
+			if tail > 0 && len(blocked) > tail {
+				blocked = blocked[len(blocked)-tail:]
+			}
+
+			// Logs written before secrets were redacted, and the container's
+			// own output, may still hold them
+			redactor := mgr.Redactor()
+			for i := range entries {
+				entries[i].Text = redactor.String(entries[i].Text)
+			}
+
+			containerID, _ := mgr.Docker().FindContainerIncludingStopped(meta.Worktree)
+			opts := docker.LogOptions{Follow: follow, Since: sinceTime, Tail: tail, Timestamps: timestamps}
+
+			if format.Structured() {
+				result := &logsResult{
+					ID:           meta.ID,
+					DisplayName:  meta.DisplayName,
+					LogFile:      mgr.LogPath(meta.ID),
+					Provisioning: entries,
+					Blocked:      blocked,
+					Container:    []string{},
+				}
+				if result.Provisioning == nil {
+					result.Provisioning = []lab.LogEntry{}
+				}
+				if containerID != "" {
+					var buf bytes.Buffer
+					if err := mgr.Docker().ContainerLogs(containerID, opts, &buf); err != nil {
+						return err
+					}
+					if s := strings.TrimSuffix(buf.String(), "\n"); s != "" {
+						result.Container = strings.Split(s, "\n")
+					}
+				}
+				return printResult(result)
+			}
+
+			fmt.Printf("==> provisioning (%s) <==\n", mgr.LogPath(meta.ID))
+			if len(entries) == 0 {
+				fmt.Println("(empty)")
+			}
+			for _, e := range entries {
+				if timestamps && !e.Time.IsZero() {
+					fmt.Printf("%s %s\n", e.Time.Format(time.RFC3339Nano), e.Text)
+				} else {
+					fmt.Println(e.Text)
+				}
+			}
+
+			if meta.Network == lab.NetworkAllowlist {
+				fmt.Println()
+				fmt.Println("==> blocked requests <==")
+				if len(blocked) == 0 {
+					fmt.Println("(none)")
+				}
+				for _, b := range blocked {
+					fmt.Printf("%s %-7s %s\n", b.Time.Local().Format(time.RFC3339), b.Method, b.Target)
+				}
+			}
+
+			fmt.Println()
+			fmt.Println("==> container <==")
+			if containerID == "" {
+				fmt.Printf("(no container -- start or resume lab %s)\n", meta.DisplayName)
+				return nil
+			}
+			out := redactor.Writer(os.Stdout)
+			defer out.Close()
+			return mgr.Docker().ContainerLogs(containerID, opts, out)
+		},
+	}
+
+	cmd.Flags().StringVar(&labName, "lab", "", "Lab to show logs for (name, UUID, project, or profile)")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep streaming container output")
+	cmd.Flags().StringVar(&since, "since", "", "Only show lines since a timestamp (2026-01-02T15:04:05Z) or a duration ago (10m, 2h)")
+	cmd.Flags().IntVar(&tail, "tail", 0, "Only show the last N lines of each log (default all)")
+	cmd.Flags().BoolVarP(&timestamps, "timestamps", "t", false, "Show timestamps")
+
+	return cmd
+}
+
+// parseSince accepts a duration before now, an RFC 3339 timestamp or a
+// date.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (want a duration such as 10m or a timestamp such as 2026-01-02T15:04:05Z)", s)
+}
@@ -3,9 +3,9 @@ package commands
 import (
 	"encoding/hex"
 	"fmt"
-	"os/exec"
 
 	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/claudeup/claudeup-lab/internal/runner"
 	"github.com/spf13/cobra"
 )
 
@@ -16,11 +16,11 @@ func newOpenCmd() *cobra.Command {
 		Use:   "open",
 		Short: "Attach VS Code to a running lab",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if _, err := exec.LookPath("code"); err != nil {
+			mgr := newManager()
+			if _, err := mgr.Runner().LookPath("code"); err != nil {
 				return fmt.Errorf("VS Code CLI 'code' not found (see: https://code.visualstudio.com/docs/setup/mac)")
 			}
 
-			mgr := lab.NewManager(defaultBaseDir())
 			resolver := lab.NewResolver(mgr.Store())
 
 			meta, err := resolveLab(resolver, labName)
@@ -36,11 +36,13 @@ func newOpenCmd() *cobra.Command {
 			hexID := hex.EncodeToString([]byte(hostname))
 			uri := fmt.Sprintf("vscode-remote://attached-container+%s/workspaces/%s", hexID, meta.DisplayName)
 
-			codeCmd := exec.Command("code", "--folder-uri", uri)
-			if err := codeCmd.Run(); err != nil {
+			if err := runner.Run(mgr.Runner(), "code", "--folder-uri", uri); err != nil {
 				return fmt.Errorf("open VS Code: %w", err)
 			}
 
+			if format.Structured() {
+				return printResult(map[string]string{"id": meta.ID, "display_name": meta.DisplayName, "uri": uri})
+			}
 			fmt.Printf("VS Code attached to lab: %s (%s)\n", meta.DisplayName, meta.ID[:8])
 			return nil
 		},
@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/claudeup/claudeup-lab/internal/output"
+)
+
+// format is the parsed global --output flag, set before any command runs.
+var format = &output.Format{Kind: output.Table}
+
+// newManager returns a Manager for the default base directory. Under a
+// structured output format its progress goes to stderr, keeping stdout
+// parseable.
+func newManager() *lab.Manager {
+	mgr := lab.NewManager(defaultBaseDir())
+	if format.Structured() {
+		mgr.SetProgress(os.Stderr)
+	}
+	return mgr
+}
+
+// printResult writes a command's result in the structured output format.
+func printResult(v interface{}) error {
+	return format.Write(os.Stdout, v)
+}
@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/spf13/cobra"
+)
+
+// portsResult is the structured output of ports.
+type portsResult struct {
+	ID          string           `json:"id"`
+	DisplayName string           `json:"display_name"`
+	Ports       []lab.PortStatus `json:"ports"`
+}
+
+func newPortsCmd() *cobra.Command {
+	var labName string
+
+	cmd := &cobra.Command{
+		Use:   "ports",
+		Short: "List, add and remove a lab's port forwards",
+		Long: `List the ports of a lab that are reachable from the host, always on
+127.0.0.1. Ports given to start --publish with full network access are
+published by the container; ports added later, or to labs with restricted
+network access, are relayed by a forwarder process on the host that runs
+while the lab does.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr := newManager()
+			resolver := lab.NewResolver(mgr.Store())
+
+			meta, err := resolveLab(resolver, labName)
+			if err != nil {
+				return err
+			}
+			return printPorts(mgr, meta)
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(&labName, "lab", "", "Lab to manage ports for (name, UUID, project, or profile)")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "add <host:container>",
+		Short: "Forward a host port to a port inside a lab",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := lab.ParsePortMapping(args[0])
+			if err != nil {
+				return err
+			}
+
+			mgr := newManager()
+			resolver := lab.NewResolver(mgr.Store())
+
+			meta, err := resolveLab(resolver, labName)
+			if err != nil {
+				return err
+			}
+			if err := mgr.AddPort(meta, p); err != nil {
+				return err
+			}
+			return printPorts(mgr, meta)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:     "rm <host-port>",
+		Aliases: []string{"remove"},
+		Short:   "Stop forwarding a host port",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			port, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid host port %q", args[0])
+			}
+
+			mgr := newManager()
+			resolver := lab.NewResolver(mgr.Store())
+
+			meta, err := resolveLab(resolver, labName)
+			if err != nil {
+				return err
+			}
+			if err := mgr.RemovePort(meta, port); err != nil {
+				return err
+			}
+			return printPorts(mgr, meta)
+		},
+	})
+
+	cmd.AddCommand(newPortsServeCmd())
+
+	return cmd
+}
+
+// newPortsServeCmd is the forwarder process that ports add, start and
+// resume run in the background.
+func newPortsServeCmd() *cobra.Command {
+	var hostPort, containerPort int
+
+	cmd := &cobra.Command{
+		Use:    "serve",
+		Short:  "Run a port forwarder in the foreground",
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			labID, _ := cmd.Flags().GetString("lab")
+			return newManager().ServePort(labID, lab.PortMapping{Host: hostPort, Container: containerPort})
+		},
+	}
+
+	cmd.Flags().IntVar(&hostPort, "host-port", 0, "Host port to listen on")
+	cmd.Flags().IntVar(&containerPort, "container-port", 0, "Port inside the lab")
+
+	return cmd
+}
+
+func printPorts(mgr *lab.Manager, meta *lab.Metadata) error {
+	ports := mgr.Ports(meta)
+
+	if format.Structured() {
+		return printResult(&portsResult{ID: meta.ID, DisplayName: meta.DisplayName, Ports: ports})
+	}
+
+	if len(ports) == 0 {
+		fmt.Printf("Lab %s has no ports (add one with: claudeup-lab ports add --lab %s <host:container>)\n",
+			meta.DisplayName, meta.DisplayName)
+		return nil
+	}
+
+	fmt.Printf("%-18s %-10s %-10s %s\n", "HOST", "CONTAINER", "MODE", "STATUS")
+	fmt.Printf("%-18s %-10s %-10s %s\n", "----", "---------", "----", "------")
+	for _, p := range ports {
+		status := "inactive"
+		if p.Active {
+			status = "active"
+		}
+		fmt.Printf("%-18s %-10d %-10s %s\n", fmt.Sprintf("127.0.0.1:%d", p.Host), p.Container, p.Mode, status)
+	}
+	return nil
+}
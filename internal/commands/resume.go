@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/spf13/cobra"
+)
+
+func newResumeCmd() *cobra.Command {
+	var labName string
+
+	cmd := &cobra.Command{
+		Use:   "resume",
+		Short: "Start a stopped lab again (reuses its worktree and volumes)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr := newManager()
+			resolver := lab.NewResolver(mgr.Store())
+
+			meta, err := resolveLab(resolver, labName)
+			if err != nil {
+				return err
+			}
+
+			if !format.Structured() {
+				fmt.Printf("Resuming lab: %s...\n", meta.DisplayName)
+			}
+
+			err = mgr.Resume(meta)
+
+			var running *lab.AlreadyRunningError
+			if format.Structured() && (err == nil || errors.As(err, &running)) {
+				return printResult(mgr.Info(meta))
+			}
+			if errors.As(err, &running) {
+				fmt.Printf("Lab is already running: %s\n", meta.DisplayName)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Resumed lab: %s (%s)\n", meta.DisplayName, meta.ID[:8])
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&labName, "lab", "", "Lab to resume (name, UUID, project, or profile)")
+
+	return cmd
+}
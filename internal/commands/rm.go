@@ -11,6 +11,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// removeResult is the structured output of rm.
+type removeResult struct {
+	ID              string `json:"id"`
+	DisplayName     string `json:"display_name"`
+	BareRepo        string `json:"bare_repo"`
+	BareRepoRemoved bool   `json:"bare_repo_removed"`
+}
+
 func newRmCmd() *cobra.Command {
 	var labName string
 	var force bool
@@ -19,7 +27,11 @@ func newRmCmd() *cobra.Command {
 		Use:   "rm",
 		Short: "Destroy a lab and all its data",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			mgr := lab.NewManager(defaultBaseDir())
+			if format.Structured() && !force {
+				return fmt.Errorf("rm with --output %s cannot prompt; pass --force", format.Kind)
+			}
+
+			mgr := newManager()
 			resolver := lab.NewResolver(mgr.Store())
 
 			meta, err := resolveLab(resolver, labName)
@@ -40,9 +52,21 @@ func newRmCmd() *cobra.Command {
 				}
 			}
 
+			// The fingerprint goes with the lab, so this is the last chance
+			warnHostChanges(mgr, meta)
+
 			err = mgr.Remove(meta, true)
+			result := &removeResult{ID: meta.ID, DisplayName: meta.DisplayName, BareRepo: meta.BareRepo}
 
 			var prompt *lab.BareRepoCleanupPrompt
+			if format.Structured() {
+				if errors.As(err, &prompt) {
+					result.BareRepoRemoved = os.RemoveAll(prompt.BareRepo) == nil
+				} else if err != nil {
+					return err
+				}
+				return printResult(result)
+			}
 			if errors.As(err, &prompt) {
 				fmt.Printf("\nBare repo %s has no remaining worktrees.\n", prompt.BareRepo)
 				if force || confirm("Remove bare repo?") {
@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/claudeup/claudeup-lab/internal/output"
 	"github.com/spf13/cobra"
 )
 
 var version = "dev"
 
 func NewRootCmd() *cobra.Command {
+	var outputSpec string
+
 	cmd := &cobra.Command{
 		Use:   "claudeup-lab",
 		Short: "Ephemeral devcontainer environments for testing Claude Code configurations",
@@ -18,16 +21,36 @@ Claude Code configurations (plugins, skills, agents, hooks, commands)
 without affecting your host setup.`,
 		SilenceUsage:  true,
 		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			f, err := output.Parse(outputSpec)
+			if err != nil {
+				return err
+			}
+			format = f
+			return nil
+		},
 	}
 
+	cmd.PersistentFlags().StringVarP(&outputSpec, "output", "o", output.Table,
+		"Output format: table, json, yaml, or go-template=<template>")
+
 	cmd.AddCommand(newVersionCmd())
 	cmd.AddCommand(newStartCmd())
 	cmd.AddCommand(newListCmd())
+	cmd.AddCommand(newInspectCmd())
 	cmd.AddCommand(newExecCmd())
+	cmd.AddCommand(newLogsCmd())
+	cmd.AddCommand(newPortsCmd())
+	cmd.AddCommand(newAuditCmd())
 	cmd.AddCommand(newOpenCmd())
 	cmd.AddCommand(newStopCmd())
+	cmd.AddCommand(newResumeCmd())
+	cmd.AddCommand(newUpdateCmd())
 	cmd.AddCommand(newRmCmd())
+	cmd.AddCommand(newFeaturesCmd())
+	cmd.AddCommand(newImageCmd())
 	cmd.AddCommand(newDoctorCmd())
+	cmd.AddCommand(newStateCmd())
 
 	return cmd
 }
@@ -36,8 +59,12 @@ func newVersionCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "version",
 		Short: "Print the version",
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format.Structured() {
+				return printResult(map[string]string{"version": version})
+			}
 			fmt.Println(version)
+			return nil
 		},
 	}
 }
@@ -3,6 +3,7 @@ package commands
 import (
 	"fmt"
 	"os"
+	"slices"
 
 	"github.com/claudeup/claudeup-lab/internal/lab"
 	"github.com/spf13/cobra"
@@ -11,6 +12,12 @@ import (
 func newStartCmd() *cobra.Command {
 	var opts lab.StartOptions
 	var features []string
+	var resources resourceFlags
+	var network string
+	var publish []string
+	var env lab.EnvOptions
+	var mounts, noDefaultMounts []string
+	var isolation string
 
 	cmd := &cobra.Command{
 		Use:   "start",
@@ -23,15 +30,74 @@ func newStartCmd() *cobra.Command {
 				}
 				opts.Project = cwd
 			}
-			opts.Features = features
+			for _, f := range features {
+				opts.Features = append(opts.Features, lab.SplitFeatureSpecs(f)...)
+			}
+			for _, spec := range publish {
+				p, err := lab.ParsePortMapping(spec)
+				if err != nil {
+					return err
+				}
+				opts.Ports = append(opts.Ports, p)
+			}
+			for _, spec := range mounts {
+				m, err := lab.ParseMountSpec(spec)
+				if err != nil {
+					return err
+				}
+				opts.Mounts = append(opts.Mounts, m)
+			}
+
+			mgr := newManager()
+
+			limits, err := mgr.DefaultResources()
+			if err != nil {
+				return err
+			}
+			if err := resources.apply(cmd, &limits); err != nil {
+				return err
+			}
+			opts.Resources = limits
+
+			opts.Env, err = mgr.ResolveEnv(env)
+			if err != nil {
+				return err
+			}
+
+			mode, allowlist, err := mgr.DefaultNetwork()
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("network") {
+				mode = network
+			}
+			opts.Network = mode
+			opts.Allowlist = allowlist
 
-			mgr := lab.NewManager(defaultBaseDir())
+			level, skip, err := mgr.DefaultMountPolicy()
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("isolation") {
+				level = isolation
+			}
+			opts.Isolation = level
+			for _, name := range noDefaultMounts {
+				if !slices.Contains(skip, name) {
+					skip = append(skip, name)
+				}
+			}
+			opts.NoDefaultMounts = skip
 
 			meta, err := mgr.Start(&opts)
 			if err != nil {
 				return err
 			}
 
+			if format.Structured() {
+				return printResult(mgr.Info(meta))
+			}
+
 			fmt.Println()
 			fmt.Println("Lab ready!")
 			fmt.Printf("  Name:     %s\n", meta.DisplayName)
@@ -39,6 +105,29 @@ func newStartCmd() *cobra.Command {
 			fmt.Printf("  Worktree: %s\n", meta.Worktree)
 			fmt.Printf("  Branch:   %s\n", meta.Branch)
 			fmt.Printf("  Profile:  %s\n", meta.Profile)
+			if versions := versionsColumn(meta); versions != "-" {
+				fmt.Printf("  Versions: %s (claude/claudeup)\n", versions)
+			}
+			if meta.LayerImage != "" {
+				fmt.Printf("  Image:    %s (built from %s)\n", meta.LayerImage, meta.Dockerfile)
+			} else if opts.Image != "" {
+				fmt.Printf("  Image:    %s\n", meta.Image)
+			}
+			if meta.Network != lab.NetworkFull {
+				fmt.Printf("  Network:  %s\n", meta.Network)
+			}
+			if meta.Offline {
+				fmt.Println("  Offline:  started from local images; claude was not upgraded")
+			}
+			if !meta.Resources.IsZero() {
+				fmt.Printf("  Limits:   %s\n", meta.Resources)
+			}
+			for _, p := range meta.Ports {
+				fmt.Printf("  Port:     127.0.0.1:%d -> %d\n", p.Host, p.Container)
+			}
+			for _, m := range meta.CustomMounts {
+				fmt.Printf("  Mount:    %s\n", m)
+			}
 			fmt.Println()
 			fmt.Println("Next steps:")
 			fmt.Printf("  claudeup-lab exec   --lab %s -- <command>\n", meta.DisplayName)
@@ -53,8 +142,21 @@ func newStartCmd() *cobra.Command {
 	cmd.Flags().StringVar(&opts.Profile, "profile", "", "claudeup profile (default: snapshot current config)")
 	cmd.Flags().StringVar(&opts.Branch, "branch", "", "Git branch name (default: lab/<profile>)")
 	cmd.Flags().StringVar(&opts.Name, "name", "", "Display name for the lab")
-	cmd.Flags().StringSliceVar(&features, "feature", nil, "Devcontainer feature (repeatable, e.g. go:1.23)")
+	cmd.Flags().StringArrayVar(&features, "feature", nil, "Devcontainer feature: a name from 'claudeup-lab features' or an OCI reference, with options (repeatable, e.g. go:1.23,golangci=true)")
+	cmd.Flags().StringVar(&opts.ClaudeVersion, "claude-version", "", "Claude Code version to install, e.g. 2.0.14 (default: config file, else the latest)")
+	cmd.Flags().StringVar(&opts.ClaudeupVersion, "claudeup-version", "", "claudeup version to install (default: config file, else the latest)")
+	cmd.Flags().BoolVar(&opts.Offline, "offline", false, "Use only local images and skip claude upgrade (also done for one start when the registry and the pull both fail)")
+	cmd.Flags().StringVar(&opts.Image, "image", "", "Base image for this lab (default: CLAUDEUP_LAB_IMAGE, the pinned image, or image from the config file)")
 	cmd.Flags().StringVar(&opts.BaseProfile, "base-profile", "", "Apply base profile before main profile")
+	resources.register(cmd)
+	cmd.Flags().StringVar(&network, "network", lab.NetworkFull, "Network access: full, none, or allowlist (hosts from the config file)")
+	cmd.Flags().StringArrayVar(&publish, "publish", nil, "Make a lab port reachable on 127.0.0.1, as host:container (repeatable)")
+	cmd.Flags().StringArrayVar(&mounts, "mount", nil, "Bind-mount a host path into the lab, as src:dst or src:dst:ro; add z to relabel it for SELinux under podman (repeatable)")
+	cmd.Flags().StringArrayVar(&noDefaultMounts, "no-default-mount", nil, "Leave out a default host mount: profiles, ext, claude-mem, ssh, settings, or claude-json (repeatable)")
+	cmd.Flags().StringVar(&isolation, "isolation", lab.IsolationStandard, "Isolation level: standard, or strict to leave only the lab's worktree and bare repo writable on the host")
+	cmd.Flags().StringArrayVar(&env.Set, "env", nil, "Set an environment variable in the lab, KEY=VALUE (repeatable)")
+	cmd.Flags().StringArrayVar(&env.Files, "env-file", nil, "Read environment variables from a KEY=VALUE file (repeatable)")
+	cmd.Flags().StringArrayVar(&env.Pass, "pass-env", nil, "Copy an environment variable from this shell into the lab (repeatable)")
 
 	return cmd
 }
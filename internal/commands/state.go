@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/spf13/cobra"
+)
+
+// stateFileResult is the structured form of a lab.StateFileReport.
+type stateFileResult struct {
+	File          string `json:"file"`
+	ID            string `json:"id,omitempty"`
+	SchemaVersion int    `json:"schema_version"`
+	Status        string `json:"status"` // ok, outdated, corrupt or migrated
+	Error         string `json:"error,omitempty"`
+}
+
+func stateFileResultFor(r *lab.StateFileReport) stateFileResult {
+	result := stateFileResult{File: r.File, ID: r.ID, SchemaVersion: r.Version, Status: "ok"}
+	switch {
+	case r.Err != nil:
+		result.Status = "corrupt"
+		result.Error = r.Err.Error()
+	case r.NeedsMigration():
+		result.Status = "outdated"
+	}
+	return result
+}
+
+func stateFileResults(reports []*lab.StateFileReport) []stateFileResult {
+	results := []stateFileResult{}
+	for _, r := range reports {
+		results = append(results, stateFileResultFor(r))
+	}
+	return results
+}
+
+func newStateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "state",
+		Short: "Inspect and maintain lab metadata files",
+	}
+
+	cmd.AddCommand(newStateCheckCmd())
+	cmd.AddCommand(newStateMigrateCmd())
+
+	return cmd
+}
+
+func newStateCheckCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "check",
+		Short: "Report metadata files that are corrupt or use an old schema",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr := newManager()
+
+			reports, err := mgr.Store().Check()
+			if err != nil {
+				return err
+			}
+
+			if format.Structured() {
+				results := stateFileResults(reports)
+				if err := printResult(results); err != nil {
+					return err
+				}
+				for _, r := range results {
+					if r.Status == "corrupt" {
+						return fmt.Errorf("some metadata files could not be read")
+					}
+				}
+				return nil
+			}
+
+			if len(reports) == 0 {
+				fmt.Println("No lab metadata found.")
+				return nil
+			}
+
+			corrupt, outdated := 0, 0
+			for _, r := range reports {
+				name := filepath.Base(r.File)
+				switch {
+				case r.Err != nil:
+					corrupt++
+					fmt.Printf("[FAIL] %s: %v\n", name, r.Err)
+				case r.NeedsMigration():
+					outdated++
+					fmt.Printf("[WARN] %s: schema version %d (current: %d)\n", name, r.Version, lab.CurrentSchemaVersion)
+				default:
+					fmt.Printf("[OK] %s\n", name)
+				}
+			}
+
+			fmt.Println()
+			if outdated > 0 {
+				fmt.Printf("%d file(s) need migration (run: claudeup-lab state migrate)\n", outdated)
+			}
+			if corrupt > 0 {
+				return fmt.Errorf("%d metadata file(s) could not be read", corrupt)
+			}
+			fmt.Println("All metadata files are readable.")
+			return nil
+		},
+	}
+}
+
+func newStateMigrateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate",
+		Short: "Rewrite metadata files at the current schema version",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr := newManager()
+
+			migrated, problems, err := mgr.Store().Migrate()
+			if err != nil {
+				return err
+			}
+
+			if format.Structured() {
+				done := stateFileResults(migrated)
+				for i := range done {
+					done[i].Status = "migrated"
+				}
+				err := printResult(map[string][]stateFileResult{
+					"migrated": done,
+					"failed":   stateFileResults(problems),
+				})
+				if err == nil && len(problems) > 0 {
+					err = fmt.Errorf("%d metadata file(s) could not be migrated", len(problems))
+				}
+				return err
+			}
+
+			for _, r := range migrated {
+				fmt.Printf("Migrated %s: schema %d -> %d\n", filepath.Base(r.File), r.Version, lab.CurrentSchemaVersion)
+			}
+			for _, r := range problems {
+				fmt.Printf("[FAIL] %s: %v\n", filepath.Base(r.File), r.Err)
+			}
+
+			if len(migrated) == 0 && len(problems) == 0 {
+				fmt.Println("All metadata files are up to date.")
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("%d metadata file(s) could not be migrated", len(problems))
+			}
+			return nil
+		},
+	}
+}
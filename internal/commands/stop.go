@@ -14,7 +14,7 @@ func newStopCmd() *cobra.Command {
 		Use:   "stop",
 		Short: "Stop a running lab (volumes persist)",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			mgr := lab.NewManager(defaultBaseDir())
+			mgr := newManager()
 			resolver := lab.NewResolver(mgr.Store())
 
 			meta, err := resolveLab(resolver, labName)
@@ -22,23 +22,26 @@ func newStopCmd() *cobra.Command {
 				return err
 			}
 
-			fmt.Printf("Stopping lab: %s...\n", meta.DisplayName)
+			if !format.Structured() {
+				fmt.Printf("Stopping lab: %s...\n", meta.DisplayName)
+			}
 
-			containerID, err := mgr.Docker().FindContainer(meta.Worktree)
+			stopped, err := mgr.Stop(meta)
 			if err != nil {
 				return err
 			}
+			warnHostChanges(mgr, meta)
 
-			if containerID == "" {
-				fmt.Printf("No running container found for lab: %s\n", meta.DisplayName)
-				return nil
+			if format.Structured() {
+				return printResult(mgr.Info(meta))
 			}
 
-			if err := mgr.Docker().StopContainer(containerID); err != nil {
-				return err
+			if !stopped {
+				fmt.Printf("No running container found for lab: %s\n", meta.DisplayName)
+				return nil
 			}
 
-			fmt.Printf("Stopped lab: %s\n", meta.DisplayName)
+			fmt.Printf("Stopped lab: %s (resume with: claudeup-lab resume --lab %s)\n", meta.DisplayName, meta.DisplayName)
 			return nil
 		},
 	}
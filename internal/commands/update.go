@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/spf13/cobra"
+)
+
+// resourceFlags are the resource limit flags shared by start and update.
+type resourceFlags struct {
+	cpus   string
+	memory string
+	pids   int64
+}
+
+func (f *resourceFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.cpus, "cpus", "", "CPU limit, e.g. 2 or 1.5 (0 for unlimited)")
+	cmd.Flags().StringVar(&f.memory, "memory", "", "Memory limit, e.g. 512m or 4g (0 for unlimited)")
+	cmd.Flags().Int64Var(&f.pids, "pids-limit", 0, "Maximum number of processes (0 for unlimited)")
+}
+
+// changed reports whether any resource flag was given.
+func (f *resourceFlags) changed(cmd *cobra.Command) bool {
+	return cmd.Flags().Changed("cpus") || cmd.Flags().Changed("memory") || cmd.Flags().Changed("pids-limit")
+}
+
+// apply overrides r with the resource flags given on the command line.
+func (f *resourceFlags) apply(cmd *cobra.Command, r *lab.Resources) error {
+	if cmd.Flags().Changed("cpus") {
+		cpus, err := lab.ParseCPUs(f.cpus)
+		if err != nil {
+			return err
+		}
+		r.CPUs = cpus
+	}
+	if cmd.Flags().Changed("memory") {
+		mem, err := lab.ParseMemory(f.memory)
+		if err != nil {
+			return err
+		}
+		r.Memory = mem
+	}
+	if cmd.Flags().Changed("pids-limit") {
+		if f.pids < 0 {
+			return fmt.Errorf("invalid --pids-limit %d", f.pids)
+		}
+		r.PidsLimit = f.pids
+	}
+	return nil
+}
+
+func newUpdateCmd() *cobra.Command {
+	var labName string
+	var resources resourceFlags
+
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Change the resource limits of a lab",
+		Long: `Change the CPU, memory and PID limits of a lab. The new limits apply to its
+container immediately, running or stopped, and NODE_OPTIONS follows the
+memory limit in new exec sessions.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !resources.changed(cmd) {
+				return fmt.Errorf("nothing to update (set --cpus, --memory or --pids-limit)")
+			}
+
+			mgr := newManager()
+			resolver := lab.NewResolver(mgr.Store())
+
+			meta, err := resolveLab(resolver, labName)
+			if err != nil {
+				return err
+			}
+
+			limits := meta.Resources
+			if err := resources.apply(cmd, &limits); err != nil {
+				return err
+			}
+			if err := mgr.UpdateResources(meta, limits); err != nil {
+				return err
+			}
+
+			if format.Structured() {
+				return printResult(mgr.Info(meta))
+			}
+			fmt.Printf("Updated lab %s: %s\n", meta.DisplayName, meta.Resources)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&labName, "lab", "", "Lab to update (name, UUID, project, or profile)")
+	resources.register(cmd)
+
+	return cmd
+}
@@ -0,0 +1,138 @@
+// Package config loads user settings for claudeup-lab from
+// ~/.claudeup-lab/config.json. Every field is optional; command-line flags
+// and environment variables take precedence over the file.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileName is the config file name inside the claudeup-lab base directory.
+const FileName = "config.json"
+
+// Config holds user defaults for claudeup-lab.
+type Config struct {
+	// Runtime selects the container runtime: "docker" (default) or "podman".
+	Runtime string `json:"runtime,omitempty"`
+
+	// Engine selects how devcontainers are brought up: "builtin" (default)
+	// or "cli" for the devcontainer CLI.
+	Engine string `json:"engine,omitempty"`
+
+	// Image is the base image of new labs. Unset means the published
+	// image; CLAUDEUP_LAB_IMAGE, an image pin and start --image take
+	// precedence.
+	Image string `json:"image,omitempty"`
+
+	// CPUs, Memory and PidsLimit are the default resource limits of new
+	// labs, as accepted by start --cpus, --memory and --pids-limit.
+	// Unset means unlimited.
+	CPUs      float64 `json:"cpus,omitempty"`
+	Memory    string  `json:"memory,omitempty"`
+	PidsLimit int64   `json:"pids_limit,omitempty"`
+
+	// Network is the default network mode of new labs: "full" (default),
+	// "none" or "allowlist".
+	Network string `json:"network,omitempty"`
+
+	// NetworkAllowlist lists the hosts labs in allowlist mode may reach.
+	// "*.example.com" allows every subdomain of example.com. Unset means
+	// the built-in list of model API, package registry and git hosts.
+	NetworkAllowlist []string `json:"network_allowlist,omitempty"`
+
+	// Env sets environment variables in every new lab, and PassEnv copies
+	// the named ones from the host environment at start. The start flags
+	// --env, --env-file and --pass-env add to and override both.
+	Env     map[string]string `json:"env,omitempty"`
+	PassEnv []string          `json:"pass_env,omitempty"`
+
+	// Secrets names the host secrets labs may receive. Their values come
+	// from the environment or SecretsFile and are never written to
+	// devcontainer.json. Unset means GITHUB_TOKEN and CONTEXT7_API_KEY.
+	Secrets []string `json:"secrets,omitempty"`
+
+	// SecretsFile is an env file (KEY=VALUE lines) holding secret values,
+	// which must be readable by the user only. Unset means secrets.env in
+	// the claudeup-lab base directory.
+	SecretsFile string `json:"secrets_file,omitempty"`
+
+	// Isolation is the default isolation level of new labs: "standard"
+	// (default) or "strict", which leaves only the lab's worktree and bare
+	// repo writable on the host.
+	Isolation string `json:"isolation,omitempty"`
+
+	// NoDefaultMounts names default host mounts new labs go without, as
+	// accepted by start --no-default-mount.
+	NoDefaultMounts []string `json:"no_default_mounts,omitempty"`
+
+	// ClaudeVersion and ClaudeupVersion are the versions new labs install,
+	// as accepted by start --claude-version and --claudeup-version. Unset
+	// means the latest.
+	ClaudeVersion   string `json:"claude_version,omitempty"`
+	ClaudeupVersion string `json:"claudeup_version,omitempty"`
+}
+
+// Path returns the config file location for a base directory.
+func Path(baseDir string) string {
+	return filepath.Join(baseDir, FileName)
+}
+
+// Load reads the config file in baseDir. A missing file yields an empty
+// config.
+func Load(baseDir string) (*Config, error) {
+	cfg := &Config{}
+
+	data, err := os.ReadFile(Path(baseDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return &Config{}, fmt.Errorf("parse %s: %w", Path(baseDir), err)
+	}
+
+	return cfg, nil
+}
+
+// ProjectDir is the directory in a project's repository holding its
+// claudeup-lab files.
+const ProjectDir = ".claudeup-lab"
+
+// Project holds a project's own settings, from .claudeup-lab/config.json in
+// its repository.
+type Project struct {
+	// Dockerfile is built on top of the base image for the project's labs,
+	// relative to the project root. Unset means Dockerfile.lab, if present.
+	Dockerfile string `json:"dockerfile,omitempty"`
+}
+
+// ProjectPath returns the config file location for a project.
+func ProjectPath(project string) string {
+	return filepath.Join(project, ProjectDir, FileName)
+}
+
+// LoadProject reads a project's config file. A missing file yields an
+// empty config.
+func LoadProject(project string) (*Project, error) {
+	cfg := &Project{}
+
+	data, err := os.ReadFile(ProjectPath(project))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("read project config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return &Project{}, fmt.Errorf("parse %s: %w", ProjectPath(project), err)
+	}
+
+	return cfg, nil
+}
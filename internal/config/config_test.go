@@ -0,0 +1,58 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/config"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	cfg, err := config.Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Runtime != "" {
+		t.Errorf("Runtime = %q, want empty", cfg.Runtime)
+	}
+}
+
+func TestLoadRuntime(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"runtime": "podman"}`), 0o644)
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Runtime != "podman" {
+		t.Errorf("Runtime = %q, want %q", cfg.Runtime, "podman")
+	}
+}
+
+func TestLoadInvalidJSON(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"runtime": `), 0o644)
+
+	cfg, err := config.Load(dir)
+	if err == nil {
+		t.Error("expected parse error")
+	}
+	if cfg == nil {
+		t.Error("Load should return a usable empty config alongside the error")
+	}
+}
+
+func TestLoadResourceDefaults(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"cpus": 2.5, "memory": "8g", "pids_limit": 1024}`), 0o644)
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.CPUs != 2.5 || cfg.Memory != "8g" || cfg.PidsLimit != 1024 {
+		t.Errorf("limits = %v, %q, %d", cfg.CPUs, cfg.Memory, cfg.PidsLimit)
+	}
+}
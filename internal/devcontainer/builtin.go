@@ -0,0 +1,287 @@
+package devcontainer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/claudeup/claudeup-lab/internal/docker"
+	"github.com/claudeup/claudeup-lab/internal/runner"
+)
+
+// Labels the built-in engine puts on containers. They match the devcontainer
+// CLI's so containers created by either engine are found by the other and by
+// docker.Runtime.
+const (
+	labelLocalFolder = "devcontainer.local_folder"
+	labelConfigFile  = "devcontainer.config_file"
+)
+
+// postCreateMarker records, in the remote user's home, that
+// postCreateCommand has run in a container.
+const postCreateMarker = ".claudeup-lab-post-create"
+
+// keepAlive is the container command: it idles until stopped, like the
+// devcontainer CLI's default entrypoint override.
+const keepAlive = "echo Container started; trap 'exit 0' TERM; while sleep 1 & wait $!; do :; done"
+
+// BuiltinEngine creates devcontainers directly with the container runtime
+// CLI. It supports image-based configs with features, mounts, containerEnv,
+// remoteEnv, appPort, runArgs, remoteUser and postCreateCommand, which is
+// all claudeup-lab renders.
+type BuiltinEngine struct {
+	bin      string
+	runtime  docker.Runtime
+	run      runner.Runner
+	registry *registryClient
+}
+
+var _ Engine = (*BuiltinEngine)(nil)
+
+// NewBuiltinEngine returns an engine that runs the named runtime's CLI
+// with r, using rt to find existing containers.
+func NewBuiltinEngine(runtime string, rt docker.Runtime, r runner.Runner) *BuiltinEngine {
+	return &BuiltinEngine{bin: runtime, runtime: rt, run: r, registry: newRegistryClient()}
+}
+
+func (e *BuiltinEngine) Name() string { return EngineBuiltin }
+
+func (e *BuiltinEngine) Up(worktreePath string, secrets Secrets, out io.Writer) error {
+	cfg, err := LoadConfig(worktreePath)
+	if err != nil {
+		return err
+	}
+
+	containers, err := e.runtime.ListContainers(worktreePath, true)
+	if err != nil {
+		return fmt.Errorf("find devcontainer: %w", err)
+	}
+
+	var id string
+	if len(containers) > 0 {
+		id = containers[0].ID
+		if !containers[0].Running() {
+			fmt.Fprintf(out, "Starting existing container %s...\n", shortID(id))
+			if err := e.stream(out, "start", id); err != nil {
+				return err
+			}
+		}
+	} else {
+		image, err := e.featureImage(cfg, out)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(out, "Creating container...")
+		created, err := runner.Output(e.run, e.bin, createArgs(cfg, worktreePath, image, e.bin)...)
+		if err != nil {
+			return fmt.Errorf("%s create: %w", e.bin, commandError(err))
+		}
+		id = strings.TrimSpace(string(created))
+		if err := e.stream(out, "start", id); err != nil {
+			return err
+		}
+	}
+
+	return e.postCreate(id, cfg, secrets, out)
+}
+
+func (e *BuiltinEngine) Exec(worktreePath string, secrets Secrets, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	cfg, err := LoadConfig(worktreePath)
+	if err != nil {
+		return err
+	}
+	id, err := e.runtime.FindContainer(worktreePath)
+	if err != nil {
+		return fmt.Errorf("find devcontainer: %w", err)
+	}
+	if id == "" {
+		return fmt.Errorf("no running devcontainer for %s", worktreePath)
+	}
+
+	return e.run.Run(&runner.Cmd{
+		Name:   e.bin,
+		Args:   execArgs(cfg, id, secrets, command, isTerminal(stdin)),
+		Env:    secrets.environ(),
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+}
+
+// featureImage returns the image to create the container from: the
+// configured image itself, or an image with the configured features
+// installed on top of it, built on first use.
+func (e *BuiltinEngine) featureImage(cfg *Config, out io.Writer) (string, error) {
+	if len(cfg.Features) == 0 {
+		return cfg.Image, nil
+	}
+
+	// Key the image by the base's ID rather than its tag, so a base rebuilt
+	// or pulled anew under the same tag gets its features installed afresh.
+	baseID, err := runner.Output(e.run, e.bin, "image", "inspect", "--format", "{{.Id}}", cfg.Image)
+	if err != nil {
+		return "", fmt.Errorf("inspect base image %s: %w", cfg.Image, err)
+	}
+	tag := featureImageTag(strings.TrimSpace(string(baseID)), cfg.Features)
+	if runner.Run(e.run, e.bin, "image", "inspect", tag) == nil {
+		return tag, nil
+	}
+
+	buildDir, err := os.MkdirTemp("", "claudeup-lab-features-")
+	if err != nil {
+		return "", fmt.Errorf("create feature build context: %w", err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	refs := make([]string, 0, len(cfg.Features))
+	for ref := range cfg.Features {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	var features []installedFeature
+	for i, ref := range refs {
+		dir := fmt.Sprintf("feature-%d", i)
+		fmt.Fprintf(out, "Fetching feature %s...\n", ref)
+		if err := e.registry.Fetch(ref, filepath.Join(buildDir, dir)); err != nil {
+			return "", err
+		}
+		meta, err := loadFeatureMetadata(filepath.Join(buildDir, dir))
+		if err != nil {
+			return "", fmt.Errorf("feature %s: %w", ref, err)
+		}
+		features = append(features, installedFeature{
+			Dir:          dir,
+			Env:          featureEnv(meta, cfg.Features[ref]),
+			ContainerEnv: meta.ContainerEnv,
+		})
+	}
+
+	dockerfile := featureDockerfile(cfg.Image, cfg.RemoteUser, features)
+	if err := os.WriteFile(filepath.Join(buildDir, "Dockerfile"), []byte(dockerfile), 0o644); err != nil {
+		return "", fmt.Errorf("write feature Dockerfile: %w", err)
+	}
+
+	fmt.Fprintf(out, "Building %s...\n", tag)
+	if err := e.stream(out, "build", "-t", tag, buildDir); err != nil {
+		return "", err
+	}
+	return tag, nil
+}
+
+// postCreate runs postCreateCommand once per container, leaving a marker in
+// the remote user's home so restarts skip it.
+func (e *BuiltinEngine) postCreate(id string, cfg *Config, secrets Secrets, out io.Writer) error {
+	if cfg.PostCreateCommand == "" {
+		return nil
+	}
+
+	marker := `"$HOME/` + postCreateMarker + `"`
+	if runner.Run(e.run, e.bin, execArgs(cfg, id, nil, []string{"/bin/sh", "-c", "test -f " + marker}, false)...) == nil {
+		return nil
+	}
+
+	fmt.Fprintln(out, "Running postCreateCommand...")
+	script := fmt.Sprintf("%s\nstatus=$?\n[ $status -eq 0 ] && touch %s\nexit $status", cfg.PostCreateCommand, marker)
+	err := e.run.Run(&runner.Cmd{
+		Name:   e.bin,
+		Args:   execArgs(cfg, id, secrets, []string{"/bin/sh", "-c", script}, false),
+		Env:    secrets.environ(),
+		Stdout: out,
+		Stderr: out,
+	})
+	if err != nil {
+		return fmt.Errorf("postCreateCommand: %w", err)
+	}
+	return nil
+}
+
+// stream runs a runtime CLI command with its output going to out.
+func (e *BuiltinEngine) stream(out io.Writer, args ...string) error {
+	if err := e.run.Run(&runner.Cmd{Name: e.bin, Args: args, Stdout: out, Stderr: out}); err != nil {
+		return fmt.Errorf("%s %s: %w", e.bin, args[0], err)
+	}
+	return nil
+}
+
+// createArgs returns the runtime CLI arguments that create a worktree's
+// devcontainer from image.
+func createArgs(cfg *Config, worktreePath, image, runtime string) []string {
+	args := []string{"create",
+		"--label", labelLocalFolder + "=" + worktreePath,
+		"--label", labelConfigFile + "=" + ConfigPath(worktreePath),
+	}
+
+	workspace := fmt.Sprintf("type=bind,source=%s,target=%s", worktreePath, cfg.WorkspaceFolder)
+	if runtime == docker.RuntimePodman {
+		workspace += ",relabel=shared"
+	}
+	args = append(args, "--mount", workspace)
+	for _, m := range cfg.Mounts {
+		args = append(args, "--mount", m)
+	}
+
+	for _, k := range sortedKeys(cfg.ContainerEnv) {
+		args = append(args, "-e", k+"="+cfg.ContainerEnv[k])
+	}
+
+	for _, p := range cfg.AppPort {
+		args = append(args, "--publish", p)
+	}
+
+	args = append(args, "-w", cfg.WorkspaceFolder)
+	args = append(args, cfg.RunArgs...)
+	return append(args, "--entrypoint", "/bin/sh", image, "-c", keepAlive)
+}
+
+// execArgs returns the runtime CLI arguments that run command in a
+// container as the remote user, from the workspace folder, with remoteEnv
+// set. Unlike containerEnv, remoteEnv is read from devcontainer.json on
+// every exec, so it can change without recreating the container. Secrets
+// are named without values, so the runtime CLI copies them from its own
+// environment and they never appear on a command line.
+func execArgs(cfg *Config, id string, secrets Secrets, command []string, tty bool) []string {
+	args := []string{"exec", "-i"}
+	if tty {
+		args = append(args, "-t")
+	}
+	if cfg.RemoteUser != "" {
+		args = append(args, "-u", cfg.RemoteUser)
+	}
+	for _, k := range sortedKeys(cfg.RemoteEnv) {
+		args = append(args, "-e", k+"="+cfg.RemoteEnv[k])
+	}
+	for _, k := range sortedKeys(secrets) {
+		args = append(args, "-e", k)
+	}
+	args = append(args, "-w", cfg.WorkspaceFolder, id)
+	return append(args, command...)
+}
+
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// commandError folds a failed command's stderr into its error.
+func commandError(err error) error {
+	if msg := runner.Stderr(err); msg != "" {
+		return fmt.Errorf("%w: %s", err, msg)
+	}
+	return err
+}
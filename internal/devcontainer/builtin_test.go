@@ -0,0 +1,96 @@
+package devcontainer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/devcontainer"
+)
+
+func testConfig() *devcontainer.Config {
+	return &devcontainer.Config{
+		Image:             "ghcr.io/claudeup/claudeup-lab:latest",
+		RemoteUser:        "node",
+		WorkspaceFolder:   "/workspaces/myapp",
+		Mounts:            []string{"source=claudeup-lab-abc-npm,target=/home/node/.npm,type=volume"},
+		ContainerEnv:      map[string]string{"B": "2", "A": "1"},
+		RunArgs:           []string{"--userns=keep-id:uid=1000,gid=1000"},
+		PostCreateCommand: "claude upgrade",
+	}
+}
+
+func TestCreateArgs(t *testing.T) {
+	args := devcontainer.CreateArgs(testConfig(), "/labs/myapp", "img:tag", "docker")
+	got := strings.Join(args, " ")
+
+	for _, want := range []string{
+		"--label devcontainer.local_folder=/labs/myapp",
+		"--label devcontainer.config_file=/labs/myapp/.devcontainer/devcontainer.json",
+		"--mount type=bind,source=/labs/myapp,target=/workspaces/myapp ",
+		"--mount source=claudeup-lab-abc-npm,target=/home/node/.npm,type=volume",
+		"-e A=1 -e B=2",
+		"-w /workspaces/myapp",
+		"--userns=keep-id:uid=1000,gid=1000 --entrypoint /bin/sh img:tag -c",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("create args missing %q:\n%s", want, got)
+		}
+	}
+	if args[0] != "create" {
+		t.Errorf("first arg = %q, want create", args[0])
+	}
+}
+
+func TestCreateArgsPodmanRelabelsWorkspace(t *testing.T) {
+	args := devcontainer.CreateArgs(testConfig(), "/labs/myapp", "img:tag", "podman")
+	if !strings.Contains(strings.Join(args, " "), "target=/workspaces/myapp,relabel=shared") {
+		t.Errorf("podman workspace mount should be relabelled: %v", args)
+	}
+}
+
+func TestExecArgs(t *testing.T) {
+	args := devcontainer.ExecArgs(testConfig(), "abc123", nil, []string{"bash"}, true)
+	want := "exec -i -t -u node -w /workspaces/myapp abc123 bash"
+	if got := strings.Join(args, " "); got != want {
+		t.Errorf("exec args = %q, want %q", got, want)
+	}
+
+	args = devcontainer.ExecArgs(testConfig(), "abc123", nil, []string{"ls"}, false)
+	if strings.Contains(strings.Join(args, " "), "-t") {
+		t.Errorf("non-terminal exec should not allocate a tty: %v", args)
+	}
+}
+
+func TestExecArgsRemoteEnv(t *testing.T) {
+	cfg := testConfig()
+	cfg.RemoteEnv = map[string]string{"NODE_OPTIONS": "--max-old-space-size=3072", "A": "1"}
+
+	args := devcontainer.ExecArgs(cfg, "abc123", nil, []string{"bash"}, false)
+	want := "exec -i -u node -e A=1 -e NODE_OPTIONS=--max-old-space-size=3072 -w /workspaces/myapp abc123 bash"
+	if got := strings.Join(args, " "); got != want {
+		t.Errorf("exec args = %q, want %q", got, want)
+	}
+}
+
+func TestCreateArgsPublishesAppPorts(t *testing.T) {
+	cfg := testConfig()
+	cfg.AppPort = devcontainer.AppPorts{"127.0.0.1:8080:3000", "9000:9000"}
+
+	got := strings.Join(devcontainer.CreateArgs(cfg, "/labs/myapp", "img:tag", "docker"), " ")
+	if !strings.Contains(got, "--publish 127.0.0.1:8080:3000 --publish 9000:9000 -w /workspaces/myapp") {
+		t.Errorf("create args should publish appPort:\n%s", got)
+	}
+}
+
+func TestExecArgsSecretsByName(t *testing.T) {
+	secrets := devcontainer.Secrets{"GITHUB_TOKEN": "ghp_secret", "API_KEY": "sk-secret"}
+
+	args := devcontainer.ExecArgs(testConfig(), "abc123", secrets, []string{"bash"}, false)
+	got := strings.Join(args, " ")
+	if !strings.Contains(got, "-e API_KEY -e GITHUB_TOKEN -w") {
+		t.Errorf("secrets should be passed by name: %q", got)
+	}
+	if strings.Contains(got, "secret") {
+		t.Errorf("secret values on the command line: %q", got)
+	}
+}
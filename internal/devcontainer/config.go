@@ -0,0 +1,71 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config is the subset of devcontainer.json the built-in engine supports.
+type Config struct {
+	Name              string                            `json:"name"`
+	Image             string                            `json:"image"`
+	Features          map[string]map[string]interface{} `json:"features"`
+	RemoteUser        string                            `json:"remoteUser"`
+	Mounts            []string                          `json:"mounts"`
+	ContainerEnv      map[string]string                 `json:"containerEnv"`
+	RemoteEnv         map[string]string                 `json:"remoteEnv"`
+	WorkspaceFolder   string                            `json:"workspaceFolder"`
+	PostCreateCommand string                            `json:"postCreateCommand"`
+	RunArgs           []string                          `json:"runArgs"`
+	AppPort           AppPorts                          `json:"appPort"`
+}
+
+// AppPorts is devcontainer.json's appPort: a port number, a runtime
+// --publish value such as "127.0.0.1:8080:3000", or a list of either.
+type AppPorts []string
+
+func (p *AppPorts) UnmarshalJSON(data []byte) error {
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		list = []json.RawMessage{data}
+	}
+
+	ports := AppPorts{}
+	for _, raw := range list {
+		var n int
+		if err := json.Unmarshal(raw, &n); err == nil {
+			ports = append(ports, fmt.Sprintf("%d:%d", n, n))
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return fmt.Errorf("appPort: want a port number or string, got %s", raw)
+		}
+		ports = append(ports, s)
+	}
+	*p = ports
+	return nil
+}
+
+// LoadConfig reads the rendered devcontainer.json of a worktree.
+func LoadConfig(worktreePath string) (*Config, error) {
+	path := ConfigPath(worktreePath)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read devcontainer.json: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if cfg.Image == "" {
+		return nil, fmt.Errorf("%s: image is required", path)
+	}
+	if cfg.WorkspaceFolder == "" {
+		return nil, fmt.Errorf("%s: workspaceFolder is required", path)
+	}
+
+	return &cfg, nil
+}
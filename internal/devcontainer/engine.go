@@ -0,0 +1,148 @@
+// Package devcontainer brings up and runs commands in the devcontainers that
+// claudeup-lab renders. The built-in engine implements the subset of the
+// devcontainer spec claudeup-lab uses on top of the container runtime CLI;
+// the CLI engine delegates to the Node-based devcontainer CLI.
+package devcontainer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/claudeup/claudeup-lab/internal/runner"
+)
+
+// Engine names.
+const (
+	EngineBuiltin = "builtin"
+	EngineCLI     = "cli"
+)
+
+// Engine creates devcontainers and runs commands inside them.
+type Engine interface {
+	// Name returns the engine name (EngineBuiltin or EngineCLI).
+	Name() string
+
+	// Up creates the devcontainer for a worktree, or starts it again if it
+	// already exists, and runs postCreateCommand the first time with
+	// secrets in its environment. Progress is written to out.
+	Up(worktreePath string, secrets Secrets, out io.Writer) error
+
+	// Exec runs a command in the worktree's running devcontainer as the
+	// remote user, from the workspace folder, with secrets in its
+	// environment.
+	Exec(worktreePath string, secrets Secrets, command []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+// Secrets are environment variables for lifecycle commands and exec
+// sessions that never go into devcontainer.json or the container's own
+// configuration: they are handed to each command as it starts.
+type Secrets map[string]string
+
+// environ returns the secrets as sorted KEY=VALUE pairs.
+func (s Secrets) environ() []string {
+	env := make([]string, 0, len(s))
+	for _, k := range sortedKeys(s) {
+		env = append(env, k+"="+s[k])
+	}
+	return env
+}
+
+// SelectEngine picks the devcontainer engine: CLAUDEUP_LAB_ENGINE wins over
+// the configured value, and the built-in engine is the default. An unknown
+// name is an error; the built-in engine is returned alongside it.
+func SelectEngine(configured string) (string, error) {
+	name := strings.TrimSpace(os.Getenv("CLAUDEUP_LAB_ENGINE"))
+	if name == "" {
+		name = strings.TrimSpace(configured)
+	}
+	switch name {
+	case "", EngineBuiltin:
+		return EngineBuiltin, nil
+	case EngineCLI:
+		return EngineCLI, nil
+	}
+	return EngineBuiltin, fmt.Errorf("unknown devcontainer engine %q (supported: builtin, cli)", name)
+}
+
+// ConfigPath returns where the rendered devcontainer.json lives in a worktree.
+func ConfigPath(worktreePath string) string {
+	return filepath.Join(worktreePath, ".devcontainer", "devcontainer.json")
+}
+
+// CLIEngine delegates to the devcontainer CLI
+// (npm install -g @devcontainers/cli).
+type CLIEngine struct {
+	runtime string
+	run     runner.Runner
+}
+
+var _ Engine = (*CLIEngine)(nil)
+
+// NewCLIEngine returns an engine that runs the devcontainer CLI with r
+// against the named container runtime.
+func NewCLIEngine(runtime string, r runner.Runner) *CLIEngine {
+	return &CLIEngine{runtime: runtime, run: r}
+}
+
+func (e *CLIEngine) Name() string { return EngineCLI }
+
+// Command returns a devcontainer CLI invocation. The runtime flag goes right
+// after the subcommand so it never ends up in the arguments of a command
+// run by exec.
+func (e *CLIEngine) Command(subcommand string, args ...string) *runner.Cmd {
+	full := []string{subcommand}
+	if e.runtime != "" && e.runtime != "docker" {
+		full = append(full, "--docker-path", e.runtime)
+	}
+	return &runner.Cmd{Name: "devcontainer", Args: append(full, args...)}
+}
+
+// Up passes secrets to the devcontainer CLI in a temporary secrets file,
+// readable only by the user and removed when the CLI exits.
+func (e *CLIEngine) Up(worktreePath string, secrets Secrets, out io.Writer) error {
+	args := []string{"--workspace-folder", worktreePath}
+	if len(secrets) > 0 {
+		f, err := os.CreateTemp("", "claudeup-lab-secrets-*.json")
+		if err != nil {
+			return fmt.Errorf("create secrets file: %w", err)
+		}
+		defer os.Remove(f.Name())
+		err = json.NewEncoder(f).Encode(secrets)
+		if cErr := f.Close(); err == nil {
+			err = cErr
+		}
+		if err != nil {
+			return fmt.Errorf("write secrets file: %w", err)
+		}
+		args = append(args, "--secrets-file", f.Name())
+	}
+
+	cmd := e.Command("up", args...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := e.run.Run(cmd); err != nil {
+		return fmt.Errorf("devcontainer up: %w", err)
+	}
+	return nil
+}
+
+// Exec passes secrets with --remote-env, the only way the devcontainer CLI
+// takes environment for exec; unlike the built-in engine, that puts their
+// values on its command line.
+func (e *CLIEngine) Exec(worktreePath string, secrets Secrets, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	args := []string{"--workspace-folder", worktreePath}
+	for _, kv := range secrets.environ() {
+		args = append(args, "--remote-env", kv)
+	}
+	args = append(args, command...)
+	cmd := e.Command("exec", args...)
+	cmd.Dir = worktreePath // avoid "CWD outside mount namespace" when host CWD isn't mapped
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return e.run.Run(cmd)
+}
@@ -0,0 +1,150 @@
+package devcontainer_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/devcontainer"
+	"github.com/claudeup/claudeup-lab/internal/runner"
+)
+
+func TestSelectEngine(t *testing.T) {
+	tests := []struct {
+		env, configured, want string
+		wantErr               bool
+	}{
+		{"", "", "builtin", false},
+		{"", "cli", "cli", false},
+		{"builtin", "cli", "builtin", false},
+		{"cli", "", "cli", false},
+		{"", "vscode", "builtin", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("CLAUDEUP_LAB_ENGINE", tt.env)
+		got, err := devcontainer.SelectEngine(tt.configured)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("SelectEngine(%q) with env %q = %q, %v; want %q, error %v",
+				tt.configured, tt.env, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCLIEngineCommand(t *testing.T) {
+	cmd := devcontainer.NewCLIEngine("podman", runner.Default).Command("exec", "--workspace-folder", "/ws", "bash")
+	want := "devcontainer exec --docker-path podman --workspace-folder /ws bash"
+	if cmd.String() != want {
+		t.Errorf("command = %q, want %q", cmd, want)
+	}
+
+	cmd = devcontainer.NewCLIEngine("docker", runner.Default).Command("up", "--workspace-folder", "/ws")
+	if strings.Contains(cmd.String(), "--docker-path") {
+		t.Errorf("docker runtime should not pass --docker-path: %v", cmd.Args)
+	}
+}
+
+func TestCLIEngineUpSecretsFile(t *testing.T) {
+	fake := runner.NewFake()
+	var path string
+	fake.On("devcontainer", "up").Do(func(cmd *runner.Cmd) error {
+		for i, arg := range cmd.Args {
+			if arg == "--secrets-file" {
+				path = cmd.Args[i+1]
+			}
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("secrets file mode = %v, want 0600", info.Mode().Perm())
+		}
+		data, _ := os.ReadFile(path)
+		if strings.TrimSpace(string(data)) != `{"GITHUB_TOKEN":"ghp_secret"}` {
+			t.Errorf("secrets file = %s", data)
+		}
+		return nil
+	})
+
+	err := devcontainer.NewCLIEngine("docker", fake).Up("/ws", devcontainer.Secrets{"GITHUB_TOKEN": "ghp_secret"}, io.Discard)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if path == "" {
+		t.Fatal("devcontainer up not given a secrets file")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("secrets file left behind after up")
+	}
+}
+
+func writeConfig(t *testing.T, worktree, content string) {
+	t.Helper()
+	path := devcontainer.ConfigPath(worktree)
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	wt := t.TempDir()
+	writeConfig(t, wt, `{
+		"name": "claudeup-lab-myapp",
+		"image": "ghcr.io/claudeup/claudeup-lab:latest",
+		"remoteUser": "node",
+		"workspaceFolder": "/workspaces/myapp",
+		"features": {"ghcr.io/devcontainers/features/go:1": {"version": "1.23"}},
+		"containerEnv": {"NODE_OPTIONS": "--max-old-space-size=4096"}
+	}`)
+
+	cfg, err := devcontainer.LoadConfig(wt)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.RemoteUser != "node" || cfg.WorkspaceFolder != "/workspaces/myapp" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if cfg.Features["ghcr.io/devcontainers/features/go:1"]["version"] != "1.23" {
+		t.Errorf("features = %v", cfg.Features)
+	}
+}
+
+func TestLoadConfigRequiresImage(t *testing.T) {
+	wt := t.TempDir()
+	writeConfig(t, wt, `{"workspaceFolder": "/workspaces/myapp"}`)
+
+	if _, err := devcontainer.LoadConfig(wt); err == nil {
+		t.Error("expected error for config without image")
+	}
+}
+
+func TestLoadConfigAppPort(t *testing.T) {
+	for _, tc := range []struct {
+		appPort string
+		want    string
+	}{
+		{`3000`, "3000:3000"},
+		{`"127.0.0.1:8080:3000"`, "127.0.0.1:8080:3000"},
+		{`[3000, "127.0.0.1:9229:9229"]`, "3000:3000,127.0.0.1:9229:9229"},
+	} {
+		wt := t.TempDir()
+		writeConfig(t, wt, `{"image": "img:tag", "workspaceFolder": "/workspaces/myapp", "appPort": `+tc.appPort+`}`)
+
+		cfg, err := devcontainer.LoadConfig(wt)
+		if err != nil {
+			t.Fatalf("LoadConfig(appPort %s): %v", tc.appPort, err)
+		}
+		if got := strings.Join(cfg.AppPort, ","); got != tc.want {
+			t.Errorf("appPort %s = %q, want %q", tc.appPort, got, tc.want)
+		}
+	}
+
+	wt := t.TempDir()
+	writeConfig(t, wt, `{"image": "img:tag", "workspaceFolder": "/workspaces/myapp", "appPort": [true]}`)
+	if _, err := devcontainer.LoadConfig(wt); err == nil {
+		t.Error("a non-port appPort should be rejected")
+	}
+}
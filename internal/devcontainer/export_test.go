@@ -0,0 +1,26 @@
+package devcontainer
+
+import "net/http"
+
+// Internals exposed to the devcontainer_test package.
+
+var (
+	CreateArgs        = createArgs
+	ExecArgs          = execArgs
+	OptionEnvName     = optionEnvName
+	FeatureDockerfile = featureDockerfile
+	FeatureImageTag   = featureImageTag
+)
+
+type InstalledFeature = installedFeature
+
+func ParseFeatureRef(ref string) (registry, repository, reference string, err error) {
+	r, err := parseFeatureRef(ref)
+	return r.Registry, r.Repository, r.Reference, err
+}
+
+// FetchFeature fetches from a plain-HTTP registry such as an httptest server.
+func FetchFeature(ref, dest string) error {
+	c := &registryClient{http: http.DefaultClient, scheme: "http"}
+	return c.Fetch(ref, dest)
+}
@@ -0,0 +1,371 @@
+package devcontainer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// featureLayerType is the media type of the tarball in a feature's OCI
+// manifest.
+const featureLayerType = "application/vnd.devcontainers.layer.v1+tar"
+
+// featureRef is a parsed OCI feature reference such as
+// ghcr.io/devcontainers/features/go:1.
+type featureRef struct {
+	Registry   string
+	Repository string
+	Reference  string // tag or digest
+}
+
+func parseFeatureRef(ref string) (featureRef, error) {
+	registry, rest, ok := strings.Cut(ref, "/")
+	if !ok || rest == "" {
+		return featureRef{}, fmt.Errorf("invalid feature reference %q: expected registry/namespace/name[:tag]", ref)
+	}
+
+	repo, reference := rest, "latest"
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		repo, reference = rest[:i], rest[i+1:]
+	} else if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		repo, reference = rest[:i], rest[i+1:]
+	}
+
+	return featureRef{Registry: registry, Repository: repo, Reference: reference}, nil
+}
+
+// registryClient downloads feature tarballs from an OCI registry using
+// anonymous bearer tokens, which is all public feature registries need.
+type registryClient struct {
+	http   *http.Client
+	scheme string
+}
+
+func newRegistryClient() *registryClient {
+	return &registryClient{http: &http.Client{Timeout: 5 * time.Minute}, scheme: "https"}
+}
+
+// get fetches a registry URL, obtaining an anonymous token if challenged.
+func (c *registryClient) get(u, accept string, token *string) (*http.Response, error) {
+	do := func() (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if *token != "" {
+			req.Header.Set("Authorization", "Bearer "+*token)
+		}
+		return c.http.Do(req)
+	}
+
+	resp, err := do()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && *token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if *token, err = c.token(challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = do(); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return resp, nil
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// token requests an anonymous pull token from the realm named in a Bearer
+// WWW-Authenticate challenge.
+func (c *registryClient) token(challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported registry auth challenge %q", challenge)
+	}
+	params := map[string]string{}
+	for _, m := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	if params["realm"] == "" {
+		return "", fmt.Errorf("registry auth challenge has no realm: %q", challenge)
+	}
+
+	q := url.Values{}
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			q.Set(k, params[k])
+		}
+	}
+	resp, err := c.http.Get(params["realm"] + "?" + q.Encode())
+	if err != nil {
+		return "", fmt.Errorf("get registry token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get registry token: %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode registry token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// Fetch downloads a feature and extracts it into dest.
+func (c *registryClient) Fetch(ref, dest string) error {
+	r, err := parseFeatureRef(ref)
+	if err != nil {
+		return err
+	}
+	base := fmt.Sprintf("%s://%s/v2/%s", c.scheme, r.Registry, r.Repository)
+
+	var token string
+	resp, err := c.get(base+"/manifests/"+r.Reference, "application/vnd.oci.image.manifest.v1+json", &token)
+	if err != nil {
+		return fmt.Errorf("fetch feature %s: %w", ref, err)
+	}
+	var manifest struct {
+		Layers []struct {
+			MediaType string `json:"mediaType"`
+			Digest    string `json:"digest"`
+		} `json:"layers"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&manifest)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("decode manifest for %s: %w", ref, err)
+	}
+
+	digest := ""
+	for _, l := range manifest.Layers {
+		if l.MediaType == featureLayerType {
+			digest = l.Digest
+			break
+		}
+	}
+	if digest == "" {
+		return fmt.Errorf("feature %s has no %s layer", ref, featureLayerType)
+	}
+
+	resp, err = c.get(base+"/blobs/"+digest, "", &token)
+	if err != nil {
+		return fmt.Errorf("fetch feature %s: %w", ref, err)
+	}
+	blob, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("download feature %s: %w", ref, err)
+	}
+
+	sum := sha256.Sum256(blob)
+	if want := strings.TrimPrefix(digest, "sha256:"); hex.EncodeToString(sum[:]) != want {
+		return fmt.Errorf("feature %s: digest mismatch", ref)
+	}
+
+	return extractTar(blob, dest)
+}
+
+// extractTar unpacks a (possibly gzipped) tarball into dest, rejecting
+// entries that would escape it.
+func extractTar(data []byte, dest string) error {
+	var r io.Reader = bytes.NewReader(data)
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("open feature archive: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read feature archive: %w", err)
+		}
+
+		target := filepath.Join(dest, hdr.Name)
+		if target != dest && !strings.HasPrefix(target, dest+string(filepath.Separator)) {
+			return fmt.Errorf("feature archive entry %q escapes destination", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode)&0o777|0o600)
+			if err != nil {
+				return err
+			}
+			_, copyErr := io.Copy(f, tr)
+			closeErr := f.Close()
+			if copyErr != nil {
+				return copyErr
+			}
+			if closeErr != nil {
+				return closeErr
+			}
+		}
+	}
+}
+
+// featureMetadata is the subset of devcontainer-feature.json the built-in
+// engine applies.
+type featureMetadata struct {
+	ID      string `json:"id"`
+	Options map[string]struct {
+		Default interface{} `json:"default"`
+	} `json:"options"`
+	ContainerEnv map[string]string `json:"containerEnv"`
+}
+
+func loadFeatureMetadata(dir string) (*featureMetadata, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "devcontainer-feature.json"))
+	if err != nil {
+		return nil, fmt.Errorf("read devcontainer-feature.json: %w", err)
+	}
+	var meta featureMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("parse devcontainer-feature.json: %w", err)
+	}
+	return &meta, nil
+}
+
+// installedFeature is a fetched feature ready to be built into an image.
+type installedFeature struct {
+	Dir          string            // directory name inside the build context
+	Env          map[string]string // option values as install.sh env vars
+	ContainerEnv map[string]string
+}
+
+var (
+	nonWordChars   = regexp.MustCompile(`[^\w_]`)
+	leadingDigits  = regexp.MustCompile(`^[\d_]+`)
+	shellSafeValue = regexp.MustCompile(`^[A-Za-z0-9_./:@%+=,-]*$`)
+)
+
+// optionEnvName converts a feature option id to the environment variable
+// name install.sh reads, following the devcontainer features spec.
+func optionEnvName(id string) string {
+	name := nonWordChars.ReplaceAllString(id, "_")
+	name = leadingDigits.ReplaceAllString(name, "_")
+	return strings.ToUpper(name)
+}
+
+// featureEnv merges user option values over the feature's defaults.
+func featureEnv(meta *featureMetadata, options map[string]interface{}) map[string]string {
+	env := make(map[string]string)
+	for id, opt := range meta.Options {
+		if opt.Default != nil {
+			env[optionEnvName(id)] = fmt.Sprint(opt.Default)
+		}
+	}
+	for id, v := range options {
+		env[optionEnvName(id)] = fmt.Sprint(v)
+	}
+	return env
+}
+
+func shellQuote(s string) string {
+	if s != "" && shellSafeValue.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func dockerfileQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// featureDockerfile renders a Dockerfile that installs features on top of a
+// base image, the way the devcontainer CLI does: each install.sh runs as
+// root with its options as environment variables, and each feature's
+// containerEnv becomes ENV.
+func featureDockerfile(base, remoteUser string, features []installedFeature) string {
+	user := remoteUser
+	if user == "" {
+		user = "root"
+	}
+	home := "/home/" + user
+	if user == "root" {
+		home = "/root"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM %s\n", base)
+	b.WriteString("USER root\n")
+	for _, f := range features {
+		dir := "/tmp/claudeup-lab-features/" + f.Dir
+		fmt.Fprintf(&b, "COPY %s %s\n", f.Dir, dir)
+
+		var env []string
+		for _, k := range sortedKeys(f.Env) {
+			env = append(env, k+"="+shellQuote(f.Env[k]))
+		}
+		fmt.Fprintf(&b, "RUN cd %s && chmod +x install.sh && _REMOTE_USER=%s _REMOTE_USER_HOME=%s _CONTAINER_USER=%s _CONTAINER_USER_HOME=%s %s ./install.sh\n",
+			dir, user, home, user, home, strings.Join(env, " "))
+
+		for _, k := range sortedKeys(f.ContainerEnv) {
+			fmt.Fprintf(&b, "ENV %s=%s\n", k, dockerfileQuote(f.ContainerEnv[k]))
+		}
+	}
+	b.WriteString("RUN rm -rf /tmp/claudeup-lab-features\n")
+	fmt.Fprintf(&b, "USER %s\n", user)
+	return b.String()
+}
+
+// featureImageTag names the image built from a base image, identified by
+// its ID, plus features, keyed by a hash of both so identical combinations
+// share one image.
+func featureImageTag(baseID string, features map[string]map[string]interface{}) string {
+	data, _ := json.Marshal(features) // map keys are marshalled sorted
+	sum := sha256.Sum256(append([]byte(baseID+"\n"), data...))
+	return "claudeup-lab-features:" + hex.EncodeToString(sum[:])[:12]
+}
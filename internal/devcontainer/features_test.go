@@ -0,0 +1,162 @@
+package devcontainer_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/devcontainer"
+)
+
+func TestParseFeatureRef(t *testing.T) {
+	tests := []struct {
+		ref, registry, repo, reference string
+	}{
+		{"ghcr.io/devcontainers/features/go:1", "ghcr.io", "devcontainers/features/go", "1"},
+		{"ghcr.io/devcontainers/features/go", "ghcr.io", "devcontainers/features/go", "latest"},
+		{"localhost:5000/features/rust:2.1", "localhost:5000", "features/rust", "2.1"},
+		{"ghcr.io/features/go@sha256:abc", "ghcr.io", "features/go", "sha256:abc"},
+	}
+	for _, tt := range tests {
+		registry, repo, reference, err := devcontainer.ParseFeatureRef(tt.ref)
+		if err != nil {
+			t.Errorf("ParseFeatureRef(%q): %v", tt.ref, err)
+			continue
+		}
+		if registry != tt.registry || repo != tt.repo || reference != tt.reference {
+			t.Errorf("ParseFeatureRef(%q) = %q, %q, %q", tt.ref, registry, repo, reference)
+		}
+	}
+
+	if _, _, _, err := devcontainer.ParseFeatureRef("go"); err == nil {
+		t.Error("expected error for reference without registry")
+	}
+}
+
+func TestOptionEnvName(t *testing.T) {
+	tests := map[string]string{
+		"version":             "VERSION",
+		"golangciLintVersion": "GOLANGCILINTVERSION",
+		"install-tools":       "INSTALL_TOOLS",
+		"3rdParty":            "_RDPARTY",
+	}
+	for id, want := range tests {
+		if got := devcontainer.OptionEnvName(id); got != want {
+			t.Errorf("OptionEnvName(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestFeatureDockerfile(t *testing.T) {
+	df := devcontainer.FeatureDockerfile("base:latest", "node", []devcontainer.InstalledFeature{{
+		Dir:          "feature-0",
+		Env:          map[string]string{"VERSION": "1.23", "EXTRA": "a b"},
+		ContainerEnv: map[string]string{"PATH": "/usr/local/go/bin:${PATH}"},
+	}})
+
+	for _, want := range []string{
+		"FROM base:latest\nUSER root\n",
+		"COPY feature-0 /tmp/claudeup-lab-features/feature-0\n",
+		"_REMOTE_USER=node _REMOTE_USER_HOME=/home/node",
+		"EXTRA='a b' VERSION=1.23 ./install.sh",
+		`ENV PATH="/usr/local/go/bin:${PATH}"`,
+	} {
+		if !strings.Contains(df, want) {
+			t.Errorf("Dockerfile missing %q:\n%s", want, df)
+		}
+	}
+	if !strings.HasSuffix(df, "USER node\n") {
+		t.Errorf("Dockerfile should switch back to the remote user:\n%s", df)
+	}
+}
+
+func TestFeatureImageTagIsStable(t *testing.T) {
+	features := map[string]map[string]interface{}{
+		"ghcr.io/devcontainers/features/go:1":   {"version": "1.23"},
+		"ghcr.io/devcontainers/features/rust:1": {},
+	}
+	a := devcontainer.FeatureImageTag("sha256:1111", features)
+	b := devcontainer.FeatureImageTag("sha256:1111", features)
+	if a != b {
+		t.Errorf("tag not stable: %q vs %q", a, b)
+	}
+	if c := devcontainer.FeatureImageTag("sha256:2222", features); c == a {
+		t.Error("rebuilt base image should change the tag")
+	}
+}
+
+func featureTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestFetchFeature(t *testing.T) {
+	blob := featureTarball(t, map[string]string{
+		"./devcontainer-feature.json": `{"id": "go"}`,
+		"./install.sh":                "#!/bin/sh\necho ok\n",
+	})
+	sum := sha256.Sum256(blob)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:features/go:pull" {
+				http.Error(w, "bad scope", http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"token": "anon"})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer anon" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+				`Bearer realm="%s/token",service="test",scope="repository:features/go:pull"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/features/go/manifests/1":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"layers": []map[string]string{{
+					"mediaType": "application/vnd.devcontainers.layer.v1+tar",
+					"digest":    digest,
+				}},
+			})
+		case "/v2/features/go/blobs/" + digest:
+			w.Write(blob)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dest := t.TempDir()
+	ref := strings.TrimPrefix(srv.URL, "http://") + "/features/go:1"
+	if err := devcontainer.FetchFeature(ref, dest); err != nil {
+		t.Fatalf("FetchFeature: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, "install.sh"))
+	if err != nil || !strings.Contains(string(data), "echo ok") {
+		t.Errorf("install.sh not extracted: %q, %v", data, err)
+	}
+}
@@ -0,0 +1,415 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultSocket = "/var/run/docker.sock"
+
+// SocketPath returns the Engine API socket for a runtime. DOCKER_HOST (and
+// CONTAINER_HOST for podman) is honoured when it names a unix socket.
+// Podman defaults to the rootless user socket when XDG_RUNTIME_DIR is set.
+func SocketPath(runtime string) string {
+	hostVars := []string{"DOCKER_HOST"}
+	if runtime == RuntimePodman {
+		hostVars = []string{"CONTAINER_HOST", "DOCKER_HOST"}
+	}
+	for _, v := range hostVars {
+		if host := os.Getenv(v); strings.HasPrefix(host, "unix://") {
+			return strings.TrimPrefix(host, "unix://")
+		}
+	}
+
+	if runtime == RuntimePodman {
+		if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+			return filepath.Join(dir, "podman", "podman.sock")
+		}
+		return "/run/podman/podman.sock"
+	}
+	return defaultSocket
+}
+
+// APIClient talks to the Docker Engine HTTP API over a unix socket.
+type APIClient struct {
+	socket string
+	http   *http.Client
+	stream *http.Client // no timeout, for followed logs
+}
+
+var _ Runtime = (*APIClient)(nil)
+
+func NewAPIClient(socketPath string) *APIClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &APIClient{
+		socket: socketPath,
+		http:   &http.Client{Transport: transport, Timeout: 2 * time.Minute},
+		stream: &http.Client{Transport: transport},
+	}
+}
+
+// do sends a request and decodes a JSON response into out, if non-nil.
+// Non-2xx responses are returned as *APIError.
+func (c *APIClient) do(method, path string, query url.Values, out interface{}) error {
+	return c.send(method, path, query, nil, out)
+}
+
+// send is do with body, if non-nil, sent as JSON.
+func (c *APIClient) send(method, path string, query url.Values, body, out interface{}) error {
+	u := "http://docker" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode docker API request for %s: %w", path, err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("docker API %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		var msg struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &msg) != nil || msg.Message == "" {
+			msg.Message = strings.TrimSpace(string(body))
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: msg.Message}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode docker API response for %s: %w", path, err)
+	}
+	return nil
+}
+
+func filtersQuery(filters map[string][]string) url.Values {
+	data, _ := json.Marshal(filters)
+	return url.Values{"filters": {string(data)}}
+}
+
+func (c *APIClient) IsRunning() bool {
+	return c.do(http.MethodGet, "/_ping", nil, nil) == nil
+}
+
+func (c *APIClient) ListContainers(worktreePath string, all bool) ([]Container, error) {
+	query := filtersQuery(map[string][]string{
+		"label": {worktreeLabel + "=" + worktreePath},
+	})
+	if all {
+		query.Set("all", "1")
+	}
+
+	var resp []listEntry
+	if err := c.do(http.MethodGet, "/containers/json", query, &resp); err != nil {
+		return nil, err
+	}
+	return listContainers(resp), nil
+}
+
+// listEntry is one element of GET /containers/json, which is also what
+// `podman ps --format json` prints.
+type listEntry struct {
+	ID      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
+	ImageID string            `json:"ImageID"`
+	State   string            `json:"State"`
+	Status  string            `json:"Status"`
+	Labels  map[string]string `json:"Labels"`
+	Created int64             `json:"Created"`
+}
+
+// listContainers converts list entries, most recently created first.
+func listContainers(entries []listEntry) []Container {
+	containers := make([]Container, 0, len(entries))
+	for _, r := range entries {
+		name := ""
+		if len(r.Names) > 0 {
+			name = strings.TrimPrefix(r.Names[0], "/")
+		}
+		containers = append(containers, Container{
+			ID:      r.ID,
+			Name:    name,
+			Image:   r.Image,
+			ImageID: r.ImageID,
+			State:   r.State,
+			Status:  r.Status,
+			Labels:  r.Labels,
+			Created: time.Unix(r.Created, 0).UTC(),
+		})
+	}
+
+	sort.SliceStable(containers, func(i, j int) bool {
+		return containers[i].Created.After(containers[j].Created)
+	})
+	return containers
+}
+
+func (c *APIClient) FindContainer(worktreePath string) (string, error) {
+	return firstID(c.ListContainers(worktreePath, false))
+}
+
+func (c *APIClient) FindContainerIncludingStopped(worktreePath string) (string, error) {
+	return firstID(c.ListContainers(worktreePath, true))
+}
+
+func (c *APIClient) InspectContainer(id string) (*Container, error) {
+	var resp inspectResponse
+	if err := c.do(http.MethodGet, "/containers/"+url.PathEscape(id)+"/json", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.container(), nil
+}
+
+func (c *APIClient) StopContainer(id string) error {
+	err := c.do(http.MethodPost, "/containers/"+url.PathEscape(id)+"/stop", nil, nil)
+	if apiErr, ok := err.(*APIError); ok && apiErr.StatusCode == http.StatusNotModified {
+		return nil // already stopped
+	}
+	return err
+}
+
+func (c *APIClient) RemoveContainer(id string) error {
+	query := url.Values{"force": {"1"}}
+	return c.do(http.MethodDelete, "/containers/"+url.PathEscape(id), query, nil)
+}
+
+func (c *APIClient) ListVolumes(labID string) ([]Volume, error) {
+	query := filtersQuery(map[string][]string{"name": {volumePrefix}})
+
+	var resp struct {
+		Volumes []struct {
+			Name       string            `json:"Name"`
+			Driver     string            `json:"Driver"`
+			Mountpoint string            `json:"Mountpoint"`
+			Labels     map[string]string `json:"Labels"`
+		} `json:"Volumes"`
+	}
+	if err := c.do(http.MethodGet, "/volumes", query, &resp); err != nil {
+		return nil, err
+	}
+
+	var volumes []Volume
+	for _, v := range resp.Volumes {
+		if !IsLabVolume(v.Name, labID) {
+			continue
+		}
+		volumes = append(volumes, Volume{
+			Name:       v.Name,
+			Driver:     v.Driver,
+			Mountpoint: v.Mountpoint,
+			Labels:     v.Labels,
+		})
+	}
+	return volumes, nil
+}
+
+func (c *APIClient) RemoveVolumes(names []string) error {
+	var errs []string
+	for _, name := range names {
+		if err := c.do(http.MethodDelete, "/volumes/"+url.PathEscape(name), nil, nil); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("remove volumes: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// VolumeSizes reports volume disk usage from GET /system/df. The engine
+// reports -1 for volumes it has not measured; those are left out.
+func (c *APIClient) VolumeSizes(names []string) (map[string]int64, error) {
+	sizes := make(map[string]int64)
+	if len(names) == 0 {
+		return sizes, nil
+	}
+
+	var df struct {
+		Volumes []struct {
+			Name      string `json:"Name"`
+			UsageData struct {
+				Size int64 `json:"Size"`
+			} `json:"UsageData"`
+		} `json:"Volumes"`
+	}
+	if err := c.do(http.MethodGet, "/system/df", url.Values{"type": {"volume"}}, &df); err != nil {
+		return nil, fmt.Errorf("volume sizes: %w", err)
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[name] = true
+	}
+	for _, v := range df.Volumes {
+		if wanted[v.Name] && v.UsageData.Size >= 0 {
+			sizes[v.Name] = v.UsageData.Size
+		}
+	}
+	return sizes, nil
+}
+
+// UpdateLimits posts to /containers/{id}/update. Swap is kept at twice the
+// memory limit, as the CLI client does.
+func (c *APIClient) UpdateLimits(id string, limits Limits) error {
+	body := map[string]int64{}
+	if limits.CPUs > 0 {
+		body["NanoCpus"] = int64(limits.CPUs * 1e9)
+	}
+	if limits.Memory > 0 {
+		body["Memory"] = limits.Memory
+		body["MemorySwap"] = 2 * limits.Memory
+	}
+	if limits.PidsLimit != 0 {
+		body["PidsLimit"] = limits.PidsLimit
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	return c.send(http.MethodPost, "/containers/"+url.PathEscape(id)+"/update", nil, body, nil)
+}
+
+// ContainerLogs streams GET /containers/{id}/logs. Output of containers
+// without a TTY arrives multiplexed and is demultiplexed into w.
+func (c *APIClient) ContainerLogs(id string, opts LogOptions, w io.Writer) error {
+	ctr, err := c.InspectContainer(id)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{"stdout": {"1"}, "stderr": {"1"}}
+	if opts.Follow {
+		query.Set("follow", "1")
+	}
+	if !opts.Since.IsZero() {
+		query.Set("since", strconv.FormatInt(opts.Since.Unix(), 10))
+	}
+	if opts.Tail > 0 {
+		query.Set("tail", strconv.Itoa(opts.Tail))
+	}
+	if opts.Timestamps {
+		query.Set("timestamps", "1")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://docker/containers/"+url.PathEscape(id)+"/logs?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return fmt.Errorf("docker API logs %s: %w", id, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+
+	if ctr.TTY {
+		_, err = io.Copy(w, resp.Body)
+		return err
+	}
+	return demux(resp.Body, w)
+}
+
+// demux copies the payload of a multiplexed log stream, in which every
+// frame starts with an 8-byte header: the stream type, three zero bytes
+// and a big-endian payload length.
+func demux(r io.Reader, w io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("read log stream: %w", err)
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return fmt.Errorf("read log stream: %w", err)
+		}
+	}
+}
+
+func (c *APIClient) ContainerHostname(worktreePath string) (string, error) {
+	return containerHostname(c, worktreePath)
+}
+
+// inspectResponse is the subset of GET /containers/{id}/json (and of
+// `docker container inspect`) that claudeup-lab uses.
+type inspectResponse struct {
+	ID        string `json:"Id"`
+	Name      string `json:"Name"`
+	Image     string `json:"Image"`
+	ImageName string `json:"ImageName"` // podman only
+	Created   string `json:"Created"`
+	Config    struct {
+		Hostname string            `json:"Hostname"`
+		Image    string            `json:"Image"`
+		Tty      bool              `json:"Tty"`
+		Labels   map[string]string `json:"Labels"`
+	} `json:"Config"`
+	State struct {
+		Status    string `json:"Status"`
+		StartedAt string `json:"StartedAt"`
+	} `json:"State"`
+}
+
+func (r *inspectResponse) container() *Container {
+	created, _ := time.Parse(time.RFC3339Nano, r.Created)
+	started, _ := time.Parse(time.RFC3339Nano, r.State.StartedAt)
+	image := r.Config.Image
+	if image == "" {
+		image = r.ImageName
+	}
+	return &Container{
+		ID:        r.ID,
+		Name:      strings.TrimPrefix(r.Name, "/"),
+		Image:     image,
+		ImageID:   r.Image,
+		State:     r.State.Status,
+		Hostname:  r.Config.Hostname,
+		TTY:       r.Config.Tty,
+		Labels:    r.Config.Labels,
+		Created:   created,
+		StartedAt: started,
+	}
+}
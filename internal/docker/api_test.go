@@ -0,0 +1,251 @@
+package docker_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/claudeup/claudeup-lab/internal/docker"
+)
+
+// fakeEngine serves a handler on a unix socket and returns a client for it.
+func fakeEngine(t *testing.T, handler http.Handler) *docker.APIClient {
+	t.Helper()
+	// Unix socket paths are length-limited, so avoid the long t.TempDir path
+	dir, err := os.MkdirTemp("", "dk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	sock := filepath.Join(dir, "docker.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	return docker.NewAPIClient(sock)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestAPIIsRunning(t *testing.T) {
+	client := fakeEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_ping" {
+			w.Write([]byte("OK"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	if !client.IsRunning() {
+		t.Error("expected fake engine to be reported as running")
+	}
+
+	missing := docker.NewAPIClient(filepath.Join(t.TempDir(), "missing.sock"))
+	if missing.IsRunning() {
+		t.Error("expected missing socket to be reported as not running")
+	}
+}
+
+func TestAPIFindContainerPicksNewest(t *testing.T) {
+	var gotFilters string
+	client := fakeEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotFilters = r.URL.Query().Get("filters")
+		writeJSON(w, 200, []map[string]interface{}{
+			{"Id": "older", "Names": []string{"/a"}, "State": "running", "Created": 100},
+			{"Id": "newer", "Names": []string{"/b"}, "State": "running", "Created": 200},
+		})
+	}))
+
+	id, err := client.FindContainer("/home/user/.claudeup-lab/workspaces/myapp-base")
+	if err != nil {
+		t.Fatalf("FindContainer: %v", err)
+	}
+	if id != "newer" {
+		t.Errorf("FindContainer = %q, want a single ID %q", id, "newer")
+	}
+	if !strings.Contains(gotFilters, "devcontainer.local_folder=/home/user/.claudeup-lab/workspaces/myapp-base") {
+		t.Errorf("filters should select by worktree label, got %s", gotFilters)
+	}
+}
+
+func TestAPIListVolumesExactMatch(t *testing.T) {
+	client := fakeEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 200, map[string]interface{}{
+			"Volumes": []map[string]interface{}{
+				{"Name": "claudeup-lab-config-abc-123", "Driver": "local"},
+				{"Name": "claudeup-lab-npm-abc-123", "Driver": "local"},
+				{"Name": "claudeup-lab-config-abc-1234", "Driver": "local"},
+				{"Name": "claudeup-lab-config-xabc-123", "Driver": "local"},
+				{"Name": "unrelated-abc-123", "Driver": "local"},
+			},
+		})
+	}))
+
+	vols, err := client.ListVolumes("abc-123")
+	if err != nil {
+		t.Fatalf("ListVolumes: %v", err)
+	}
+	names := docker.VolumeNames(vols)
+	if len(names) != 2 || names[0] != "claudeup-lab-config-abc-123" || names[1] != "claudeup-lab-npm-abc-123" {
+		t.Errorf("ListVolumes = %v, want only the two volumes of lab abc-123", names)
+	}
+}
+
+func TestAPIInspectNotFound(t *testing.T) {
+	client := fakeEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 404, map[string]string{"message": "No such container: nope"})
+	}))
+
+	_, err := client.InspectContainer("nope")
+	if !errors.Is(err, docker.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	var apiErr *docker.APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "No such container: nope" {
+		t.Errorf("expected APIError with engine message, got %v", err)
+	}
+}
+
+func TestAPIContainerHostname(t *testing.T) {
+	client := fakeEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/json":
+			writeJSON(w, 200, []map[string]interface{}{{"Id": "c1", "State": "running"}})
+		case "/containers/c1/json":
+			writeJSON(w, 200, map[string]interface{}{
+				"Id":     "c1",
+				"Config": map[string]interface{}{"Hostname": "4f2a9c1b", "Image": "test:latest"},
+				"State":  map[string]interface{}{"Status": "running", "StartedAt": "2026-02-10T12:00:00Z"},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	host, err := client.ContainerHostname("/ws")
+	if err != nil {
+		t.Fatalf("ContainerHostname: %v", err)
+	}
+	if host != "4f2a9c1b" {
+		t.Errorf("hostname = %q, want %q", host, "4f2a9c1b")
+	}
+}
+
+func TestAPIStopAlreadyStopped(t *testing.T) {
+	client := fakeEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	if err := client.StopContainer("c1"); err != nil {
+		t.Errorf("stopping an already stopped container should succeed, got %v", err)
+	}
+}
+
+func TestIsLabVolume(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"claudeup-lab-config-abc-123", true},
+		{"claudeup-lab-bashhistory-abc-123", true},
+		{"claudeup-lab-config-abc-1234", false},
+		{"claudeup-lab-abc-123", false},
+		{"other-config-abc-123", false},
+	}
+	for _, tt := range tests {
+		if got := docker.IsLabVolume(tt.name, "abc-123"); got != tt.want {
+			t.Errorf("IsLabVolume(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAPIVolumeSizes(t *testing.T) {
+	client := fakeEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/system/df" || r.URL.Query().Get("type") != "volume" {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, 200, map[string]interface{}{"Volumes": []map[string]interface{}{
+			{"Name": "claudeup-lab-npm-abc", "UsageData": map[string]interface{}{"Size": 2048}},
+			{"Name": "claudeup-lab-config-abc", "UsageData": map[string]interface{}{"Size": -1}},
+			{"Name": "other", "UsageData": map[string]interface{}{"Size": 99}},
+		}})
+	}))
+
+	sizes, err := client.VolumeSizes([]string{"claudeup-lab-npm-abc", "claudeup-lab-config-abc"})
+	if err != nil {
+		t.Fatalf("VolumeSizes: %v", err)
+	}
+	if len(sizes) != 1 || sizes["claudeup-lab-npm-abc"] != 2048 {
+		t.Errorf("VolumeSizes = %v, want only the measured lab volume", sizes)
+	}
+}
+
+func TestAPIContainerLogsDemultiplexes(t *testing.T) {
+	frame := func(stream byte, payload string) []byte {
+		header := []byte{stream, 0, 0, 0, 0, 0, 0, byte(len(payload))}
+		return append(header, payload...)
+	}
+
+	var query url.Values
+	client := fakeEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/c1/json":
+			writeJSON(w, 200, map[string]interface{}{"Id": "c1", "Config": map[string]interface{}{"Tty": false}})
+		case "/containers/c1/logs":
+			query = r.URL.Query()
+			w.Write(frame(1, "hello\n"))
+			w.Write(frame(2, "oops\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	var out bytes.Buffer
+	if err := client.ContainerLogs("c1", docker.LogOptions{Tail: 5, Since: time.Unix(1700000000, 0)}, &out); err != nil {
+		t.Fatalf("ContainerLogs: %v", err)
+	}
+	if out.String() != "hello\noops\n" {
+		t.Errorf("logs = %q, want both streams without frame headers", out.String())
+	}
+	if query.Get("tail") != "5" || query.Get("since") != "1700000000" || query.Get("follow") != "" {
+		t.Errorf("query = %v", query)
+	}
+}
+
+func TestAPIUpdateLimits(t *testing.T) {
+	var body map[string]int64
+	client := fakeEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/containers/c1/update" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		writeJSON(w, 200, map[string]interface{}{"Warnings": []string{}})
+	}))
+
+	if err := client.UpdateLimits("c1", docker.Limits{CPUs: 1.5, Memory: 1 << 30, PidsLimit: -1}); err != nil {
+		t.Fatalf("UpdateLimits: %v", err)
+	}
+	want := map[string]int64{"NanoCpus": 1500000000, "Memory": 1 << 30, "MemorySwap": 2 << 30, "PidsLimit": -1}
+	for k, v := range want {
+		if body[k] != v {
+			t.Errorf("%s = %d, want %d (body %v)", k, body[k], v, body)
+		}
+	}
+}
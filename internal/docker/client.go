@@ -1,81 +1,215 @@
 package docker
 
 import (
+	"encoding/json"
 	"fmt"
-	"os"
-	"os/exec"
+	"io"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/claudeup/claudeup-lab/internal/runner"
 )
 
-// Client wraps Docker CLI commands for container and volume operations.
-type Client struct{}
+// Client wraps container CLI commands for container and volume operations.
+// It drives the docker CLI by default and the podman CLI when created with
+// NewClientFor(RuntimePodman).
+type Client struct {
+	bin string
+	run runner.Runner
+}
+
+var _ Runtime = (*Client)(nil)
 
 func NewClient() *Client {
-	return &Client{}
+	return NewClientFor(RuntimeDocker, runner.Default)
+}
+
+// NewClientFor returns a Client that runs the named runtime's CLI with r.
+func NewClientFor(runtime string, r runner.Runner) *Client {
+	return &Client{bin: runtime, run: r}
 }
 
 func (c *Client) IsRunning() bool {
-	cmd := exec.Command("docker", "info")
-	cmd.Stdout = nil
-	cmd.Stderr = nil
-	return cmd.Run() == nil
+	return runner.Run(c.run, c.bin, "info") == nil
+}
+
+// psEntry is one line of `docker ps --format '{{json .}}'`.
+type psEntry struct {
+	ID        string `json:"ID"`
+	Names     string `json:"Names"`
+	Image     string `json:"Image"`
+	State     string `json:"State"`
+	Status    string `json:"Status"`
+	Labels    string `json:"Labels"`
+	CreatedAt string `json:"CreatedAt"`
+}
+
+// ListContainers returns the devcontainers labelled with the given worktree
+// path, most recently created first.
+func (c *Client) ListContainers(worktreePath string, all bool) ([]Container, error) {
+	args := []string{"ps", "--no-trunc",
+		"--filter", fmt.Sprintf("label=%s=%s", worktreeLabel, worktreePath)}
+	if all {
+		args = append(args, "-a")
+	}
+
+	// podman prints the Engine API list format as one JSON array
+	if c.bin == RuntimePodman {
+		out, err := runner.Output(c.run, c.bin, append(args, "--format", "json")...)
+		if err != nil {
+			return nil, fmt.Errorf("%s ps: %w", c.bin, err)
+		}
+		var entries []listEntry
+		if err := json.Unmarshal(out, &entries); err != nil {
+			return nil, fmt.Errorf("parse %s ps output: %w", c.bin, err)
+		}
+		return listContainers(entries), nil
+	}
+
+	out, err := runner.Output(c.run, c.bin, append(args, "--format", "{{json .}}")...)
+	if err != nil {
+		return nil, fmt.Errorf("%s ps: %w", c.bin, err)
+	}
+
+	var containers []Container
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line == "" {
+			continue
+		}
+		var e psEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return nil, fmt.Errorf("parse %s ps output: %w", c.bin, err)
+		}
+		created, _ := time.Parse("2006-01-02 15:04:05 -0700 MST", e.CreatedAt)
+		containers = append(containers, Container{
+			ID:      e.ID,
+			Name:    e.Names,
+			Image:   e.Image,
+			State:   e.State,
+			Status:  e.Status,
+			Labels:  parseLabels(e.Labels),
+			Created: created,
+		})
+	}
+
+	sort.SliceStable(containers, func(i, j int) bool {
+		return containers[i].Created.After(containers[j].Created)
+	})
+	return containers, nil
 }
 
 // FindContainer returns the container ID for a running devcontainer
 // matching the given worktree path label, or empty string if none found.
 func (c *Client) FindContainer(worktreePath string) (string, error) {
-	cmd := exec.Command("docker", "ps", "-q",
-		"--filter", fmt.Sprintf("label=devcontainer.local_folder=%s", worktreePath))
-	out, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("docker ps: %w", err)
-	}
-	return strings.TrimSpace(string(out)), nil
+	return firstID(c.ListContainers(worktreePath, false))
 }
 
 // FindContainerIncludingStopped returns the container ID including stopped
 // containers matching the given worktree path label.
 func (c *Client) FindContainerIncludingStopped(worktreePath string) (string, error) {
-	cmd := exec.Command("docker", "ps", "-aq",
-		"--filter", fmt.Sprintf("label=devcontainer.local_folder=%s", worktreePath))
-	out, err := cmd.Output()
+	return firstID(c.ListContainers(worktreePath, true))
+}
+
+// InspectContainer returns the details of a single container.
+func (c *Client) InspectContainer(id string) (*Container, error) {
+	out, err := runner.Output(c.run, c.bin, "container", "inspect", id)
 	if err != nil {
-		return "", fmt.Errorf("docker ps: %w", err)
+		if strings.Contains(strings.ToLower(runner.Stderr(err)), "no such") {
+			return nil, fmt.Errorf("%s inspect %s: %w", c.bin, id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s inspect %s: %w", c.bin, id, err)
+	}
+
+	var resp []inspectResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, fmt.Errorf("parse %s inspect output: %w", c.bin, err)
 	}
-	return strings.TrimSpace(string(out)), nil
+	if len(resp) == 0 {
+		return nil, fmt.Errorf("%s inspect %s: %w", c.bin, id, ErrNotFound)
+	}
+	return resp[0].container(), nil
 }
 
 func (c *Client) StopContainer(id string) error {
-	cmd := exec.Command("docker", "stop", id)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("docker stop %s: %w", id, err)
+	if err := runner.Run(c.run, c.bin, "stop", id); err != nil {
+		return fmt.Errorf("%s stop %s: %w", c.bin, id, err)
 	}
 	return nil
 }
 
 func (c *Client) RemoveContainer(id string) error {
-	cmd := exec.Command("docker", "rm", "-f", id)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("docker rm %s: %w", id, err)
+	if err := runner.Run(c.run, c.bin, "rm", "-f", id); err != nil {
+		return fmt.Errorf("%s rm %s: %w", c.bin, id, err)
 	}
 	return nil
 }
 
-// ListVolumes returns Docker volume names containing the given pattern.
-func (c *Client) ListVolumes(pattern string) ([]string, error) {
-	cmd := exec.Command("docker", "volume", "ls", "--format", "{{.Name}}")
-	out, err := cmd.Output()
+// volumeEntry is one volume as printed by `docker volume ls --format
+// '{{json .}}'` (labels as a string) or `podman volume ls --format json`
+// (labels as an object).
+type volumeEntry struct {
+	Name       string          `json:"Name"`
+	Driver     string          `json:"Driver"`
+	Mountpoint string          `json:"Mountpoint"`
+	Labels     json.RawMessage `json:"Labels"`
+}
+
+func (e *volumeEntry) labels() map[string]string {
+	var m map[string]string
+	if json.Unmarshal(e.Labels, &m) == nil {
+		return m
+	}
+	var s string
+	json.Unmarshal(e.Labels, &s)
+	return parseLabels(s)
+}
+
+// ListVolumes returns the per-lab volumes of the given lab.
+func (c *Client) ListVolumes(labID string) ([]Volume, error) {
+	args := []string{"volume", "ls", "--filter", "name=" + volumePrefix}
+	if c.bin == RuntimePodman {
+		args = append(args, "--format", "json")
+	} else {
+		args = append(args, "--format", "{{json .}}")
+	}
+	out, err := runner.Output(c.run, c.bin, args...)
 	if err != nil {
-		return nil, fmt.Errorf("docker volume ls: %w", err)
+		return nil, fmt.Errorf("%s volume ls: %w", c.bin, err)
 	}
 
-	var matches []string
-	for _, name := range strings.Split(strings.TrimSpace(string(out)), "\n") {
-		if name != "" && strings.Contains(name, pattern) {
-			matches = append(matches, name)
+	var entries []volumeEntry
+	if c.bin == RuntimePodman {
+		if err := json.Unmarshal(out, &entries); err != nil {
+			return nil, fmt.Errorf("parse %s volume ls output: %w", c.bin, err)
+		}
+	} else {
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			if line == "" {
+				continue
+			}
+			var e volumeEntry
+			if err := json.Unmarshal([]byte(line), &e); err != nil {
+				return nil, fmt.Errorf("parse %s volume ls output: %w", c.bin, err)
+			}
+			entries = append(entries, e)
 		}
 	}
-	return matches, nil
+
+	var volumes []Volume
+	for _, e := range entries {
+		if !IsLabVolume(e.Name, labID) {
+			continue
+		}
+		volumes = append(volumes, Volume{
+			Name:       e.Name,
+			Driver:     e.Driver,
+			Mountpoint: e.Mountpoint,
+			Labels:     e.labels(),
+		})
+	}
+	return volumes, nil
 }
 
 func (c *Client) RemoveVolumes(names []string) error {
@@ -83,21 +217,131 @@ func (c *Client) RemoveVolumes(names []string) error {
 		return nil
 	}
 	args := append([]string{"volume", "rm"}, names...)
-	cmd := exec.Command("docker", args...)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("docker volume rm: %w", err)
+	if err := runner.Run(c.run, c.bin, args...); err != nil {
+		return fmt.Errorf("%s volume rm: %w", c.bin, err)
+	}
+	return nil
+}
+
+// VolumeSizes reports volume disk usage. Docker measures it with system
+// df; podman volumes are plain host directories, so their files are added
+// up directly.
+func (c *Client) VolumeSizes(names []string) (map[string]int64, error) {
+	sizes := make(map[string]int64)
+	if len(names) == 0 {
+		return sizes, nil
+	}
+
+	if c.bin == RuntimePodman {
+		out, err := runner.Output(c.run, c.bin, append([]string{"volume", "inspect"}, names...)...)
+		if err != nil {
+			return nil, fmt.Errorf("%s volume inspect: %w", c.bin, err)
+		}
+		var entries []volumeEntry
+		if err := json.Unmarshal(out, &entries); err != nil {
+			return nil, fmt.Errorf("parse %s volume inspect output: %w", c.bin, err)
+		}
+		for _, e := range entries {
+			if size, err := dirSize(e.Mountpoint); err == nil {
+				sizes[e.Name] = size
+			}
+		}
+		return sizes, nil
+	}
+
+	out, err := runner.Output(c.run, c.bin, "system", "df", "-v", "--format", "{{json .}}")
+	if err != nil {
+		return nil, fmt.Errorf("%s system df: %w", c.bin, err)
+	}
+	var df struct {
+		Volumes []struct {
+			Name string `json:"Name"`
+			Size string `json:"Size"`
+		} `json:"Volumes"`
+	}
+	if err := json.Unmarshal(out, &df); err != nil {
+		return nil, fmt.Errorf("parse %s system df output: %w", c.bin, err)
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[name] = true
+	}
+	for _, v := range df.Volumes {
+		if !wanted[v.Name] {
+			continue
+		}
+		if size, err := parseSize(v.Size); err == nil {
+			sizes[v.Name] = size
+		}
+	}
+	return sizes, nil
+}
+
+// UpdateLimits runs `docker update`. Swap is kept at twice the memory
+// limit, the default for a container created with only --memory.
+func (c *Client) UpdateLimits(id string, limits Limits) error {
+	args := []string{"update"}
+	if limits.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(limits.CPUs, 'f', -1, 64))
+	}
+	if limits.Memory > 0 {
+		args = append(args,
+			"--memory", strconv.FormatInt(limits.Memory, 10),
+			"--memory-swap", strconv.FormatInt(2*limits.Memory, 10))
+	}
+	if limits.PidsLimit != 0 {
+		args = append(args, "--pids-limit", strconv.FormatInt(limits.PidsLimit, 10))
+	}
+	if len(args) == 1 {
+		return nil
+	}
+	args = append(args, id)
+
+	if err := runner.Run(c.run, c.bin, args...); err != nil {
+		return fmt.Errorf("%s update %s: %w", c.bin, id, err)
+	}
+	return nil
+}
+
+// ContainerLogs runs `docker logs`, writing the container's stdout and
+// stderr to w.
+func (c *Client) ContainerLogs(id string, opts LogOptions, w io.Writer) error {
+	args := []string{"logs"}
+	if opts.Follow {
+		args = append(args, "--follow")
+	}
+	if !opts.Since.IsZero() {
+		args = append(args, "--since", strconv.FormatInt(opts.Since.Unix(), 10))
+	}
+	if opts.Tail > 0 {
+		args = append(args, "--tail", strconv.Itoa(opts.Tail))
+	}
+	if opts.Timestamps {
+		args = append(args, "--timestamps")
+	}
+	args = append(args, id)
+
+	cmd := &runner.Cmd{Name: c.bin, Args: args, Stdout: w, Stderr: w}
+	if err := c.run.Run(cmd); err != nil {
+		return fmt.Errorf("%s logs %s: %w", c.bin, id, err)
 	}
 	return nil
 }
 
 // ContainerHostname returns the hostname of a running devcontainer.
 func (c *Client) ContainerHostname(worktreePath string) (string, error) {
-	cmd := exec.Command("devcontainer", "exec",
-		"--workspace-folder", worktreePath, "hostname")
-	cmd.Stderr = os.Stderr
-	out, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("get container hostname: %w", err)
+	return containerHostname(c, worktreePath)
+}
+
+// parseLabels splits the comma-separated key=value list the CLI prints for
+// labels.
+func parseLabels(s string) map[string]string {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			labels[k] = v
+		}
 	}
-	return strings.TrimSpace(string(out)), nil
+	return labels
 }
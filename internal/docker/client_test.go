@@ -1,10 +1,15 @@
 package docker_test
 
 import (
+	"bytes"
+	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/claudeup/claudeup-lab/internal/docker"
+	"github.com/claudeup/claudeup-lab/internal/runner"
 )
 
 func requireDocker(t *testing.T) {
@@ -38,7 +43,7 @@ func TestFindContainerNoMatch(t *testing.T) {
 func TestListVolumesNoMatch(t *testing.T) {
 	requireDocker(t)
 	client := docker.NewClient()
-	vols, err := client.ListVolumes("claudeup-lab-test-nonexistent-pattern")
+	vols, err := client.ListVolumes("00000000-0000-0000-0000-000000000000")
 	if err != nil {
 		t.Fatalf("ListVolumes: %v", err)
 	}
@@ -46,3 +51,53 @@ func TestListVolumesNoMatch(t *testing.T) {
 		t.Errorf("expected 0 volumes, got %d", len(vols))
 	}
 }
+
+func TestVolumeSizesDocker(t *testing.T) {
+	fake := runner.NewFake()
+	fake.On("docker", "system", "df").Return(`{"Volumes":[{"Name":"claudeup-lab-npm-abc","Size":"1.5MB"},{"Name":"claudeup-lab-config-abc","Size":"12kB"},{"Name":"other","Size":"1GB"}]}`, nil)
+
+	client := docker.NewClientFor(docker.RuntimeDocker, fake)
+	sizes, err := client.VolumeSizes([]string{"claudeup-lab-npm-abc", "claudeup-lab-config-abc"})
+	if err != nil {
+		t.Fatalf("VolumeSizes: %v", err)
+	}
+	if len(sizes) != 2 || sizes["claudeup-lab-npm-abc"] != 1500000 || sizes["claudeup-lab-config-abc"] != 12000 {
+		t.Errorf("VolumeSizes = %v", sizes)
+	}
+}
+
+func TestVolumeSizesPodman(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a"), make([]byte, 300), 0o644)
+	os.Mkdir(filepath.Join(dir, "sub"), 0o755)
+	os.WriteFile(filepath.Join(dir, "sub", "b"), make([]byte, 200), 0o644)
+
+	fake := runner.NewFake()
+	fake.On("podman", "volume", "inspect").Return(fmt.Sprintf(`[{"Name":"claudeup-lab-npm-abc","Mountpoint":%q}]`, dir), nil)
+
+	client := docker.NewClientFor(docker.RuntimePodman, fake)
+	sizes, err := client.VolumeSizes([]string{"claudeup-lab-npm-abc"})
+	if err != nil {
+		t.Fatalf("VolumeSizes: %v", err)
+	}
+	if sizes["claudeup-lab-npm-abc"] != 500 {
+		t.Errorf("size = %d, want 500", sizes["claudeup-lab-npm-abc"])
+	}
+}
+
+func TestContainerLogsArgs(t *testing.T) {
+	fake := runner.NewFake()
+	fake.On("podman", "logs").Return("line\n", nil)
+
+	client := docker.NewClientFor(docker.RuntimePodman, fake)
+	var out bytes.Buffer
+	if err := client.ContainerLogs("c1", docker.LogOptions{Follow: true, Tail: 20}, &out); err != nil {
+		t.Fatalf("ContainerLogs: %v", err)
+	}
+	if !fake.Ran("podman logs --follow --tail 20 c1") {
+		t.Errorf("commands = %v", fake.Commands())
+	}
+	if out.String() != "line\n" {
+		t.Errorf("output = %q", out.String())
+	}
+}
@@ -1,91 +1,384 @@
 package docker
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	assets "github.com/claudeup/claudeup-lab/embed"
+	"github.com/claudeup/claudeup-lab/internal/runner"
 )
 
 const DefaultImage = "ghcr.io/claudeup/claudeup-lab:latest"
 
+// AssetsLabel is the label holding AssetsHash on images built from the
+// embedded Dockerfile.
+const AssetsLabel = "dev.claudeup.lab.assets"
+
 // ImageManager handles pulling and building the base container image.
-type ImageManager struct{}
+type ImageManager struct {
+	bin string
+	run runner.Runner
+	out io.Writer
+}
 
 func NewImageManager() *ImageManager {
-	return &ImageManager{}
+	return NewImageManagerFor(RuntimeDocker, runner.Default)
+}
+
+// NewImageManagerFor returns an ImageManager that runs the named runtime's
+// CLI with r.
+func NewImageManagerFor(runtime string, r runner.Runner) *ImageManager {
+	return &ImageManager{bin: runtime, run: r, out: os.Stdout}
+}
+
+// SetProgress sends pull and build output to w instead of stdout.
+func (im *ImageManager) SetProgress(w io.Writer) {
+	im.out = w
 }
 
 func (im *ImageManager) ExistsLocally(image string) bool {
-	cmd := exec.Command("docker", "image", "inspect", image)
-	cmd.Stdout = nil
-	cmd.Stderr = nil
-	return cmd.Run() == nil
+	return runner.Run(im.run, im.bin, "image", "inspect", image) == nil
+}
+
+// Digest returns the registry digest (sha256:...) of a local image, or ""
+// for an image that was built locally and never pushed or pulled.
+func (im *ImageManager) Digest(image string) (string, error) {
+	out, err := runner.Output(im.run, im.bin, "image", "inspect", "--format", "{{json .RepoDigests}}", image)
+	if err != nil {
+		return "", fmt.Errorf("%s image inspect %s: %w", im.bin, image, err)
+	}
+	var digests []string
+	if err := json.Unmarshal(out, &digests); err != nil {
+		return "", fmt.Errorf("parse %s image inspect output: %w", im.bin, err)
+	}
+	for _, d := range digests {
+		if _, digest, ok := strings.Cut(d, "@"); ok {
+			return digest, nil
+		}
+	}
+	return "", nil
+}
+
+// ID returns the local ID (sha256:...) of an image.
+func (im *ImageManager) ID(image string) (string, error) {
+	out, err := runner.Output(im.run, im.bin, "image", "inspect", "--format", "{{.Id}}", image)
+	if err != nil {
+		return "", fmt.Errorf("%s image inspect %s: %w", im.bin, image, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Labels returns the labels of a local image.
+func (im *ImageManager) Labels(image string) (map[string]string, error) {
+	out, err := runner.Output(im.run, im.bin, "image", "inspect", "--format", "{{json .Config.Labels}}", image)
+	if err != nil {
+		return nil, fmt.Errorf("%s image inspect %s: %w", im.bin, image, err)
+	}
+	var labels map[string]string
+	if err := json.Unmarshal(out, &labels); err != nil {
+		return nil, fmt.Errorf("parse %s image inspect output: %w", im.bin, err)
+	}
+	return labels, nil
+}
+
+// Stale reports whether a local image was built from embedded assets other
+// than this binary's. Pulled images carry no assets label and are never
+// stale.
+func (im *ImageManager) Stale(image string) (bool, error) {
+	labels, err := im.Labels(image)
+	if err != nil {
+		return false, err
+	}
+	hash, ok := labels[AssetsLabel]
+	return ok && hash != AssetsHash(), nil
 }
 
 // EnsureImage pulls the image from the registry, falling back to building
-// from the embedded Dockerfile if the pull fails.
+// from the embedded Dockerfile if the pull fails. An image pinned by digest
+// cannot be rebuilt, so failing to pull it is an error. A local image built
+// from older embedded assets is rebuilt.
 func (im *ImageManager) EnsureImage(image string) error {
 	if im.ExistsLocally(image) {
-		return nil
+		if stale, err := im.Stale(image); err != nil || !stale {
+			return nil
+		}
+		fmt.Fprintf(im.out, "Image %s was built by another version of claudeup-lab, rebuilding...\n", image)
+		return im.Build(image, false)
 	}
 
-	fmt.Printf("Pulling image %s...\n", image)
-	if err := im.pull(image); err != nil {
-		fmt.Printf("Pull failed (%v), building from embedded Dockerfile...\n", err)
+	fmt.Fprintf(im.out, "Pulling image %s...\n", image)
+	if err := im.Pull(image); err != nil {
+		if IsDigestRef(image) {
+			return err
+		}
+		fmt.Fprintf(im.out, "Pull failed (%v), building from embedded Dockerfile...\n", err)
 		return im.buildFallback(image)
 	}
 
 	return nil
 }
 
-func (im *ImageManager) pull(image string) error {
-	cmd := exec.Command("docker", "pull", image)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// EnsureLocalImage is EnsureImage without the network: the image must
+// already be present, and one built from older embedded assets is used as
+// it is.
+func (im *ImageManager) EnsureLocalImage(image string) error {
+	if !im.ExistsLocally(image) {
+		return fmt.Errorf("image %s is not available locally", image)
+	}
+	if stale, _ := im.Stale(image); stale {
+		fmt.Fprintf(im.out, "Image %s was built by another version of claudeup-lab; using it as is\n", image)
+	}
+	return nil
+}
+
+// Pull pulls an image from its registry.
+func (im *ImageManager) Pull(image string) error {
+	err := im.run.Run(&runner.Cmd{
+		Name: im.bin, Args: []string{"pull", image},
+		Stdout: im.out, Stderr: os.Stderr,
+	})
+	if err != nil {
+		return fmt.Errorf("%s pull %s: %w", im.bin, image, err)
+	}
+	return nil
 }
 
 func (im *ImageManager) buildFallback(tag string) error {
+	return im.Build(tag, false)
+}
+
+// Build builds the base image from the embedded Dockerfile and tags it;
+// noCache rebuilds every layer.
+func (im *ImageManager) Build(tag string, noCache bool) error {
+	if IsDigestRef(tag) {
+		return fmt.Errorf("cannot build %s: a digest names a published image", tag)
+	}
 	dir, err := os.MkdirTemp("", "claudeup-lab-build-*")
 	if err != nil {
 		return fmt.Errorf("create temp dir: %w", err)
 	}
 	defer os.RemoveAll(dir)
 
-	files := map[string][]byte{
+	for name, content := range buildContext() {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0o755); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+
+	args := []string{"build", "-t", tag, "--label", AssetsLabel + "=" + AssetsHash()}
+	if noCache {
+		args = append(args, "--no-cache")
+	}
+	fmt.Fprintln(im.out, "Building image from embedded Dockerfile...")
+	err = im.run.Run(&runner.Cmd{
+		Name: im.bin, Args: append(args, dir),
+		Stdout: im.out, Stderr: os.Stderr,
+	})
+	if err != nil {
+		return fmt.Errorf("%s build: %w", im.bin, err)
+	}
+
+	return nil
+}
+
+// buildContext returns the files the base image is built from.
+func buildContext() map[string][]byte {
+	return map[string][]byte{
 		"Dockerfile":            assets.Dockerfile,
 		"init-claude-config.sh": assets.InitClaudeConfig,
 		"init-config-repo.sh":   assets.InitConfigRepo,
 		"init-claudeup.sh":      assets.InitClaudeup,
 	}
-	for name, content := range files {
-		path := filepath.Join(dir, name)
-		if err := os.WriteFile(path, content, 0o755); err != nil {
-			return fmt.Errorf("write %s: %w", name, err)
+}
+
+// AssetsHash identifies the build context embedded in this binary.
+func AssetsHash() string {
+	files := buildContext()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(files[name]))
+		h.Write(files[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// LayerRepository holds the images built from project Dockerfiles on top of
+// the base image.
+const LayerRepository = "claudeup-lab-project"
+
+// BaseImageArg is the build argument a project Dockerfile names the base
+// image with: it must start FROM ${CLAUDEUP_LAB_IMAGE}.
+const BaseImageArg = "CLAUDEUP_LAB_IMAGE"
+
+// LayerTag names the image built from a project Dockerfile on a base image,
+// identified by its digest.
+func LayerTag(dockerfile []byte, baseDigest string) string {
+	h := sha256.New()
+	h.Write(dockerfile)
+	fmt.Fprintf(h, "\x00%s", baseDigest)
+	return LayerRepository + ":" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// checkLayerFrom checks that a project Dockerfile builds on the base image
+// passed in BaseImageArg.
+func checkLayerFrom(dockerfile []byte) error {
+	declared := false
+	for _, line := range strings.Split(string(dockerfile), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
 		}
+		switch strings.ToUpper(fields[0]) {
+		case "ARG":
+			name, _, _ := strings.Cut(fields[1], "=")
+			declared = declared || name == BaseImageArg
+		case "FROM":
+			from := fields[1]
+			for i := 2; strings.HasPrefix(from, "--") && i < len(fields); i++ {
+				from = fields[i]
+			}
+			if from != "${"+BaseImageArg+"}" && from != "$"+BaseImageArg {
+				return fmt.Errorf("must start FROM ${%s}, the lab base image (found FROM %s)", BaseImageArg, from)
+			}
+			if !declared {
+				return fmt.Errorf("must declare ARG %s before FROM", BaseImageArg)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("has no FROM instruction")
+}
+
+// BuildLayer builds a project Dockerfile on top of base, whose digest is
+// baseDigest, with contextDir as the build context. An image built earlier
+// from the same file and base is reused. It returns the image's tag.
+func (im *ImageManager) BuildLayer(base, baseDigest, dockerfile, contextDir string) (string, error) {
+	content, err := os.ReadFile(dockerfile)
+	if err != nil {
+		return "", fmt.Errorf("read project Dockerfile: %w", err)
+	}
+	if err := checkLayerFrom(content); err != nil {
+		return "", fmt.Errorf("%s %w", dockerfile, err)
 	}
+	if baseDigest == "" {
+		baseDigest = base
+	}
+	tag := LayerTag(content, baseDigest)
+	if im.ExistsLocally(tag) {
+		return tag, nil
+	}
+
+	fmt.Fprintf(im.out, "Building %s on top of %s...\n", filepath.Base(dockerfile), base)
+	err = im.run.Run(&runner.Cmd{
+		Name:   im.bin,
+		Args:   []string{"build", "-f", dockerfile, "-t", tag, "--build-arg", BaseImageArg + "=" + base, contextDir},
+		Stdout: im.out, Stderr: os.Stderr,
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s build %s: %w", im.bin, dockerfile, err)
+	}
+	return tag, nil
+}
+
+// Image is a local image as listed by the runtime.
+type Image struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag,omitempty"`
+	Digest     string `json:"digest,omitempty"` // registry digest, for pulled images
+	ID         string `json:"id"`
+	Created    string `json:"created"` // as the runtime reports it, e.g. "3 days ago"
+	Size       int64  `json:"size"`
+}
+
+// Ref returns a reference to the image: repository:tag, or
+// repository@digest for an untagged image.
+func (i Image) Ref() string {
+	if i.Tag != "" {
+		return i.Repository + ":" + i.Tag
+	}
+	if i.Digest != "" {
+		return i.Repository + "@" + i.Digest
+	}
+	return i.ID
+}
 
-	fmt.Println("Building image from embedded Dockerfile...")
-	cmd := exec.Command("docker", "build", "-t", tag, dir)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("docker build: %w", err)
+// List returns the local images of the given repositories.
+func (im *ImageManager) List(repositories ...string) ([]Image, error) {
+	var images []Image
+	for _, repo := range repositories {
+		out, err := runner.Output(im.run, im.bin, "image", "ls", "--digests", "--no-trunc",
+			"--format", "{{.Repository}}\t{{.Tag}}\t{{.Digest}}\t{{.ID}}\t{{.CreatedSince}}\t{{.Size}}", repo)
+		if err != nil {
+			return nil, fmt.Errorf("%s image ls: %w", im.bin, err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			fields := strings.Split(line, "\t")
+			if len(fields) != 6 {
+				continue
+			}
+			img := Image{Repository: fields[0], Tag: fields[1], Digest: fields[2], ID: fields[3], Created: fields[4]}
+			if img.Tag == "<none>" {
+				img.Tag = ""
+			}
+			if img.Digest == "<none>" {
+				img.Digest = ""
+			}
+			img.Size, _ = parseSize(strings.ReplaceAll(fields[5], " ", ""))
+			images = append(images, img)
+		}
 	}
+	return images, nil
+}
 
+// Remove deletes local images.
+func (im *ImageManager) Remove(refs []string) error {
+	if len(refs) == 0 {
+		return nil
+	}
+	if err := runner.Run(im.run, im.bin, append([]string{"image", "rm"}, refs...)...); err != nil {
+		return fmt.Errorf("%s image rm: %w", im.bin, err)
+	}
 	return nil
 }
 
-// ImageTag returns the configured image tag, allowing override via
-// the CLAUDEUP_LAB_IMAGE environment variable.
-func ImageTag() string {
-	tag := os.Getenv("CLAUDEUP_LAB_IMAGE")
-	if tag != "" {
-		return strings.TrimSpace(tag)
+// Repository returns the repository part of an image reference, without
+// its tag or digest.
+func Repository(ref string) string {
+	ref, _, _ = strings.Cut(ref, "@")
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
 	}
-	return DefaultImage
+	return ref
+}
+
+// RegistryHost returns the host:port of the registry an image reference
+// pulls from.
+func RegistryHost(ref string) string {
+	host, _, found := strings.Cut(ref, "/")
+	if !found || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		return "registry-1.docker.io:443"
+	}
+	if !strings.Contains(host, ":") {
+		host += ":443"
+	}
+	return host
+}
+
+// IsDigestRef reports whether an image reference names a digest.
+func IsDigestRef(ref string) bool {
+	return strings.Contains(ref, "@sha256:")
 }
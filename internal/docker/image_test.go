@@ -1,9 +1,14 @@
 package docker_test
 
 import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/claudeup/claudeup-lab/internal/docker"
+	"github.com/claudeup/claudeup-lab/internal/runner"
 )
 
 func TestImageExistsLocally(t *testing.T) {
@@ -20,3 +25,112 @@ func TestImageNameConstants(t *testing.T) {
 		t.Error("DefaultImage should not be empty")
 	}
 }
+
+func TestListImages(t *testing.T) {
+	fake := runner.NewFake()
+	fake.On("docker", "image", "ls").Return(
+		"ghcr.io/claudeup/claudeup-lab\tlatest\tsha256:feed\tsha256:0123456789abcdef\t3 days ago\t1.2GB\n"+
+			"ghcr.io/claudeup/claudeup-lab\t<none>\tsha256:beef\tsha256:fedcba9876543210\t2 weeks ago\t980 MB\n", nil)
+
+	im := docker.NewImageManagerFor(docker.RuntimeDocker, fake)
+	images, err := im.List("ghcr.io/claudeup/claudeup-lab")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(images) != 2 {
+		t.Fatalf("List = %+v, want 2 images", images)
+	}
+	if images[0].Ref() != "ghcr.io/claudeup/claudeup-lab:latest" || images[0].Size != 1200000000 || images[0].Created != "3 days ago" {
+		t.Errorf("images[0] = %+v", images[0])
+	}
+	if images[1].Ref() != "ghcr.io/claudeup/claudeup-lab@sha256:beef" || images[1].Size != 980000000 {
+		t.Errorf("images[1] = %+v", images[1])
+	}
+}
+
+func TestImageReferences(t *testing.T) {
+	for ref, want := range map[string]string{
+		"ghcr.io/claudeup/claudeup-lab:latest":      "ghcr.io/claudeup/claudeup-lab",
+		"ghcr.io/claudeup/claudeup-lab@sha256:feed": "ghcr.io/claudeup/claudeup-lab",
+		"localhost:5000/lab:v2":                     "localhost:5000/lab",
+		"localhost:5000/lab":                        "localhost:5000/lab",
+	} {
+		if got := docker.Repository(ref); got != want {
+			t.Errorf("Repository(%q) = %q, want %q", ref, got, want)
+		}
+	}
+	for ref, want := range map[string]string{
+		"ghcr.io/claudeup/claudeup-lab:latest": "ghcr.io:443",
+		"localhost:5000/lab:v2":                "localhost:5000",
+		"node:22":                              "registry-1.docker.io:443",
+		"library/node":                         "registry-1.docker.io:443",
+	} {
+		if got := docker.RegistryHost(ref); got != want {
+			t.Errorf("RegistryHost(%q) = %q, want %q", ref, got, want)
+		}
+	}
+	if !docker.IsDigestRef("lab@sha256:feed") || docker.IsDigestRef("lab:latest") {
+		t.Error("IsDigestRef misclassified a reference")
+	}
+
+	fake := runner.NewFake()
+	im := docker.NewImageManagerFor(docker.RuntimeDocker, fake)
+	if err := im.Build("lab@sha256:feed", false); err == nil {
+		t.Error("building a digest reference should fail")
+	}
+	if len(fake.Commands()) != 0 {
+		t.Errorf("commands = %v", fake.Commands())
+	}
+}
+
+func TestBuildLayer(t *testing.T) {
+	dir := t.TempDir()
+	dockerfile := filepath.Join(dir, "Dockerfile.lab")
+
+	fake := runner.NewFake()
+	fake.On("docker", "image", "inspect").Fail(1, "No such image")
+	fake.On("docker", "build")
+	im := docker.NewImageManagerFor(docker.RuntimeDocker, fake)
+	im.SetProgress(io.Discard)
+
+	for _, bad := range []string{
+		"FROM ubuntu:24.04\n",
+		"FROM ${CLAUDEUP_LAB_IMAGE}\n",
+		"ARG CLAUDEUP_LAB_IMAGE\nRUN true\n",
+	} {
+		os.WriteFile(dockerfile, []byte(bad), 0o644)
+		if _, err := im.BuildLayer("lab:latest", "sha256:feed", dockerfile, dir); err == nil {
+			t.Errorf("BuildLayer(%q) should fail", bad)
+		}
+	}
+	if fake.Ran("docker build") {
+		t.Fatalf("nothing should be built from an invalid Dockerfile: %v", fake.Commands())
+	}
+
+	content := []byte("# syntax=docker/dockerfile:1\nARG CLAUDEUP_LAB_IMAGE\nFROM --platform=linux/amd64 ${CLAUDEUP_LAB_IMAGE}\nRUN apt-get install -y jq\n")
+	os.WriteFile(dockerfile, content, 0o644)
+	tag, err := im.BuildLayer("lab:latest", "sha256:feed", dockerfile, dir)
+	if err != nil {
+		t.Fatalf("BuildLayer: %v", err)
+	}
+	if tag != docker.LayerTag(content, "sha256:feed") || !strings.HasPrefix(tag, docker.LayerRepository+":") {
+		t.Errorf("tag = %q", tag)
+	}
+	if !fake.Ran("docker build -f " + dockerfile + " -t " + tag + " --build-arg CLAUDEUP_LAB_IMAGE=lab:latest " + dir) {
+		t.Errorf("commands = %v", fake.Commands())
+	}
+	if tag == docker.LayerTag(content, "sha256:beef") {
+		t.Error("a new base digest should give a new tag")
+	}
+
+	fake.On("docker", "image", "inspect")
+	builds := len(fake.Commands())
+	if _, err := im.BuildLayer("lab:latest", "sha256:feed", dockerfile, dir); err != nil {
+		t.Fatalf("BuildLayer (cached): %v", err)
+	}
+	for _, c := range fake.Commands()[builds:] {
+		if strings.HasPrefix(c, "docker build") {
+			t.Errorf("cached layer should not be rebuilt: %v", fake.Commands())
+		}
+	}
+}
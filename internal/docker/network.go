@@ -0,0 +1,115 @@
+package docker
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/claudeup/claudeup-lab/internal/runner"
+)
+
+// labIDLabel marks the networks and helper containers that belong to a lab.
+const labIDLabel = "claudeup-lab.id"
+
+// NetworkManager handles the private networks and egress proxy containers
+// of labs with restricted network access.
+type NetworkManager struct {
+	bin string
+	run runner.Runner
+}
+
+// NewNetworkManagerFor returns a NetworkManager that runs the named
+// runtime's CLI with r.
+func NewNetworkManagerFor(runtime string, r runner.Runner) *NetworkManager {
+	return &NetworkManager{bin: runtime, run: r}
+}
+
+// LabNetwork returns the name of a lab's private network.
+func LabNetwork(labID string) string {
+	return "claudeup-lab-net-" + labID
+}
+
+// ProxyContainer returns the name of a lab's egress proxy container.
+func ProxyContainer(labID string) string {
+	return "claudeup-lab-proxy-" + labID
+}
+
+// NetworkExists reports whether a network exists.
+func (n *NetworkManager) NetworkExists(name string) bool {
+	return runner.Run(n.run, n.bin, "network", "inspect", name) == nil
+}
+
+// CreateInternalNetwork creates a network with no route outside the host,
+// labelled with the lab it belongs to.
+func (n *NetworkManager) CreateInternalNetwork(name, labID string) error {
+	if err := runner.Run(n.run, n.bin, "network", "create", "--internal", "--label", labIDLabel+"="+labID, name); err != nil {
+		return fmt.Errorf("%s network create %s: %w", n.bin, name, err)
+	}
+	return nil
+}
+
+// RemoveNetwork removes a network. A network that does not exist is not
+// an error.
+func (n *NetworkManager) RemoveNetwork(name string) error {
+	if !n.NetworkExists(name) {
+		return nil
+	}
+	if err := runner.Run(n.run, n.bin, "network", "rm", name); err != nil {
+		return fmt.Errorf("%s network rm %s: %w", n.bin, name, err)
+	}
+	return nil
+}
+
+// Connect attaches a container to a network.
+func (n *NetworkManager) Connect(network, container string) error {
+	if err := runner.Run(n.run, n.bin, "network", "connect", network, container); err != nil {
+		return fmt.Errorf("%s network connect %s: %w", n.bin, network, err)
+	}
+	return nil
+}
+
+// ProxySpec describes an egress proxy container: a Node script run from
+// image with the given environment.
+type ProxySpec struct {
+	Name   string
+	LabID  string
+	Image  string
+	Script string
+	Env    map[string]string
+}
+
+// RunProxy creates and starts a proxy container on the runtime's default
+// network, from where it can reach the outside.
+func (n *NetworkManager) RunProxy(spec ProxySpec) error {
+	args := []string{"run", "--detach",
+		"--name", spec.Name,
+		"--label", labIDLabel + "=" + spec.LabID,
+		"--user", "node",
+		"--entrypoint", "node",
+	}
+	for _, k := range sortedKeys(spec.Env) {
+		args = append(args, "--env", k+"="+spec.Env[k])
+	}
+	args = append(args, spec.Image, "-e", spec.Script)
+
+	if err := runner.Run(n.run, n.bin, args...); err != nil {
+		return fmt.Errorf("%s run %s: %w", n.bin, spec.Name, err)
+	}
+	return nil
+}
+
+// StartContainer starts an existing, stopped container.
+func (n *NetworkManager) StartContainer(name string) error {
+	if err := runner.Run(n.run, n.bin, "start", name); err != nil {
+		return fmt.Errorf("%s start %s: %w", n.bin, name, err)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
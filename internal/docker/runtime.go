@@ -0,0 +1,245 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/claudeup/claudeup-lab/internal/runner"
+)
+
+// Runtime is the set of container engine operations claudeup-lab needs.
+// Client implements it with the docker CLI and APIClient with the Engine
+// HTTP API.
+type Runtime interface {
+	IsRunning() bool
+
+	// ListContainers returns the devcontainers labelled with the given
+	// worktree path, most recently created first. Stopped containers are
+	// included when all is true.
+	ListContainers(worktreePath string, all bool) ([]Container, error)
+
+	// FindContainer returns the ID of the running container for a worktree,
+	// or empty string if none is running.
+	FindContainer(worktreePath string) (string, error)
+
+	// FindContainerIncludingStopped is FindContainer including stopped
+	// containers.
+	FindContainerIncludingStopped(worktreePath string) (string, error)
+
+	InspectContainer(id string) (*Container, error)
+	StopContainer(id string) error
+	RemoveContainer(id string) error
+
+	// ListVolumes returns the per-lab volumes (claudeup-lab-<kind>-<labID>)
+	// belonging to a lab.
+	ListVolumes(labID string) ([]Volume, error)
+	RemoveVolumes(names []string) error
+
+	// VolumeSizes returns the disk usage in bytes of the named volumes.
+	// Volumes the runtime cannot measure are left out.
+	VolumeSizes(names []string) (map[string]int64, error)
+
+	// ContainerHostname returns the hostname of the running devcontainer
+	// for a worktree.
+	ContainerHostname(worktreePath string) (string, error)
+
+	// UpdateLimits changes the resource limits of an existing container.
+	UpdateLimits(id string, limits Limits) error
+
+	// ContainerLogs copies a container's stdout and stderr to w. With
+	// opts.Follow it keeps streaming until the container stops.
+	ContainerLogs(id string, opts LogOptions, w io.Writer) error
+}
+
+// Limits are container resource limits. Zero fields are left as they are;
+// a negative PidsLimit lifts the PID limit.
+type Limits struct {
+	CPUs      float64
+	Memory    int64 // bytes
+	PidsLimit int64
+}
+
+// LogOptions selects which container log lines to show.
+type LogOptions struct {
+	Follow     bool
+	Since      time.Time // zero means from the start
+	Tail       int       // last N lines; 0 or less means all
+	Timestamps bool
+}
+
+// Container describes a container as reported by the engine. Fields only
+// available from inspect (Hostname, StartedAt) are empty in list results.
+type Container struct {
+	ID        string
+	Name      string
+	Image     string
+	ImageID   string
+	State     string
+	Status    string
+	Hostname  string
+	TTY       bool
+	Labels    map[string]string
+	Created   time.Time
+	StartedAt time.Time
+}
+
+// Running reports whether the container is in the running state.
+func (c *Container) Running() bool {
+	return c.State == "running"
+}
+
+// Volume describes a named volume.
+type Volume struct {
+	Name       string
+	Driver     string
+	Mountpoint string
+	Labels     map[string]string
+}
+
+// VolumeNames returns the names of the given volumes.
+func VolumeNames(volumes []Volume) []string {
+	names := make([]string, len(volumes))
+	for i, v := range volumes {
+		names[i] = v.Name
+	}
+	return names
+}
+
+// ErrNotFound matches engine errors for a container or volume that does
+// not exist.
+var ErrNotFound = errors.New("not found")
+
+// APIError is an error response from the Engine API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("docker API: %s (status %d)", e.Message, e.StatusCode)
+}
+
+func (e *APIError) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == 404
+}
+
+// worktreeLabel is the label the devcontainer CLI puts on the containers it
+// creates, pointing back at the workspace folder on the host.
+const worktreeLabel = "devcontainer.local_folder"
+
+// volumePrefix starts the name of every per-lab volume.
+const volumePrefix = "claudeup-lab-"
+
+// IsLabVolume reports whether a volume name is one of the per-lab volumes
+// (claudeup-lab-<kind>-<labID>) of the given lab.
+func IsLabVolume(name, labID string) bool {
+	if labID == "" || !strings.HasPrefix(name, volumePrefix) || !strings.HasSuffix(name, "-"+labID) {
+		return false
+	}
+	kind := strings.TrimSuffix(strings.TrimPrefix(name, volumePrefix), "-"+labID)
+	return kind != "" && !strings.Contains(kind, "-")
+}
+
+// Supported container runtimes.
+const (
+	RuntimeDocker = "docker"
+	RuntimePodman = "podman"
+)
+
+// SelectRuntime picks the container runtime: CLAUDEUP_LAB_RUNTIME wins over
+// the configured value, and docker is the default. An unknown name is an
+// error; docker is returned alongside it so callers can still proceed.
+func SelectRuntime(configured string) (string, error) {
+	name := strings.TrimSpace(os.Getenv("CLAUDEUP_LAB_RUNTIME"))
+	if name == "" {
+		name = strings.TrimSpace(configured)
+	}
+	switch name {
+	case "", RuntimeDocker:
+		return RuntimeDocker, nil
+	case RuntimePodman:
+		return RuntimePodman, nil
+	}
+	return RuntimeDocker, fmt.Errorf("unknown container runtime %q (supported: docker, podman)", name)
+}
+
+// NewRuntime returns a Runtime for the named container runtime. With
+// CLAUDEUP_LAB_TRANSPORT=api it talks to the runtime's Engine API socket
+// (podman serves a Docker-compatible one); otherwise it drives the CLI
+// with r.
+func NewRuntime(runtime string, r runner.Runner) Runtime {
+	if strings.TrimSpace(os.Getenv("CLAUDEUP_LAB_TRANSPORT")) == "api" {
+		return NewAPIClient(SocketPath(runtime))
+	}
+	return NewClientFor(runtime, r)
+}
+
+func firstID(containers []Container, err error) (string, error) {
+	if err != nil || len(containers) == 0 {
+		return "", err
+	}
+	return containers[0].ID, nil
+}
+
+// containerHostname looks up the hostname of a worktree's running
+// devcontainer from its inspect data.
+func containerHostname(rt Runtime, worktreePath string) (string, error) {
+	id, err := rt.FindContainer(worktreePath)
+	if err != nil {
+		return "", fmt.Errorf("get container hostname: %w", err)
+	}
+	if id == "" {
+		return "", fmt.Errorf("get container hostname: no running container for %s", worktreePath)
+	}
+	ctr, err := rt.InspectContainer(id)
+	if err != nil {
+		return "", fmt.Errorf("get container hostname: %w", err)
+	}
+	return ctr.Hostname, nil
+}
+
+// volumeUnits are the decimal units the docker CLI prints sizes in.
+var volumeUnits = map[string]float64{
+	"B": 1, "KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12, "PB": 1e15,
+}
+
+// parseSize parses a human-readable size such as "12.5MB" or "0B".
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.LastIndexAny(s, "0123456789.") + 1
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse size %q: %w", s, err)
+	}
+	unit, ok := volumeUnits[strings.ToUpper(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("parse size %q: unknown unit", s)
+	}
+	return int64(n * unit), nil
+}
+
+// dirSize adds up the sizes of the regular files under dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,54 @@
+package docker_test
+
+import (
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/docker"
+)
+
+func TestSelectRuntime(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        string
+		configured string
+		want       string
+		wantErr    bool
+	}{
+		{"default", "", "", "docker", false},
+		{"config", "", "podman", "podman", false},
+		{"env wins over config", "docker", "podman", "docker", false},
+		{"env only", "podman", "", "podman", false},
+		{"unknown", "", "containerd", "docker", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CLAUDEUP_LAB_RUNTIME", tt.env)
+			got, err := docker.SelectRuntime(tt.configured)
+			if got != tt.want {
+				t.Errorf("SelectRuntime = %q, want %q", got, tt.want)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSocketPath(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("CONTAINER_HOST", "")
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+
+	if got := docker.SocketPath(docker.RuntimeDocker); got != "/var/run/docker.sock" {
+		t.Errorf("docker socket = %q", got)
+	}
+	if got := docker.SocketPath(docker.RuntimePodman); got != "/run/user/1000/podman/podman.sock" {
+		t.Errorf("podman socket = %q", got)
+	}
+
+	t.Setenv("CONTAINER_HOST", "unix:///tmp/podman.sock")
+	if got := docker.SocketPath(docker.RuntimePodman); got != "/tmp/podman.sock" {
+		t.Errorf("podman socket with CONTAINER_HOST = %q", got)
+	}
+}
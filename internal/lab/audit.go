@@ -0,0 +1,277 @@
+package lab
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Host paths a lab's default mounts and writable --mount paths expose, and
+// the hooks and config of its bare repo, are fingerprinted when it starts, under <baseDir>/audit, so audit can show
+// what changed on the host while the lab existed. Changes made by the host
+// itself -- Claude Code running outside the lab also writes ~/.claude.json
+// -- show up too; Writable tells whether the lab could have made them.
+
+// ErrNoFingerprint is returned by Audit for labs with no host fingerprint,
+// such as ones started before fingerprints were taken.
+var ErrNoFingerprint = errors.New("no host fingerprint")
+
+// Kinds of host changes.
+const (
+	ChangeCreated  = "created"
+	ChangeModified = "modified"
+	ChangeDeleted  = "deleted"
+)
+
+// AuditRoot is a host path a lab's mounts expose.
+type AuditRoot struct {
+	Mount    string `json:"mount"` // default mount name, "mount" for a lab's own, or "bare-repo"
+	Path     string `json:"path"`
+	Writable bool   `json:"writable"`
+}
+
+// fileFingerprint identifies a file's contents and permissions. Symlinks
+// are identified by their target.
+type fileFingerprint struct {
+	Mode   fs.FileMode `json:"mode"`
+	SHA256 string      `json:"sha256,omitempty"`
+	Link   string      `json:"link,omitempty"`
+}
+
+// hostFingerprint is the state of a lab's exposed host paths at start.
+type hostFingerprint struct {
+	Taken time.Time                  `json:"taken"`
+	Roots []AuditRoot                `json:"roots"`
+	Files map[string]fileFingerprint `json:"files"`
+}
+
+// HostChange is a host file that differs from its fingerprint.
+type HostChange struct {
+	Path     string `json:"path"`
+	Kind     string `json:"kind"` // ChangeCreated, ChangeModified or ChangeDeleted
+	Mount    string `json:"mount"`
+	Writable bool   `json:"writable"` // whether the lab could write to it
+}
+
+// AuditReport compares a lab's exposed host paths with their fingerprint.
+type AuditReport struct {
+	ID          string       `json:"id"`
+	DisplayName string       `json:"display_name"`
+	Since       time.Time    `json:"since"`
+	Roots       []AuditRoot  `json:"roots"`
+	Files       int          `json:"files"` // files checked
+	Changes     []HostChange `json:"changes"`
+}
+
+// auditPath returns where a lab's host fingerprint is kept.
+func (m *Manager) auditPath(labID string) string {
+	return filepath.Join(m.baseDir, "audit", labID+".json")
+}
+
+// removeAudit deletes a lab's host fingerprint, if any.
+func (m *Manager) removeAudit(labID string) error {
+	if err := os.Remove(m.auditPath(labID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove host fingerprint: %w", err)
+	}
+	return nil
+}
+
+// auditMountCustom names the roots of a lab's own --mount paths.
+const auditMountCustom = "mount"
+
+// auditMountBareRepo names the parts of a lab's writable bare repo that git
+// on the host reads too: its hooks and config can run code outside the lab.
+const auditMountBareRepo = "bare-repo"
+
+// auditRoots lists the host paths of a lab's applied default mounts and of
+// the mounts it added that it can write to. Read-only ones are left out:
+// the lab cannot change them, and they may be large.
+func (m *Manager) auditRoots(meta *Metadata) []AuditRoot {
+	var roots []AuditRoot
+	for _, mount := range m.Mounts(meta) {
+		switch {
+		case !mount.Applied:
+		case mount.Name != "":
+			roots = append(roots, AuditRoot{Mount: mount.Name, Path: mount.Source, Writable: !mount.ReadOnly})
+		case mount.Custom && !mount.ReadOnly:
+			roots = append(roots, AuditRoot{Mount: auditMountCustom, Path: mount.Source, Writable: true})
+		}
+	}
+	if meta.BareRepo != "" {
+		for _, name := range []string{"hooks", "config"} {
+			roots = append(roots, AuditRoot{Mount: auditMountBareRepo, Path: filepath.Join(meta.BareRepo, name), Writable: true})
+		}
+	}
+	return roots
+}
+
+// fingerprintHost records the current state of the host paths a lab
+// exposes, replacing any earlier fingerprint.
+func (m *Manager) fingerprintHost(meta *Metadata) error {
+	roots := m.auditRoots(meta)
+	files, err := fingerprintRoots(roots)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(&hostFingerprint{Taken: time.Now().UTC(), Roots: roots, Files: files}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := m.auditPath(meta.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create audit directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("write host fingerprint: %w", err)
+	}
+	return nil
+}
+
+// ensureFingerprint takes a fingerprint for a lab that has none.
+func (m *Manager) ensureFingerprint(meta *Metadata) error {
+	if _, err := os.Stat(m.auditPath(meta.ID)); err == nil {
+		return nil
+	}
+	return m.fingerprintHost(meta)
+}
+
+// Audit reports the host files under a lab's exposed paths that were
+// created, modified or deleted since it started.
+func (m *Manager) Audit(meta *Metadata) (*AuditReport, error) {
+	data, err := os.ReadFile(m.auditPath(meta.ID))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("lab %s: %w", meta.DisplayName, ErrNoFingerprint)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read host fingerprint: %w", err)
+	}
+	var before hostFingerprint
+	if err := json.Unmarshal(data, &before); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", m.auditPath(meta.ID), err)
+	}
+
+	after, err := fingerprintRoots(before.Roots)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AuditReport{
+		ID:          meta.ID,
+		DisplayName: meta.DisplayName,
+		Since:       before.Taken,
+		Roots:       before.Roots,
+		Files:       len(after),
+		Changes:     []HostChange{},
+	}
+	change := func(path, kind string) {
+		root := rootOf(before.Roots, path)
+		report.Changes = append(report.Changes, HostChange{Path: path, Kind: kind, Mount: root.Mount, Writable: root.Writable})
+	}
+	for path, fp := range after {
+		old, ok := before.Files[path]
+		switch {
+		case !ok:
+			change(path, ChangeCreated)
+		case old != fp:
+			change(path, ChangeModified)
+		}
+	}
+	for path := range before.Files {
+		if _, ok := after[path]; !ok {
+			change(path, ChangeDeleted)
+		}
+	}
+	sort.Slice(report.Changes, func(i, j int) bool { return report.Changes[i].Path < report.Changes[j].Path })
+	return report, nil
+}
+
+// rootOf returns the root a path was found under.
+func rootOf(roots []AuditRoot, path string) AuditRoot {
+	for _, r := range roots {
+		if path == r.Path || strings.HasPrefix(path, r.Path+string(filepath.Separator)) {
+			return r
+		}
+	}
+	return AuditRoot{}
+}
+
+// fingerprintRoots fingerprints every file under roots. Files that
+// disappear while they are read, and roots that do not exist, contribute
+// nothing.
+func fingerprintRoots(roots []AuditRoot) (map[string]fileFingerprint, error) {
+	files := make(map[string]fileFingerprint)
+	for _, root := range roots {
+		// WalkDir does not follow a symlinked root, such as a ~/.claude.json
+		// kept in a dotfiles repo. Walk what it points to, and record the
+		// files under the root's own path.
+		resolved, err := filepath.EvalSymlinks(root.Path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fingerprint %s: %w", root.Path, err)
+		}
+		err = filepath.WalkDir(resolved, func(path string, d fs.DirEntry, err error) error {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			fp, err := fingerprintFile(path, d)
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			files[root.Path+strings.TrimPrefix(path, resolved)] = fp
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fingerprint %s: %w", root.Path, err)
+		}
+	}
+	return files, nil
+}
+
+func fingerprintFile(path string, d fs.DirEntry) (fileFingerprint, error) {
+	info, err := d.Info()
+	if err != nil {
+		return fileFingerprint{}, err
+	}
+	fp := fileFingerprint{Mode: info.Mode()}
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		fp.Link, err = os.Readlink(path)
+		return fp, err
+	case !info.Mode().IsRegular():
+		// Sockets and pipes have no contents to compare
+		return fp, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fileFingerprint{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fileFingerprint{}, err
+	}
+	fp.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return fp, nil
+}
@@ -5,9 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
-	assets "github.com/claudeup/claudeup-lab/embed"
+	"github.com/claudeup/claudeup-lab/internal/docker"
 )
 
 // DevcontainerConfig holds all parameters needed to render a devcontainer.json.
@@ -22,17 +23,24 @@ type DevcontainerConfig struct {
 	ClaudeupHome string // Override for ~/.claudeup; empty falls back to HomeDir/.claudeup
 	GitUserName  string
 	GitUserEmail string
-	GitHubToken  string
-	Context7Key  string
 	ConfigRepo   string
 	ConfigBranch string
 	BaseProfile  string
 	Features     []string
-}
+	Registry     FeatureRegistry // resolves Features; nil means the built-in registry
+	Runtime      string          // Container runtime; "podman" adds userns and SELinux relabel options
+	Resources    Resources
+	Network      string // NetworkNone or NetworkAllowlist restrict access; anything else is full
+	Offline      bool   // provision without the network: no claude upgrade, no downloads
+	Ports        []PortMapping
+	Env          map[string]string // the lab's own variables, added to containerEnv
+
+	Isolation       string      // IsolationStrict leaves only the worktree and bare repo writable
+	NoDefaultMounts []string    // default mounts to leave out, by name
+	Mounts          []MountSpec // the lab's own bind mounts
 
-type featureEntry struct {
-	Feature        string `json:"feature"`
-	DefaultVersion string `json:"default_version"`
+	ClaudeVersion   string // Claude Code version to install; empty means upgrade to the latest
+	ClaudeupVersion string // claudeup version to install; empty means the image's, or the latest
 }
 
 // RenderDevcontainer writes a devcontainer.json into the .devcontainer/
@@ -43,7 +51,10 @@ func RenderDevcontainer(config *DevcontainerConfig, worktreePath string) error {
 		return fmt.Errorf("create .devcontainer: %w", err)
 	}
 
-	dc := buildDevcontainerJSON(config)
+	dc, err := buildDevcontainerJSON(config)
+	if err != nil {
+		return err
+	}
 
 	data, err := json.MarshalIndent(dc, "", "  ")
 	if err != nil {
@@ -58,21 +69,62 @@ func RenderDevcontainer(config *DevcontainerConfig, worktreePath string) error {
 	return nil
 }
 
-func buildDevcontainerJSON(config *DevcontainerConfig) map[string]interface{} {
-	mounts := buildMounts(config)
-	features := buildFeatures(config.Features)
+func buildDevcontainerJSON(config *DevcontainerConfig) (map[string]interface{}, error) {
+	registry := config.Registry
+	if registry == nil {
+		var err error
+		if registry, err = builtinFeatures(); err != nil {
+			return nil, err
+		}
+	}
+	features, err := registry.ResolveAll(config.Features)
+	if err != nil {
+		return nil, err
+	}
+	nodeOptions := fmt.Sprintf("--max-old-space-size=%d", config.Resources.nodeHeap())
 
-	env := map[string]string{
-		"CLAUDE_CONFIG_DIR":    "/home/node/.claude",
-		"CLAUDE_PROFILE":       config.Profile,
-		"NODE_OPTIONS":         "--max-old-space-size=4096",
-		"GIT_USER_NAME":        config.GitUserName,
-		"GIT_USER_EMAIL":       config.GitUserEmail,
-		"GITHUB_TOKEN":         config.GitHubToken,
-		"CONTEXT7_API_KEY":     config.Context7Key,
-		"CLAUDE_CONFIG_REPO":   config.ConfigRepo,
-		"CLAUDE_CONFIG_BRANCH": config.ConfigBranch,
-		"CLAUDE_BASE_PROFILE":  config.BaseProfile,
+	env := make(map[string]string)
+	for k, v := range config.Env {
+		env[k] = v
+	}
+	for k, v := range builtinEnv(config, nodeOptions) {
+		env[k] = v
+	}
+
+	install := "claude upgrade"
+	if config.ClaudeVersion != "" {
+		install = "claude install " + strings.TrimPrefix(config.ClaudeVersion, "v")
+	}
+	postCreate := install + " && /usr/local/bin/init-claude-config.sh && /usr/local/bin/init-config-repo.sh && /usr/local/bin/init-claudeup.sh"
+
+	var runArgs []string
+	switch config.Network {
+	case NetworkNone:
+		runArgs = append(runArgs, "--network=none")
+	case NetworkAllowlist:
+		// The private network is internal, so the proxy is the only way out
+		runArgs = append(runArgs, "--network="+docker.LabNetwork(config.ID))
+	}
+	if config.Network == NetworkNone || config.Offline {
+		// Nothing can be downloaded; keep the image's version, which has to
+		// be the pinned one
+		postCreate = strings.TrimPrefix(postCreate, install+" && ")
+		if config.ClaudeVersion != "" {
+			postCreate = claudeVersionCheck(config.ClaudeVersion) + " && " + postCreate
+		}
+	}
+
+	var mounts []string
+	for _, m := range planMounts(config) {
+		if !m.Applied {
+			continue
+		}
+		mounts = append(mounts, m.spec)
+		if m.Target == hostClaudeJSON {
+			// Strict labs work on a copy of ~/.claude.json in the config
+			// volume, made once so later changes in the lab survive
+			postCreate = seedClaudeJSON + " && " + postCreate
+		}
 	}
 
 	dc := map[string]interface{}{
@@ -82,12 +134,80 @@ func buildDevcontainerJSON(config *DevcontainerConfig) map[string]interface{} {
 		"remoteUser":        "node",
 		"mounts":            mounts,
 		"containerEnv":      env,
+		"remoteEnv":         map[string]string{"NODE_OPTIONS": nodeOptions},
 		"workspaceFolder":   fmt.Sprintf("/workspaces/%s", config.DisplayName),
-		"postCreateCommand": "claude upgrade && /usr/local/bin/init-claude-config.sh && /usr/local/bin/init-config-repo.sh && /usr/local/bin/init-claudeup.sh",
+		"postCreateCommand": postCreate,
 		"waitFor":           "postCreateCommand",
 	}
 
-	return dc
+	if config.Runtime == docker.RuntimePodman {
+		// Rootless podman maps the host user to root in the container by
+		// default; map it to node instead so bind-mounted files stay
+		// readable and anything the lab writes is owned by the host user.
+		runArgs = append(runArgs, "--userns=keep-id:uid=1000,gid=1000")
+	}
+	runArgs = append(runArgs, config.Resources.runArgs()...)
+	if len(runArgs) > 0 {
+		dc["runArgs"] = runArgs
+	}
+	if req := config.Resources.hostRequirements(); req != nil {
+		dc["hostRequirements"] = req
+	}
+
+	// Published ports are bound to the host's loopback interface when the
+	// container is created; all ports are listed for editors that forward
+	// them themselves.
+	var appPort []string
+	var forwardPorts []int
+	for _, p := range config.Ports {
+		if p.Published {
+			appPort = append(appPort, p.appPort())
+		}
+		forwardPorts = append(forwardPorts, p.Container)
+	}
+	if len(appPort) > 0 {
+		dc["appPort"] = appPort
+	}
+	if len(forwardPorts) > 0 {
+		dc["forwardPorts"] = forwardPorts
+	}
+
+	return dc, nil
+}
+
+// builtinEnv is the containerEnv claudeup-lab sets itself, which a lab's
+// own variables cannot override.
+func builtinEnv(config *DevcontainerConfig, nodeOptions string) map[string]string {
+	env := map[string]string{
+		"CLAUDE_CONFIG_DIR":    "/home/node/.claude",
+		"CLAUDE_PROFILE":       config.Profile,
+		"NODE_OPTIONS":         nodeOptions,
+		"GIT_USER_NAME":        config.GitUserName,
+		"GIT_USER_EMAIL":       config.GitUserEmail,
+		"CLAUDE_CONFIG_REPO":   config.ConfigRepo,
+		"CLAUDE_CONFIG_BRANCH": config.ConfigBranch,
+		"CLAUDE_BASE_PROFILE":  config.BaseProfile,
+	}
+	if config.Offline {
+		// The init scripts fail clearly instead of trying to download
+		env["CLAUDEUP_LAB_OFFLINE"] = "1"
+	}
+	if config.ClaudeVersion != "" {
+		// Keep the pinned version; Claude Code updates itself otherwise
+		env["DISABLE_AUTOUPDATER"] = "1"
+	}
+	if config.ClaudeupVersion != "" {
+		env["CLAUDEUP_VERSION"] = config.ClaudeupVersion
+	}
+	if config.Network == NetworkAllowlist {
+		proxy := proxyURL(config.ID)
+		for _, k := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+			env[k] = proxy
+		}
+		env["NO_PROXY"] = "localhost,127.0.0.1"
+		env["no_proxy"] = "localhost,127.0.0.1"
+	}
+	return env
 }
 
 // ClaudeupHome returns the claudeup home directory. It checks CLAUDEUP_HOME
@@ -109,85 +229,149 @@ func claudeupHomeFor(config *DevcontainerConfig) string {
 	return filepath.Join(config.HomeDir, ".claudeup")
 }
 
-func buildMounts(config *DevcontainerConfig) []string {
+// seedClaudeJSON copies the host's ~/.claude.json into a strict lab's
+// config volume, unless an earlier start already did, and points
+// ~/.claude.json at the copy.
+const seedClaudeJSON = "{ [ -e /home/node/.claude/.claude.json ] || cp " + hostClaudeJSON + " /home/node/.claude/.claude.json; } && ln -sfn /home/node/.claude/.claude.json /home/node/.claude.json"
+
+// Mount is one entry of a lab's mount plan.
+type Mount struct {
+	Type     string `json:"type"`           // "bind" or "volume"
+	Name     string `json:"name,omitempty"` // default host mounts only, as accepted by --no-default-mount
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+	Custom   bool   `json:"custom,omitempty"` // added with --mount
+	Applied  bool   `json:"applied"`
+	Skipped  string `json:"skipped,omitempty"` // why an optional mount was left out
+	Note     string `json:"note,omitempty"`
+
+	spec string // devcontainer.json mount string
+}
+
+// Spec returns the mount in devcontainer.json syntax.
+func (m Mount) Spec() string { return m.spec }
+
+func volumeMount(name, target string) Mount {
+	return Mount{
+		Type:    "volume",
+		Source:  name,
+		Target:  target,
+		Applied: true,
+		spec:    fmt.Sprintf("source=%s,target=%s,type=volume", name, target),
+	}
+}
+
+// planMounts lists every mount a lab can get, including optional bind
+// mounts that are skipped because their source does not exist on the host
+// or the lab's mount policy leaves them out.
+func planMounts(config *DevcontainerConfig) []Mount {
 	id := config.ID
 	home := config.HomeDir
 	cupHome := claudeupHomeFor(config)
+	strict := config.Isolation == IsolationStrict
 
-	mounts := []string{
-		fmt.Sprintf("source=claudeup-lab-bashhistory-%s,target=/commandhistory,type=volume", id),
-		fmt.Sprintf("source=claudeup-lab-config-%s,target=/home/node/.claude,type=volume", id),
-		fmt.Sprintf("source=claudeup-lab-claudeup-%s,target=/home/node/.claudeup,type=volume", id),
+	mounts := []Mount{
+		volumeMount("claudeup-lab-bashhistory-"+id, "/commandhistory"),
+		volumeMount("claudeup-lab-config-"+id, "/home/node/.claude"),
+		volumeMount("claudeup-lab-claudeup-"+id, "/home/node/.claudeup"),
+	}
+
+	// Under podman on an SELinux host, the repositories a lab works on are
+	// relabelled for the container to read them. Files from the host home
+	// are not: relabelling them would change the labels the host's own
+	// programs rely on, such as sshd reading ~/.ssh/authorized_keys. The
+	// lab's own mounts are relabelled only when asked for with :z.
+	relabel := config.Runtime == docker.RuntimePodman
+
+	bindOpts := func(readOnly, relabelled bool) string {
+		opts := "type=bind"
+		if readOnly {
+			opts += ",readonly"
+		}
+		if relabel && relabelled {
+			opts += ",relabel=shared"
+		}
+		return opts
 	}
 
 	// Optional bind mounts -- skip if source doesn't exist
 	optionalMounts := []struct {
-		source string
-		target string
-		opts   string
+		name     string
+		source   string
+		target   string
+		readOnly bool
 	}{
-		{filepath.Join(cupHome, "profiles"), "/home/node/.claudeup/profiles", "type=bind,readonly"},
-		{filepath.Join(cupHome, "ext"), "/home/node/.claudeup/ext", "type=bind,readonly"},
-		{filepath.Join(home, ".claude-mem"), "/home/node/.claude-mem", "type=bind"},
-		{filepath.Join(home, ".ssh"), "/home/node/.ssh", "type=bind,readonly"},
-		{filepath.Join(home, ".claude", "settings.json"), "/tmp/base-settings.json", "type=bind,readonly"},
-		{filepath.Join(home, ".claude.json"), "/home/node/.claude.json", "type=bind"},
+		{MountProfiles, filepath.Join(cupHome, "profiles"), "/home/node/.claudeup/profiles", true},
+		{MountExt, filepath.Join(cupHome, "ext"), "/home/node/.claudeup/ext", true},
+		{MountClaudeMem, filepath.Join(home, ".claude-mem"), "/home/node/.claude-mem", false},
+		{MountSSH, filepath.Join(home, ".ssh"), "/home/node/.ssh", true},
+		{MountSettings, filepath.Join(home, ".claude", "settings.json"), "/tmp/base-settings.json", true},
+		{MountClaudeJSON, filepath.Join(home, ".claude.json"), "/home/node/.claude.json", false},
 	}
 
 	for _, m := range optionalMounts {
-		if _, err := os.Stat(m.source); err == nil {
-			mounts = append(mounts, fmt.Sprintf("source=%s,target=%s,%s", m.source, m.target, m.opts))
+		var note string
+		if strict && m.name == MountClaudeJSON {
+			m.target, m.readOnly = hostClaudeJSON, true
+			note = "copied into claudeup-lab-config-" + id
 		}
+		mount := Mount{
+			Type:     "bind",
+			Name:     m.name,
+			Source:   m.source,
+			Target:   m.target,
+			ReadOnly: m.readOnly,
+			Optional: true,
+			Applied:  true,
+			Note:     note,
+			spec:     fmt.Sprintf("source=%s,target=%s,%s", m.source, m.target, bindOpts(m.readOnly, false)),
+		}
+		switch {
+		case slices.Contains(config.NoDefaultMounts, m.name):
+			mount.Applied = false
+			mount.Skipped = "disabled with --no-default-mount"
+		case strict && m.name == MountClaudeMem:
+			mount.Applied = false
+			mount.Skipped = "writable host directory, left out under strict isolation"
+		default:
+			if _, err := os.Stat(m.source); err != nil {
+				mount.Applied = false
+				mount.Skipped = "source does not exist"
+			}
+		}
+		mounts = append(mounts, mount)
+	}
+
+	// The lab's own bind mounts, checked when it was started
+	for _, m := range config.Mounts {
+		mounts = append(mounts, Mount{
+			Type:     "bind",
+			Source:   m.Source,
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly,
+			Custom:   true,
+			Applied:  true,
+			spec:     fmt.Sprintf("source=%s,target=%s,%s", m.Source, m.Target, bindOpts(m.ReadOnly, m.Relabel)),
+		})
 	}
 
 	// Bare repo bind mount (required for git worktree resolution)
-	mounts = append(mounts, fmt.Sprintf("source=%s,target=%s,type=bind", config.BareRepoPath, config.BareRepoPath))
+	mounts = append(mounts, Mount{
+		Type:    "bind",
+		Source:  config.BareRepoPath,
+		Target:  config.BareRepoPath,
+		Applied: true,
+		spec:    fmt.Sprintf("source=%s,target=%s,%s", config.BareRepoPath, config.BareRepoPath, bindOpts(false, true)),
+	})
 
 	// Per-lab volumes
 	mounts = append(mounts,
-		fmt.Sprintf("source=claudeup-lab-npm-%s,target=/home/node/.npm-global,type=volume", id),
-		fmt.Sprintf("source=claudeup-lab-local-%s,target=/home/node/.local,type=volume", id),
-		fmt.Sprintf("source=claudeup-lab-bun-%s,target=/home/node/.bun,type=volume", id),
+		volumeMount("claudeup-lab-npm-"+id, "/home/node/.npm-global"),
+		volumeMount("claudeup-lab-local-"+id, "/home/node/.local"),
+		volumeMount("claudeup-lab-bun-"+id, "/home/node/.bun"),
 	)
 
 	return mounts
 }
-
-func buildFeatures(specs []string) map[string]interface{} {
-	if len(specs) == 0 {
-		return map[string]interface{}{}
-	}
-
-	// Load feature registry
-	var registry map[string]featureEntry
-	if err := json.Unmarshal(assets.FeaturesJSON, &registry); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to parse feature registry: %v\n", err)
-		return map[string]interface{}{}
-	}
-
-	features := make(map[string]interface{})
-	for _, spec := range specs {
-		name, version := parseFeatureSpec(spec)
-
-		entry, ok := registry[name]
-		if !ok {
-			continue
-		}
-
-		if version == "" {
-			version = entry.DefaultVersion
-		}
-
-		features[entry.Feature] = map[string]string{"version": version}
-	}
-
-	return features
-}
-
-func parseFeatureSpec(spec string) (name, version string) {
-	idx := strings.IndexByte(spec, ':')
-	if idx >= 0 {
-		return spec[:idx], spec[idx+1:]
-	}
-	return spec, ""
-}
@@ -2,6 +2,7 @@ package lab_test
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -216,3 +217,260 @@ func TestFeatureInjection(t *testing.T) {
 		t.Error("should contain go feature")
 	}
 }
+
+func TestPodmanMountOptions(t *testing.T) {
+	dir := t.TempDir()
+	fakeHome := t.TempDir()
+	os.MkdirAll(filepath.Join(fakeHome, ".ssh"), 0o700)
+	os.MkdirAll(filepath.Join(fakeHome, ".claude-mem"), 0o755)
+
+	config := &lab.DevcontainerConfig{
+		ProjectName:  "myapp",
+		Profile:      "base",
+		ID:           "abc-123",
+		DisplayName:  "myapp-base",
+		Image:        "test:latest",
+		BareRepoPath: "/tmp/bare.git",
+		HomeDir:      fakeHome,
+		Runtime:      "podman",
+		Mounts: []lab.MountSpec{
+			{Source: "/srv/data", Target: "/mnt/data"},
+			{Source: "/srv/shared", Target: "/mnt/shared", Relabel: true},
+		},
+	}
+
+	if err := lab.RenderDevcontainer(config, dir); err != nil {
+		t.Fatalf("RenderDevcontainer: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, ".devcontainer", "devcontainer.json"))
+	var parsed struct {
+		Mounts  []string `json:"mounts"`
+		RunArgs []string `json:"runArgs"`
+	}
+	json.Unmarshal(data, &parsed)
+
+	if len(parsed.RunArgs) != 1 || !strings.HasPrefix(parsed.RunArgs[0], "--userns=keep-id") {
+		t.Errorf("podman labs should run with keep-id userns, got %v", parsed.RunArgs)
+	}
+
+	for _, m := range parsed.Mounts {
+		switch {
+		case strings.Contains(m, "source=/tmp/bare.git,"), strings.Contains(m, "source=/srv/shared,"):
+			if !strings.Contains(m, "relabel=shared") {
+				t.Errorf("bare repo and :z mounts should be relabelled under podman: %s", m)
+			}
+		case strings.Contains(m, "relabel"):
+			t.Errorf("only the bare repo and :z mounts should be relabelled: %s", m)
+		}
+	}
+}
+
+func TestDockerHasNoPodmanOptions(t *testing.T) {
+	dir := t.TempDir()
+	config := &lab.DevcontainerConfig{
+		ProjectName:  "myapp",
+		Profile:      "base",
+		ID:           "abc-123",
+		DisplayName:  "myapp-base",
+		Image:        "test:latest",
+		BareRepoPath: "/tmp/bare.git",
+		HomeDir:      t.TempDir(),
+	}
+
+	if err := lab.RenderDevcontainer(config, dir); err != nil {
+		t.Fatalf("RenderDevcontainer: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, ".devcontainer", "devcontainer.json"))
+	if strings.Contains(string(data), "relabel") || strings.Contains(string(data), "runArgs") {
+		t.Errorf("docker labs should not get podman options:\n%s", data)
+	}
+}
+
+func TestResourceLimits(t *testing.T) {
+	dir := t.TempDir()
+	config := &lab.DevcontainerConfig{
+		ProjectName:  "myapp",
+		Profile:      "base",
+		ID:           "abc-123",
+		DisplayName:  "myapp-base",
+		Image:        "test:latest",
+		BareRepoPath: "/tmp/bare.git",
+		HomeDir:      t.TempDir(),
+		Runtime:      "podman",
+		Resources:    lab.Resources{CPUs: 1.5, Memory: 4 << 30, PidsLimit: 256},
+	}
+
+	if err := lab.RenderDevcontainer(config, dir); err != nil {
+		t.Fatalf("RenderDevcontainer: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, ".devcontainer", "devcontainer.json"))
+	var parsed struct {
+		RunArgs          []string               `json:"runArgs"`
+		HostRequirements map[string]interface{} `json:"hostRequirements"`
+		ContainerEnv     map[string]string      `json:"containerEnv"`
+		RemoteEnv        map[string]string      `json:"remoteEnv"`
+	}
+	json.Unmarshal(data, &parsed)
+
+	want := "--userns=keep-id:uid=1000,gid=1000 --cpus=1.5 --memory=4294967296 --pids-limit=256"
+	if got := strings.Join(parsed.RunArgs, " "); got != want {
+		t.Errorf("runArgs = %q, want %q", got, want)
+	}
+	if parsed.HostRequirements["cpus"] != float64(2) || parsed.HostRequirements["memory"] != "4096mb" {
+		t.Errorf("hostRequirements = %v", parsed.HostRequirements)
+	}
+	// Three quarters of 4 GiB
+	if parsed.ContainerEnv["NODE_OPTIONS"] != "--max-old-space-size=3072" || parsed.RemoteEnv["NODE_OPTIONS"] != "--max-old-space-size=3072" {
+		t.Errorf("NODE_OPTIONS = %q / %q, want the heap to follow the memory limit",
+			parsed.ContainerEnv["NODE_OPTIONS"], parsed.RemoteEnv["NODE_OPTIONS"])
+	}
+}
+
+func TestNetworkModes(t *testing.T) {
+	render := func(network string) map[string]interface{} {
+		dir := t.TempDir()
+		config := &lab.DevcontainerConfig{
+			ProjectName:  "myapp",
+			Profile:      "base",
+			ID:           "abc-123",
+			DisplayName:  "myapp-base",
+			Image:        "test:latest",
+			BareRepoPath: "/tmp/bare.git",
+			HomeDir:      t.TempDir(),
+			Network:      network,
+		}
+		if err := lab.RenderDevcontainer(config, dir); err != nil {
+			t.Fatalf("RenderDevcontainer: %v", err)
+		}
+		data, _ := os.ReadFile(filepath.Join(dir, ".devcontainer", "devcontainer.json"))
+		var parsed map[string]interface{}
+		json.Unmarshal(data, &parsed)
+		return parsed
+	}
+
+	full := render(lab.NetworkFull)
+	if _, ok := full["runArgs"]; ok {
+		t.Errorf("full network should not add runArgs: %v", full["runArgs"])
+	}
+
+	none := render(lab.NetworkNone)
+	if fmt.Sprint(none["runArgs"]) != "[--network=none]" {
+		t.Errorf("none runArgs = %v", none["runArgs"])
+	}
+	if strings.Contains(none["postCreateCommand"].(string), "claude upgrade") {
+		t.Error("claude upgrade cannot work without a network")
+	}
+
+	allow := render(lab.NetworkAllowlist)
+	if fmt.Sprint(allow["runArgs"]) != "[--network=claudeup-lab-net-abc-123]" {
+		t.Errorf("allowlist runArgs = %v", allow["runArgs"])
+	}
+	env := allow["containerEnv"].(map[string]interface{})
+	if env["HTTPS_PROXY"] != "http://claudeup-lab-proxy-abc-123:3128" || env["http_proxy"] != env["HTTPS_PROXY"] {
+		t.Errorf("proxy env = %v", env)
+	}
+}
+
+func TestOfflineProvisioning(t *testing.T) {
+	dir := t.TempDir()
+	config := &lab.DevcontainerConfig{
+		ProjectName:  "myapp",
+		Profile:      "base",
+		ID:           "abc-123",
+		DisplayName:  "myapp-base",
+		Image:        "test:latest",
+		BareRepoPath: "/tmp/bare.git",
+		HomeDir:      t.TempDir(),
+		Offline:      true,
+	}
+	if err := lab.RenderDevcontainer(config, dir); err != nil {
+		t.Fatalf("RenderDevcontainer: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, ".devcontainer", "devcontainer.json"))
+	var parsed map[string]interface{}
+	json.Unmarshal(data, &parsed)
+
+	postCreate := parsed["postCreateCommand"].(string)
+	if strings.Contains(postCreate, "claude upgrade") || !strings.Contains(postCreate, "init-claudeup.sh") {
+		t.Errorf("postCreateCommand = %q, want provisioning without claude upgrade", postCreate)
+	}
+	if env := parsed["containerEnv"].(map[string]interface{}); env["CLAUDEUP_LAB_OFFLINE"] != "1" {
+		t.Errorf("containerEnv = %v, want CLAUDEUP_LAB_OFFLINE for the init scripts", env)
+	}
+	if _, ok := parsed["runArgs"]; ok {
+		t.Errorf("offline should not restrict the lab's network: %v", parsed["runArgs"])
+	}
+}
+
+func TestPinnedVersions(t *testing.T) {
+	render := func(offline bool) map[string]interface{} {
+		dir := t.TempDir()
+		config := &lab.DevcontainerConfig{
+			ProjectName:     "myapp",
+			Profile:         "base",
+			ID:              "abc-123",
+			DisplayName:     "myapp-base",
+			Image:           "test:latest",
+			BareRepoPath:    "/tmp/bare.git",
+			HomeDir:         t.TempDir(),
+			Offline:         offline,
+			ClaudeVersion:   "2.0.14",
+			ClaudeupVersion: "1.3.0",
+		}
+		if err := lab.RenderDevcontainer(config, dir); err != nil {
+			t.Fatalf("RenderDevcontainer: %v", err)
+		}
+		data, _ := os.ReadFile(filepath.Join(dir, ".devcontainer", "devcontainer.json"))
+		var parsed map[string]interface{}
+		json.Unmarshal(data, &parsed)
+		return parsed
+	}
+
+	online := render(false)
+	postCreate := online["postCreateCommand"].(string)
+	if !strings.HasPrefix(postCreate, "claude install 2.0.14 && ") || strings.Contains(postCreate, "claude upgrade") {
+		t.Errorf("postCreateCommand = %q, want the pinned version installed", postCreate)
+	}
+	env := online["containerEnv"].(map[string]interface{})
+	if env["CLAUDEUP_VERSION"] != "1.3.0" || env["DISABLE_AUTOUPDATER"] != "1" {
+		t.Errorf("containerEnv = %v", env)
+	}
+
+	postCreate = render(true)["postCreateCommand"].(string)
+	if strings.Contains(postCreate, "claude install") || !strings.Contains(postCreate, "= '2.0.14' ]") {
+		t.Errorf("offline postCreateCommand = %q, want the image's version checked instead", postCreate)
+	}
+}
+
+func TestRenderPorts(t *testing.T) {
+	dir := t.TempDir()
+	config := &lab.DevcontainerConfig{
+		ProjectName:  "myapp",
+		Profile:      "base",
+		ID:           "abc-123",
+		DisplayName:  "myapp-base",
+		Image:        "test:latest",
+		BareRepoPath: "/tmp/bare.git",
+		HomeDir:      t.TempDir(),
+		Ports: []lab.PortMapping{
+			{Host: 8080, Container: 3000, Published: true},
+			{Host: 9229, Container: 9229},
+		},
+	}
+	if err := lab.RenderDevcontainer(config, dir); err != nil {
+		t.Fatalf("RenderDevcontainer: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, ".devcontainer", "devcontainer.json"))
+	var parsed map[string]interface{}
+	json.Unmarshal(data, &parsed)
+
+	if fmt.Sprint(parsed["appPort"]) != "[127.0.0.1:8080:3000]" {
+		t.Errorf("appPort = %v, want only the published port, on loopback", parsed["appPort"])
+	}
+	if fmt.Sprint(parsed["forwardPorts"]) != "[3000 9229]" {
+		t.Errorf("forwardPorts = %v", parsed["forwardPorts"])
+	}
+}
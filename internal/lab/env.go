@@ -0,0 +1,159 @@
+package lab
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/claudeup/claudeup-lab/internal/devcontainer"
+)
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// EnvOptions are the start flags that add environment variables to a lab.
+type EnvOptions struct {
+	Set   []string // KEY=VALUE, from --env
+	Files []string // env files, from --env-file
+	Pass  []string // host variables to copy, from --pass-env
+}
+
+// ResolveEnv merges the environment of a new lab from the config file and
+// the start flags. Later sources win: the config file's env, then its
+// pass_env, then env files in the order given, then --pass-env, then
+// --env. Variables passed from the host but not set there are left out.
+func (m *Manager) ResolveEnv(opts EnvOptions) (map[string]string, error) {
+	env := make(map[string]string)
+	for k, v := range m.config.Env {
+		env[k] = v
+	}
+	for _, k := range m.config.PassEnv {
+		if v, ok := os.LookupEnv(k); ok {
+			env[k] = v
+		}
+	}
+	for _, path := range opts.Files {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("read env file: %w", err)
+		}
+		fileEnv, err := parseEnvFile(f, path)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range fileEnv {
+			env[k] = v
+		}
+	}
+	for _, k := range opts.Pass {
+		v, ok := os.LookupEnv(k)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Warning: --pass-env %s: not set in this environment\n", k)
+			continue
+		}
+		env[k] = v
+	}
+	for _, kv := range opts.Set {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --env %q (want KEY=VALUE; use --pass-env %s to copy it from this environment)", kv, kv)
+		}
+		env[k] = v
+	}
+
+	secrets, err := m.SecretNames()
+	if err != nil {
+		return nil, err
+	}
+	for k := range env {
+		if !envNamePattern.MatchString(k) {
+			return nil, fmt.Errorf("invalid environment variable name %q", k)
+		}
+		for _, s := range secrets {
+			if k == s {
+				return nil, fmt.Errorf("%s is a secret: labs receive it without it being written to devcontainer.json (see secrets in the config file)", k)
+			}
+		}
+	}
+	return env, nil
+}
+
+// checkEnv rejects variables claudeup-lab sets itself for a lab in the
+// given network mode, which a lab's own environment cannot override.
+func checkEnv(env map[string]string, network string) error {
+	builtin := builtinEnv(&DevcontainerConfig{Network: network}, "")
+	for _, k := range sortedEnvKeys(env) {
+		if _, ok := builtin[k]; ok {
+			return fmt.Errorf("%s is set by claudeup-lab and cannot be overridden", k)
+		}
+	}
+	return nil
+}
+
+// labEnv returns the values of a lab's own environment variables for
+// rendering its devcontainer.json again. Only their names are kept in the
+// metadata, so each value comes from the current devcontainer.json, else
+// the config file's env, else the host environment; variables found in
+// none of them are left out.
+func (m *Manager) labEnv(meta *Metadata) map[string]string {
+	if len(meta.Env) == 0 {
+		return nil
+	}
+	var current map[string]string
+	if cfg, err := devcontainer.LoadConfig(meta.Worktree); err == nil {
+		current = cfg.ContainerEnv
+	}
+
+	env := make(map[string]string)
+	for _, k := range meta.Env {
+		if v, ok := current[k]; ok {
+			env[k] = v
+		} else if v, ok := m.config.Env[k]; ok {
+			env[k] = v
+		} else if v, ok := os.LookupEnv(k); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+func sortedEnvKeys(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseEnvFile parses KEY=VALUE lines. Blank lines and lines starting with
+// # are skipped, a leading "export " is allowed, and a value may be wrapped
+// in single or double quotes.
+func parseEnvFile(r io.Reader, name string) (map[string]string, error) {
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || !envNamePattern.MatchString(key) {
+			return nil, fmt.Errorf("%s:%d: want KEY=VALUE", name, n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	return env, nil
+}
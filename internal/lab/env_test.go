@@ -0,0 +1,66 @@
+package lab_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+)
+
+func TestResolveEnvPrecedence(t *testing.T) {
+	baseDir := t.TempDir()
+	os.WriteFile(filepath.Join(baseDir, "config.json"), []byte(`{
+		"env": {"FROM_CONFIG": "config", "OVERRIDDEN": "config"},
+		"pass_env": ["HOST_VAR", "UNSET_HOST_VAR"]
+	}`), 0o644)
+	envFile := filepath.Join(t.TempDir(), "lab.env")
+	os.WriteFile(envFile, []byte("# overrides\nOVERRIDDEN=file\nFROM_FILE='quoted value'\n"), 0o644)
+	t.Setenv("HOST_VAR", "host")
+	t.Setenv("FLAG_PASS", "passed")
+	os.Unsetenv("UNSET_HOST_VAR")
+
+	env, err := lab.NewManager(baseDir).ResolveEnv(lab.EnvOptions{
+		Files: []string{envFile},
+		Pass:  []string{"FLAG_PASS"},
+		Set:   []string{"ANTHROPIC_BASE_URL=http://localhost:8080", "FLAG_PASS=set wins", "EMPTY="},
+	})
+	if err != nil {
+		t.Fatalf("ResolveEnv: %v", err)
+	}
+	want := map[string]string{
+		"FROM_CONFIG":        "config",
+		"OVERRIDDEN":         "file",
+		"HOST_VAR":           "host",
+		"FROM_FILE":          "quoted value",
+		"FLAG_PASS":          "set wins",
+		"ANTHROPIC_BASE_URL": "http://localhost:8080",
+		"EMPTY":              "",
+	}
+	if len(env) != len(want) {
+		t.Errorf("env = %v, want %v", env, want)
+	}
+	for k, v := range want {
+		if got, ok := env[k]; !ok || got != v {
+			t.Errorf("env[%s] = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestResolveEnvRejects(t *testing.T) {
+	mgr := lab.NewManager(t.TempDir())
+	for _, tc := range []struct {
+		opts lab.EnvOptions
+		want string
+	}{
+		{lab.EnvOptions{Set: []string{"NO_VALUE"}}, "--pass-env NO_VALUE"},
+		{lab.EnvOptions{Set: []string{"BAD-NAME=1"}}, "invalid environment variable name"},
+		{lab.EnvOptions{Set: []string{"GITHUB_TOKEN=ghp_x"}}, "is a secret"},
+		{lab.EnvOptions{Files: []string{filepath.Join(t.TempDir(), "missing.env")}}, "read env file"},
+	} {
+		if _, err := mgr.ResolveEnv(tc.opts); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("ResolveEnv(%+v) = %v, want error containing %q", tc.opts, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package lab
+
+import "os"
+
+// Internals exposed to the lab_test package.
+
+// SetSpawner replaces how m starts detached port forwarders.
+func (m *Manager) SetSpawner(spawn func(labID string, p PortMapping, log, pidFile *os.File) (int, error)) {
+	m.spawn = spawn
+}
+
+// ForwarderPIDPath returns where m records the forwarder of a host port.
+func (m *Manager) ForwarderPIDPath(labID string, hostPort int) string {
+	return m.forwarderPIDPath(labID, hostPort)
+}
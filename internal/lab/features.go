@@ -0,0 +1,291 @@
+package lab
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	assets "github.com/claudeup/claudeup-lab/embed"
+	"github.com/claudeup/claudeup-lab/internal/config"
+)
+
+// Devcontainer features are named in a registry: the one built into the
+// binary, overlaid by the user's registry in the base directory and then by
+// the project's own, each a features.json mapping names to OCI references.
+// A full OCI reference can be used without a registry entry.
+
+// featuresFileName is the registry file in the base directory and in a
+// project's .claudeup-lab directory.
+const featuresFileName = "features.json"
+
+// Feature registry sources, from lowest to highest precedence.
+const (
+	FeatureSourceBuiltin = "built-in"
+	FeatureSourceUser    = "user"
+	FeatureSourceProject = "project"
+)
+
+// FeatureEntry is a named feature in a registry.
+type FeatureEntry struct {
+	Name           string            `json:"name"`
+	Feature        string            `json:"feature"` // OCI reference
+	DefaultVersion string            `json:"default_version,omitempty"`
+	Options        map[string]string `json:"options,omitempty"` // defaults, overridden by the spec
+	Source         string            `json:"source"`
+	Path           string            `json:"path,omitempty"` // registry file, for user and project entries
+}
+
+// FeatureRegistry maps feature names to their entries.
+type FeatureRegistry map[string]FeatureEntry
+
+// featureFileEntry is an entry as written in features.json.
+type featureFileEntry struct {
+	Feature        string            `json:"feature"`
+	DefaultVersion string            `json:"default_version"`
+	Options        map[string]string `json:"options"`
+}
+
+// ProjectFeaturesPath returns a project's feature registry.
+func ProjectFeaturesPath(project string) string {
+	return filepath.Join(project, config.ProjectDir, featuresFileName)
+}
+
+// UserFeaturesPath returns the user's feature registry.
+func (m *Manager) UserFeaturesPath() string {
+	return filepath.Join(m.baseDir, featuresFileName)
+}
+
+// FeatureRegistry merges the built-in registry with the user's and, when
+// project is not empty, the project's. Later registries replace entries of
+// the same name.
+func (m *Manager) FeatureRegistry(project string) (FeatureRegistry, error) {
+	registry, err := builtinFeatures()
+	if err != nil {
+		return nil, err
+	}
+	paths := []struct{ source, path string }{{FeatureSourceUser, m.UserFeaturesPath()}}
+	if project != "" {
+		paths = append(paths, struct{ source, path string }{FeatureSourceProject, ProjectFeaturesPath(project)})
+	}
+	for _, p := range paths {
+		data, err := os.ReadFile(p.path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read feature registry: %w", err)
+		}
+		if err := registry.add(data, p.source, p.path); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// labFeatures returns the registry a lab's features are resolved with. A
+// registry that cannot be read is reported, and the built-in one used.
+func (m *Manager) labFeatures(meta *Metadata) FeatureRegistry {
+	registry, err := m.FeatureRegistry(meta.Project)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return nil
+	}
+	return registry
+}
+
+// builtinFeatures returns the registry embedded in the binary.
+func builtinFeatures() (FeatureRegistry, error) {
+	registry := FeatureRegistry{}
+	if err := registry.add(assets.FeaturesJSON, FeatureSourceBuiltin, ""); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+func (r FeatureRegistry) add(data []byte, source, path string) error {
+	name := path
+	if name == "" {
+		name = "built-in feature registry"
+	}
+	var entries map[string]featureFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("parse %s: %w", name, err)
+	}
+	for feature, e := range entries {
+		if e.Feature == "" || strings.ContainsAny(feature, ":,=/") {
+			return fmt.Errorf("%s: invalid feature %q (want a name without :,=/ and an OCI reference in \"feature\")", name, feature)
+		}
+		r[feature] = FeatureEntry{
+			Name:           feature,
+			Feature:        e.Feature,
+			DefaultVersion: e.DefaultVersion,
+			Options:        e.Options,
+			Source:         source,
+			Path:           path,
+		}
+	}
+	return nil
+}
+
+// Entries returns the registry's entries sorted by name.
+func (r FeatureRegistry) Entries() []FeatureEntry {
+	entries := make([]FeatureEntry, 0, len(r))
+	for _, e := range r {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// FeatureSpec is a parsed --feature value.
+type FeatureSpec struct {
+	Name    string // registry name, or empty for a full reference
+	Ref     string // OCI reference, for specs that are one
+	Version string
+	Options map[string]string
+}
+
+// ParseFeatureSpec parses a feature as given to --feature: a registry name
+// with an optional version, or a full OCI reference, followed by
+// comma-separated options: "go:1.23,golangci=true" or
+// "ghcr.io/devcontainers/features/go:1,version=1.23".
+func ParseFeatureSpec(s string) (FeatureSpec, error) {
+	parts := strings.Split(s, ",")
+	var spec FeatureSpec
+	if strings.Contains(parts[0], "/") {
+		spec.Ref = parts[0]
+	} else {
+		spec.Name, spec.Version, _ = strings.Cut(parts[0], ":")
+	}
+	if spec.Name == "" && spec.Ref == "" {
+		return FeatureSpec{}, fmt.Errorf("invalid feature %q (want name[:version][,option=value...])", s)
+	}
+	for _, opt := range parts[1:] {
+		key, value, ok := strings.Cut(opt, "=")
+		if !ok || key == "" {
+			return FeatureSpec{}, fmt.Errorf("invalid feature %q: option %q is not key=value", s, opt)
+		}
+		if spec.Options == nil {
+			spec.Options = make(map[string]string)
+		}
+		spec.Options[key] = value
+	}
+	return spec, nil
+}
+
+// SplitFeatureSpecs splits a --feature value that lists several features,
+// "go,python:3.12", keeping each feature's options with it.
+func SplitFeatureSpecs(value string) []string {
+	var specs []string
+	for _, part := range strings.Split(value, ",") {
+		if len(specs) > 0 && strings.Contains(part, "=") {
+			specs[len(specs)-1] += "," + part
+			continue
+		}
+		specs = append(specs, part)
+	}
+	return specs
+}
+
+// Resolve returns the OCI reference and devcontainer.json options of a
+// feature spec. Unknown names are an error that suggests a close match.
+func (r FeatureRegistry) Resolve(s string) (string, map[string]interface{}, error) {
+	spec, err := ParseFeatureSpec(s)
+	if err != nil {
+		return "", nil, err
+	}
+
+	options := make(map[string]interface{})
+	ref := spec.Ref
+	if spec.Name != "" {
+		entry, ok := r[spec.Name]
+		if !ok {
+			return "", nil, r.unknown(spec.Name)
+		}
+		ref = entry.Feature
+		for k, v := range entry.Options {
+			options[k] = featureOptionValue(v)
+		}
+		version := spec.Version
+		if version == "" {
+			version = entry.DefaultVersion
+		}
+		if version != "" {
+			options["version"] = version
+		}
+	}
+	for k, v := range spec.Options {
+		options[k] = featureOptionValue(v)
+	}
+	return ref, options, nil
+}
+
+// ResolveAll resolves every spec into devcontainer.json's features map.
+func (r FeatureRegistry) ResolveAll(specs []string) (map[string]interface{}, error) {
+	features := make(map[string]interface{})
+	for _, s := range specs {
+		ref, options, err := r.Resolve(s)
+		if err != nil {
+			return nil, err
+		}
+		features[ref] = options
+	}
+	return features, nil
+}
+
+// unknown builds the error for a feature name missing from the registry.
+func (r FeatureRegistry) unknown(name string) error {
+	best, bestDist := "", 3
+	lower := strings.ToLower(name)
+	for known := range r {
+		k := strings.ToLower(known)
+		d := editDistance(lower, k)
+		if strings.HasPrefix(lower, k) || strings.HasPrefix(k, lower) {
+			// golang for go, py for python
+			d = min(d, 1)
+		}
+		if d < bestDist || (d == bestDist && known < best) {
+			best, bestDist = known, d
+		}
+	}
+	if best != "" {
+		return fmt.Errorf("unknown feature %q (did you mean %q? see: claudeup-lab features)", name, best)
+	}
+	return fmt.Errorf("unknown feature %q (see: claudeup-lab features, or use a full OCI reference)", name)
+}
+
+// featureOptionValue types an option value the way feature options are
+// declared: true and false are booleans, anything else a string.
+func featureOptionValue(v string) interface{} {
+	switch v {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return v
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,83 @@
+package lab_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+)
+
+func TestParseFeatureSpec(t *testing.T) {
+	spec, err := lab.ParseFeatureSpec("go:1.23,golangci=true")
+	if err != nil || spec.Name != "go" || spec.Version != "1.23" || spec.Options["golangci"] != "true" {
+		t.Errorf("ParseFeatureSpec(name) = %+v, %v", spec, err)
+	}
+	spec, err = lab.ParseFeatureSpec("ghcr.io/devcontainers/features/go:1,version=1.23")
+	if err != nil || spec.Name != "" || spec.Ref != "ghcr.io/devcontainers/features/go:1" || spec.Options["version"] != "1.23" {
+		t.Errorf("ParseFeatureSpec(ref) = %+v, %v", spec, err)
+	}
+	for _, bad := range []string{"", ":1.23", "go,golangci", "go,=true"} {
+		if _, err := lab.ParseFeatureSpec(bad); err == nil {
+			t.Errorf("ParseFeatureSpec(%q) should fail", bad)
+		}
+	}
+
+	got := strings.Join(lab.SplitFeatureSpecs("go:1.23,golangci=true,python,node:20"), " ")
+	if got != "go:1.23,golangci=true python node:20" {
+		t.Errorf("SplitFeatureSpecs = %q", got)
+	}
+}
+
+func TestFeatureRegistryOverlays(t *testing.T) {
+	baseDir := t.TempDir()
+	project := t.TempDir()
+	os.WriteFile(filepath.Join(baseDir, "features.json"), []byte(`{
+		"go": {"feature": "ghcr.io/example/go:2", "default_version": "1.22", "options": {"golangci": "false"}},
+		"terraform": {"feature": "ghcr.io/devcontainers/features/terraform:1"}
+	}`), 0o644)
+	os.MkdirAll(filepath.Join(project, ".claudeup-lab"), 0o755)
+	os.WriteFile(lab.ProjectFeaturesPath(project), []byte(`{
+		"terraform": {"feature": "ghcr.io/example/terraform:1", "default_version": "1.9"}
+	}`), 0o644)
+
+	registry, err := lab.NewManager(baseDir).FeatureRegistry(project)
+	if err != nil {
+		t.Fatalf("FeatureRegistry: %v", err)
+	}
+	sources := make(map[string]string)
+	for _, e := range registry.Entries() {
+		sources[e.Name] = e.Source
+	}
+	if sources["python"] != lab.FeatureSourceBuiltin || sources["go"] != lab.FeatureSourceUser || sources["terraform"] != lab.FeatureSourceProject {
+		t.Errorf("sources = %v", sources)
+	}
+
+	ref, options, err := registry.Resolve("go:1.23,golangci=true")
+	if err != nil || ref != "ghcr.io/example/go:2" || options["version"] != "1.23" || options["golangci"] != true {
+		t.Errorf("Resolve(go) = %q, %v, %v", ref, options, err)
+	}
+	ref, options, err = registry.Resolve("go")
+	if err != nil || options["version"] != "1.22" || options["golangci"] != false {
+		t.Errorf("Resolve(go) with defaults = %q, %v, %v", ref, options, err)
+	}
+	ref, options, err = registry.Resolve("ghcr.io/devcontainers/features/docker-in-docker:2,moby=false")
+	if err != nil || ref != "ghcr.io/devcontainers/features/docker-in-docker:2" || options["moby"] != false || options["version"] != nil {
+		t.Errorf("Resolve(ref) = %q, %v, %v", ref, options, err)
+	}
+
+	_, _, err = registry.Resolve("golang:1.23")
+	if err == nil || !strings.Contains(err.Error(), `did you mean "go"`) {
+		t.Errorf("Resolve(golang) = %v, want a suggestion", err)
+	}
+	_, _, err = registry.Resolve("haskell")
+	if err == nil || !strings.Contains(err.Error(), "claudeup-lab features") {
+		t.Errorf("Resolve(haskell) = %v, want a pointer to the features command", err)
+	}
+
+	os.WriteFile(filepath.Join(baseDir, "features.json"), []byte(`{"go": {}}`), 0o644)
+	if _, err := lab.NewManager(baseDir).FeatureRegistry(""); err == nil {
+		t.Error("an entry without an OCI reference should be rejected")
+	}
+}
@@ -0,0 +1,244 @@
+package lab
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/claudeup/claudeup-lab/internal/config"
+	"github.com/claudeup/claudeup-lab/internal/docker"
+)
+
+// pinFileName holds the digest reference new labs are pinned to, written
+// by image pin.
+const pinFileName = "image.pin"
+
+// featureImageRepository is where the builtin engine keeps base images
+// with features installed.
+const featureImageRepository = "claudeup-lab-features"
+
+// layerFileName is the Dockerfile a project's labs are built from on top of
+// the base image when its config names none.
+const layerFileName = "Dockerfile.lab"
+
+// PinPath returns the file the image pin is kept in.
+func (m *Manager) PinPath() string {
+	return filepath.Join(m.baseDir, pinFileName)
+}
+
+// ConfiguredImage returns the base image reference new labs use before any
+// pin: CLAUDEUP_LAB_IMAGE, else image in the config file, else the
+// published image.
+func (m *Manager) ConfiguredImage() string {
+	if v := strings.TrimSpace(os.Getenv("CLAUDEUP_LAB_IMAGE")); v != "" {
+		return v
+	}
+	if m.config.Image != "" {
+		return m.config.Image
+	}
+	return docker.DefaultImage
+}
+
+// PinnedImage returns the digest reference written by image pin, or "".
+func (m *Manager) PinnedImage() string {
+	data, err := os.ReadFile(m.PinPath())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// DefaultImage returns the image new labs are created from without
+// start --image. CLAUDEUP_LAB_IMAGE overrides a pin; the pin overrides the
+// config file.
+func (m *Manager) DefaultImage() string {
+	if os.Getenv("CLAUDEUP_LAB_IMAGE") == "" {
+		if pin := m.PinnedImage(); pin != "" {
+			return pin
+		}
+	}
+	return m.ConfiguredImage()
+}
+
+// imageDigest identifies the image a lab is created from: its registry
+// digest, or its local ID for an image built on this host.
+func (m *Manager) imageDigest(image string) (string, error) {
+	digest, err := m.images.Digest(image)
+	if err != nil || digest != "" {
+		return digest, err
+	}
+	return m.images.ID(image)
+}
+
+// PinImage locks new labs to the current digest of image, pulling it
+// first if it is not present. It returns the pinned reference.
+func (m *Manager) PinImage(image string) (string, error) {
+	if docker.IsDigestRef(image) {
+		return "", fmt.Errorf("%s is already a digest reference", image)
+	}
+	if !m.images.ExistsLocally(image) {
+		if err := m.images.Pull(image); err != nil {
+			return "", err
+		}
+	}
+	digest, err := m.images.Digest(image)
+	if err != nil {
+		return "", err
+	}
+	if digest == "" {
+		return "", fmt.Errorf("image %s was built locally and has no registry digest (pull it with: claudeup-lab image pull)", image)
+	}
+
+	pinned := docker.Repository(image) + "@" + digest
+	if err := os.MkdirAll(m.baseDir, 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(m.PinPath(), []byte(pinned+"\n"), 0o644); err != nil {
+		return "", fmt.Errorf("write image pin: %w", err)
+	}
+	return pinned, nil
+}
+
+// UnpinImage removes the image pin, if any.
+func (m *Manager) UnpinImage() error {
+	if err := os.Remove(m.PinPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove image pin: %w", err)
+	}
+	return nil
+}
+
+// LabImage is a local image claudeup-lab created or pulled.
+type LabImage struct {
+	docker.Image
+	Default bool     `json:"default"` // what new labs are created from
+	Labs    []string `json:"labs"`    // labs whose container runs it
+}
+
+// LabImages lists the local base, feature and project images, with the labs
+// whose containers use them.
+func (m *Manager) LabImages() ([]LabImage, error) {
+	repos := []string{docker.Repository(m.ConfiguredImage())}
+	if pin := m.PinnedImage(); pin != "" && !slices.Contains(repos, docker.Repository(pin)) {
+		repos = append(repos, docker.Repository(pin))
+	}
+	repos = append(repos, featureImageRepository, docker.LayerRepository)
+
+	images, err := m.images.List(repos...)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultID, _ := m.images.ID(m.DefaultImage())
+	users := m.imageUsers()
+	var result []LabImage
+	for _, img := range images {
+		labs := users[img.ID]
+		if labs == nil {
+			labs = []string{}
+		}
+		result = append(result, LabImage{Image: img, Default: img.ID == defaultID, Labs: labs})
+	}
+	return result, nil
+}
+
+// imageUsers maps image IDs to the labs whose container was created from
+// them.
+func (m *Manager) imageUsers() map[string][]string {
+	users := make(map[string][]string)
+	labs, err := m.store.List()
+	if err != nil {
+		return users
+	}
+	for _, meta := range labs {
+		id, _ := m.docker.FindContainerIncludingStopped(meta.Worktree)
+		if id == "" {
+			continue
+		}
+		if ctr, err := m.docker.InspectContainer(id); err == nil {
+			users[ctr.ImageID] = append(users[ctr.ImageID], meta.DisplayName)
+		}
+	}
+	return users
+}
+
+// UnusedImages returns the lab images no container uses and new labs
+// would not be created from.
+func (m *Manager) UnusedImages() ([]LabImage, error) {
+	images, err := m.LabImages()
+	if err != nil {
+		return nil, err
+	}
+	var unused []LabImage
+	for _, img := range images {
+		if !img.Default && len(img.Labs) == 0 {
+			unused = append(unused, img)
+		}
+	}
+	return unused, nil
+}
+
+// PruneImages removes images, as returned by UnusedImages.
+func (m *Manager) PruneImages(images []LabImage) error {
+	var refs []string
+	for _, img := range images {
+		refs = append(refs, img.Ref())
+	}
+	return m.images.Remove(refs)
+}
+
+// RunsStaleImage reports whether a lab's container was created from an
+// image built by another version of claudeup-lab. Images built from a stale
+// base, such as feature images, inherit its assets label.
+func (m *Manager) RunsStaleImage(meta *Metadata) bool {
+	id, _ := m.docker.FindContainerIncludingStopped(meta.Worktree)
+	if id == "" {
+		return false
+	}
+	ctr, err := m.docker.InspectContainer(id)
+	if err != nil {
+		return false
+	}
+	stale, _ := m.images.Stale(ctr.ImageID)
+	return stale
+}
+
+// ProjectDockerfile returns the Dockerfile a project's labs are built from
+// on top of the base image: dockerfile from the project config, else
+// Dockerfile.lab if the project has one, else "".
+func ProjectDockerfile(project string) (string, error) {
+	cfg, err := config.LoadProject(project)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Dockerfile == "" {
+		path := filepath.Join(project, layerFileName)
+		if _, err := os.Stat(path); err != nil {
+			return "", nil
+		}
+		return path, nil
+	}
+
+	path := cfg.Dockerfile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(project, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("project Dockerfile (dockerfile in %s): %w", config.ProjectPath(project), err)
+	}
+	return path, nil
+}
+
+// layerImage builds a lab's project Dockerfile on top of base again, or
+// reuses the image built from it at start if the Dockerfile is gone.
+func (m *Manager) layerImage(meta *Metadata, base string) (string, error) {
+	if _, err := os.Stat(meta.Dockerfile); os.IsNotExist(err) && meta.LayerImage != "" && m.images.ExistsLocally(meta.LayerImage) {
+		return meta.LayerImage, nil
+	}
+	digest, err := m.imageDigest(base)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return m.images.BuildLayer(base, digest, meta.Dockerfile, meta.Project)
+}
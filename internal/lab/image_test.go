@@ -0,0 +1,53 @@
+package lab_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/docker"
+	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/claudeup/claudeup-lab/internal/runner"
+)
+
+func TestDefaultImagePrecedence(t *testing.T) {
+	t.Setenv("CLAUDEUP_LAB_IMAGE", "")
+	baseDir := t.TempDir()
+
+	if got := lab.NewManager(baseDir).DefaultImage(); got != docker.DefaultImage {
+		t.Errorf("DefaultImage() = %q, want the published image", got)
+	}
+
+	os.WriteFile(filepath.Join(baseDir, "config.json"), []byte(`{"image": "registry.example.com/lab:v2"}`), 0o644)
+	if got := lab.NewManager(baseDir).DefaultImage(); got != "registry.example.com/lab:v2" {
+		t.Errorf("DefaultImage() = %q, want the config file's image", got)
+	}
+
+	fake := runner.NewFake()
+	fake.On("docker", "image", "inspect")
+	fake.On("docker", "image", "inspect", "--format").Return(`["registry.example.com/lab@sha256:feed"]`, nil)
+	mgr := lab.NewManagerWithRunner(baseDir, fake)
+	pinned, err := mgr.PinImage(mgr.ConfiguredImage())
+	if err != nil || pinned != "registry.example.com/lab@sha256:feed" {
+		t.Fatalf("PinImage = %q, %v", pinned, err)
+	}
+	if got := mgr.DefaultImage(); got != pinned {
+		t.Errorf("DefaultImage() = %q, want the pin", got)
+	}
+
+	t.Setenv("CLAUDEUP_LAB_IMAGE", "lab:dev")
+	if got := mgr.DefaultImage(); got != "lab:dev" {
+		t.Errorf("DefaultImage() = %q, want CLAUDEUP_LAB_IMAGE over the pin", got)
+	}
+	t.Setenv("CLAUDEUP_LAB_IMAGE", "")
+
+	if err := mgr.UnpinImage(); err != nil {
+		t.Fatalf("UnpinImage: %v", err)
+	}
+	if got := mgr.DefaultImage(); got != "registry.example.com/lab:v2" {
+		t.Errorf("DefaultImage() after unpin = %q", got)
+	}
+	if _, err := mgr.PinImage(pinned); err == nil {
+		t.Error("pinning a digest reference should fail")
+	}
+}
@@ -0,0 +1,177 @@
+package lab
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/claudeup/claudeup-lab/internal/devcontainer"
+	"github.com/claudeup/claudeup-lab/internal/docker"
+)
+
+// LabInfo is the machine-readable view of a lab: its metadata plus what
+// the container runtime reports about it right now. Its JSON encoding is
+// the stable schema of --output json, yaml and go-template.
+type LabInfo struct {
+	*Metadata
+
+	// Status is the live status shown by list: "running", "stopped", a
+	// transitional or failed lifecycle state, or "orphaned" when the
+	// worktree is gone.
+	Status      string   `json:"status"`
+	Resumable   bool     `json:"resumable"`
+	ContainerID string   `json:"container_id,omitempty"`
+	Volumes     []string `json:"volumes"`
+}
+
+// Info gathers a lab's metadata and live status.
+func (m *Manager) Info(meta *Metadata) *LabInfo {
+	info := &LabInfo{
+		Metadata: meta,
+		Status:   m.LabStatus(meta),
+		Volumes:  []string{},
+	}
+	info.Resumable = info.Status == string(StateStopped) && m.CanResume(meta)
+	info.ContainerID, _ = m.docker.FindContainerIncludingStopped(meta.Worktree)
+	if volumes, err := m.docker.ListVolumes(meta.ID); err == nil {
+		info.Volumes = append(info.Volumes, docker.VolumeNames(volumes)...)
+	}
+	return info
+}
+
+// LabDetails is everything inspect shows about a lab. Sections that could
+// not be gathered are left empty, with the reason in Warnings.
+type LabDetails struct {
+	*LabInfo
+
+	Container    *ContainerDetails `json:"container"`
+	VolumeSizes  map[string]int64  `json:"volume_sizes"` // bytes, for volumes the runtime could measure
+	Mounts       []Mount           `json:"mounts"`
+	Devcontainer json.RawMessage   `json:"devcontainer,omitempty"`
+	Git          *Divergence       `json:"git,omitempty"`
+	Blocked      []BlockedRequest  `json:"blocked,omitempty"` // allowlist labs only
+	Secrets      []SecretStatus    `json:"secrets"`
+	Warnings     []string          `json:"warnings,omitempty"`
+}
+
+// ContainerDetails describes a lab's container as the runtime reports it.
+type ContainerDetails struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Image         string    `json:"image"`
+	ImageID       string    `json:"image_id"`
+	ImageDigest   string    `json:"image_digest,omitempty"`
+	State         string    `json:"state"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+}
+
+// Inspect gathers a lab's full details: Info plus the container, volume
+// sizes, mount plan, rendered devcontainer.json with secrets redacted,
+// where its secrets come from and git divergence.
+func (m *Manager) Inspect(meta *Metadata) *LabDetails {
+	d := &LabDetails{LabInfo: m.Info(meta), VolumeSizes: map[string]int64{}}
+	warn := func(format string, args ...interface{}) {
+		d.Warnings = append(d.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	if d.ContainerID != "" {
+		if ctr, err := m.docker.InspectContainer(d.ContainerID); err != nil {
+			warn("inspect container: %v", err)
+		} else {
+			d.Container = containerDetails(ctr)
+			if digest, err := m.images.Digest(ctr.ImageID); err != nil {
+				warn("image digest: %v", err)
+			} else {
+				d.Container.ImageDigest = digest
+			}
+		}
+	}
+
+	if sizes, err := m.docker.VolumeSizes(d.Volumes); err != nil {
+		warn("volume sizes: %v", err)
+	} else {
+		d.VolumeSizes = sizes
+	}
+
+	d.Mounts = m.Mounts(meta)
+
+	secrets, statuses, err := m.resolveSecrets()
+	if err != nil {
+		warn("secrets: %v", err)
+	}
+	d.Secrets = statuses
+	if d.Secrets == nil {
+		d.Secrets = []SecretStatus{}
+	}
+	names, _ := m.SecretNames()
+
+	if data, err := os.ReadFile(devcontainer.ConfigPath(meta.Worktree)); err != nil {
+		warn("read devcontainer.json: %v", err)
+	} else if json.Valid(data) {
+		d.Devcontainer = redactDevcontainer(data, names, NewRedactor(secrets))
+	} else {
+		warn("devcontainer.json is not valid JSON")
+	}
+
+	if dirExists(meta.Worktree) {
+		if div, err := m.worktrees.Divergence(meta.Worktree, meta.Project); err != nil {
+			warn("git divergence: %v", err)
+		} else {
+			d.Git = div
+		}
+	}
+
+	if blocked, err := m.BlockedRequests(meta, time.Time{}); err != nil {
+		warn("blocked requests: %v", err)
+	} else {
+		d.Blocked = blocked
+	}
+
+	return d
+}
+
+func containerDetails(ctr *docker.Container) *ContainerDetails {
+	d := &ContainerDetails{
+		ID:        ctr.ID,
+		Name:      ctr.Name,
+		Image:     ctr.Image,
+		ImageID:   ctr.ImageID,
+		State:     ctr.State,
+		StartedAt: ctr.StartedAt,
+	}
+	if ctr.Running() && !ctr.StartedAt.IsZero() {
+		d.UptimeSeconds = int64(time.Since(ctr.StartedAt).Seconds())
+	}
+	return d
+}
+
+// Mounts returns a lab's mount plan. A mount counts as applied when it is
+// in the lab's rendered devcontainer.json; optional mounts missing from it
+// carry the reason they were skipped.
+func (m *Manager) Mounts(meta *Metadata) []Mount {
+	plan := planMounts(m.devcontainerConfig(meta, ""))
+
+	cfg, err := devcontainer.LoadConfig(meta.Worktree)
+	if err != nil {
+		return plan
+	}
+	rendered := make(map[string]bool)
+	for _, spec := range cfg.Mounts {
+		rendered[spec] = true
+	}
+	for i := range plan {
+		applied := rendered[plan[i].spec]
+		if applied == plan[i].Applied {
+			continue
+		}
+		plan[i].Applied = applied
+		if applied {
+			plan[i].Skipped = ""
+		} else if plan[i].Skipped == "" {
+			plan[i].Skipped = "not present when the lab was rendered"
+		}
+	}
+	return plan
+}
@@ -0,0 +1,278 @@
+package lab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/claudeup/claudeup-lab/internal/docker"
+)
+
+// Journal step actions, in the order Start performs them.
+const (
+	stepSnapshot  = "snapshot"
+	stepBareClone = "bare-clone"
+	stepWorktree  = "worktree"
+	stepNetwork   = "network"
+	stepVolumes   = "volumes"
+	stepContainer = "container"
+)
+
+// ErrInterrupted is returned by Start when it receives SIGINT or SIGTERM.
+var ErrInterrupted = errors.New("lab creation interrupted")
+
+// JournalStep records one side effect of lab creation with enough detail to
+// undo it.
+type JournalStep struct {
+	Action   string `json:"action"`
+	Profile  string `json:"profile,omitempty"`
+	BareRepo string `json:"bare_repo,omitempty"`
+	Worktree string `json:"worktree,omitempty"`
+	Branch   string `json:"branch,omitempty"`
+}
+
+// Journal is a write-ahead log of the steps taken while creating a lab.
+// Each step is recorded before it runs, so a crash at any point leaves
+// enough on disk to undo whatever was partially done.
+type Journal struct {
+	LabID   string        `json:"lab_id"`
+	PID     int           `json:"pid"`
+	Started time.Time     `json:"started"`
+	Steps   []JournalStep `json:"steps"`
+
+	path string
+}
+
+func (m *Manager) journalDir() string {
+	return filepath.Join(m.baseDir, "journal")
+}
+
+// beginJournal creates an empty journal for a new lab.
+func (m *Manager) beginJournal(labID string) (*Journal, error) {
+	if err := os.MkdirAll(m.journalDir(), 0o755); err != nil {
+		return nil, fmt.Errorf("create journal directory: %w", err)
+	}
+	j := &Journal{
+		LabID:   labID,
+		PID:     os.Getpid(),
+		Started: time.Now().UTC(),
+		path:    filepath.Join(m.journalDir(), labID+".json"),
+	}
+	if err := j.save(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Record appends a step and flushes the journal to disk.
+func (j *Journal) Record(step JournalStep) error {
+	j.Steps = append(j.Steps, step)
+	return j.save()
+}
+
+// Amend updates the most recently recorded step and flushes the journal.
+func (j *Journal) Amend(fn func(step *JournalStep)) error {
+	if len(j.Steps) == 0 {
+		return nil
+	}
+	fn(&j.Steps[len(j.Steps)-1])
+	return j.save()
+}
+
+// Commit marks lab creation as complete by removing the journal.
+func (j *Journal) Commit() error {
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove journal: %w", err)
+	}
+	return nil
+}
+
+func (j *Journal) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal journal: %w", err)
+	}
+	if err := writeFileAtomic(j.path, data, 0o644); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	return nil
+}
+
+func loadJournal(path string) (*Journal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+	var j Journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("parse journal %s: %w", filepath.Base(path), err)
+	}
+	j.path = path
+	return &j, nil
+}
+
+// rollback undoes every recorded step in reverse order and removes the
+// journal. Failures are reported but do not stop the remaining steps.
+func (m *Manager) rollback(j *Journal) error {
+	fmt.Fprintf(os.Stderr, "Rolling back lab %s...\n", shortID(j.LabID))
+
+	var errs []string
+	for i := len(j.Steps) - 1; i >= 0; i-- {
+		if err := m.undoStep(j, j.Steps[i]); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", j.Steps[i].Action, err))
+		}
+	}
+
+	if err := j.Commit(); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("rollback of lab %s incomplete: %s", shortID(j.LabID), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// createdWorktree returns the worktree a journal's lab creation added, if
+// it got that far.
+func (j *Journal) createdWorktree() string {
+	for _, s := range j.Steps {
+		if s.Action == stepWorktree {
+			return s.Worktree
+		}
+	}
+	return ""
+}
+
+func (m *Manager) undoStep(j *Journal, step JournalStep) error {
+	labID := j.LabID
+	switch step.Action {
+	case stepSnapshot:
+		return m.profiles.CleanupSnapshot(step.Profile)
+	case stepBareClone:
+		return m.worktrees.RemoveBareRepo(step.BareRepo, j.createdWorktree())
+	case stepWorktree:
+		if err := m.worktrees.RemoveWorktree(step.BareRepo, step.Worktree); err != nil {
+			return err
+		}
+		if step.Branch != "" {
+			return m.worktrees.DeleteBranch(step.BareRepo, step.Branch)
+		}
+	case stepNetwork:
+		return m.removeProxy(labID)
+	case stepVolumes:
+		volumes, err := m.docker.ListVolumes(labID)
+		if err != nil {
+			return err
+		}
+		return m.docker.RemoveVolumes(docker.VolumeNames(volumes))
+	case stepContainer:
+		id, err := m.docker.FindContainerIncludingStopped(step.Worktree)
+		if err != nil || id == "" {
+			return err
+		}
+		return m.docker.RemoveContainer(id)
+	default:
+		return fmt.Errorf("unknown journal step %q", step.Action)
+	}
+	return nil
+}
+
+// PendingJournals returns the journals left behind by lab creations that
+// neither finished nor rolled back, excluding ones whose process is still
+// running. Journals that cannot be read are reported and skipped.
+func (m *Manager) PendingJournals() ([]*Journal, error) {
+	entries, err := os.ReadDir(m.journalDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read journal directory: %w", err)
+	}
+
+	var journals []*Journal
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(m.journalDir(), entry.Name())
+		j, err := loadJournal(path)
+		if err != nil {
+			// One unreadable journal must not hold up recovery of the rest
+			fmt.Fprintf(os.Stderr, "Warning: skipping journal %s: %v\n", path, err)
+			continue
+		}
+		if j.PID != os.Getpid() && processAlive(j.PID) {
+			continue
+		}
+		journals = append(journals, j)
+	}
+	return journals, nil
+}
+
+// RecoverJournals finishes off interrupted lab creations. A journal whose
+// lab got past provisioning belongs to a lab that completed just before the
+// crash, so only the journal is removed. Anything else is rolled back and
+// its metadata, if any, is marked failed.
+func (m *Manager) RecoverJournals() error {
+	journals, err := m.PendingJournals()
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, j := range journals {
+		meta, loadErr := m.store.Load(j.LabID)
+		if loadErr == nil && meta.State != StateCreating && meta.State != StateProvisioning {
+			if err := j.Commit(); err != nil {
+				errs = append(errs, err.Error())
+			}
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Found interrupted lab creation from %s\n", j.Started.Local().Format(time.DateTime))
+		if err := m.rollback(j); err != nil {
+			errs = append(errs, err.Error())
+		}
+		if loadErr == nil {
+			if err := m.store.Transition(meta, StateFailed, ErrInterrupted); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("recover journals: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// notifyInterrupt captures SIGINT and SIGTERM so Start can roll back instead
+// of dying mid-step. Child processes in the foreground process group still
+// receive the signal and exit on their own.
+func notifyInterrupt() chan os.Signal {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	return ch
+}
+
+func checkInterrupted(ch chan os.Signal) error {
+	select {
+	case <-ch:
+		return ErrInterrupted
+	default:
+		return nil
+	}
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	return syscall.Kill(pid, 0) == nil
+}
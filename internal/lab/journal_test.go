@@ -0,0 +1,156 @@
+package lab_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+)
+
+func writeJournal(t *testing.T, baseDir string, j lab.Journal) string {
+	t.Helper()
+	dir := filepath.Join(baseDir, "journal")
+	os.MkdirAll(dir, 0o755)
+	data, _ := json.Marshal(j)
+	path := filepath.Join(dir, j.LabID+".json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+	return path
+}
+
+func TestRecoverJournalsRollsBack(t *testing.T) {
+	baseDir := t.TempDir()
+	cupHome := t.TempDir()
+	t.Setenv("CLAUDEUP_HOME", cupHome)
+	profilesDir := filepath.Join(cupHome, "profiles")
+	os.MkdirAll(profilesDir, 0o755)
+	os.WriteFile(filepath.Join(profilesDir, "_lab-snapshot-deadbeef.json"), []byte("{}"), 0o644)
+
+	source := initTestRepo(t)
+	mgr := lab.NewManager(baseDir)
+	barePath, _, err := mgr.Worktrees().EnsureBareRepo(source, "testproject")
+	if err != nil {
+		t.Fatalf("EnsureBareRepo: %v", err)
+	}
+	wtPath := filepath.Join(baseDir, "workspaces", "testproject-lab")
+	if _, _, err := mgr.Worktrees().CreateWorktree(barePath, wtPath, "lab/test"); err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+
+	// PID 0 is never a live owner, so the journal counts as abandoned
+	path := writeJournal(t, baseDir, lab.Journal{
+		LabID: "abc-123",
+		Steps: []lab.JournalStep{
+			{Action: "snapshot", Profile: "_lab-snapshot-deadbeef"},
+			{Action: "bare-clone", BareRepo: barePath},
+			{Action: "worktree", BareRepo: barePath, Worktree: wtPath, Branch: "lab/test"},
+		},
+	})
+
+	if err := mgr.RecoverJournals(); err != nil {
+		t.Fatalf("RecoverJournals: %v", err)
+	}
+
+	for _, p := range []string{
+		wtPath,
+		barePath,
+		filepath.Join(profilesDir, "_lab-snapshot-deadbeef.json"),
+		path,
+	} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s should have been removed by rollback", p)
+		}
+	}
+}
+
+func TestRecoverJournalsKeepsCompletedLab(t *testing.T) {
+	baseDir := t.TempDir()
+	t.Setenv("CLAUDEUP_HOME", t.TempDir())
+	mgr := lab.NewManager(baseDir)
+
+	wtPath := t.TempDir()
+	mgr.Store().Save(&lab.Metadata{ID: "abc-123", DisplayName: "done", Worktree: wtPath})
+
+	path := writeJournal(t, baseDir, lab.Journal{
+		LabID: "abc-123",
+		Steps: []lab.JournalStep{
+			{Action: "worktree", BareRepo: "/nonexistent.git", Worktree: wtPath},
+		},
+	})
+
+	if err := mgr.RecoverJournals(); err != nil {
+		t.Fatalf("RecoverJournals: %v", err)
+	}
+
+	if _, err := os.Stat(wtPath); err != nil {
+		t.Error("worktree of a completed lab should be kept")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("journal of a completed lab should be removed")
+	}
+}
+
+func TestRecoverJournalsMarksUnfinishedLabFailed(t *testing.T) {
+	baseDir := t.TempDir()
+	t.Setenv("CLAUDEUP_HOME", t.TempDir())
+	mgr := lab.NewManager(baseDir)
+
+	mgr.Store().Save(&lab.Metadata{ID: "abc-123", DisplayName: "half", State: lab.StateProvisioning})
+	writeJournal(t, baseDir, lab.Journal{LabID: "abc-123"})
+
+	if err := mgr.RecoverJournals(); err != nil {
+		t.Fatalf("RecoverJournals: %v", err)
+	}
+
+	meta, err := mgr.Store().Load("abc-123")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if meta.State != lab.StateFailed {
+		t.Errorf("State = %q, want %q", meta.State, lab.StateFailed)
+	}
+	if meta.LastError == "" {
+		t.Error("interrupted lab should record why it failed")
+	}
+}
+
+func TestPendingJournalsSkipsLiveProcess(t *testing.T) {
+	baseDir := t.TempDir()
+	t.Setenv("CLAUDEUP_HOME", t.TempDir())
+	mgr := lab.NewManager(baseDir)
+
+	// The parent of the test binary is alive for the duration of the test
+	writeJournal(t, baseDir, lab.Journal{LabID: "live", PID: os.Getppid()})
+	writeJournal(t, baseDir, lab.Journal{LabID: "dead"})
+
+	journals, err := mgr.PendingJournals()
+	if err != nil {
+		t.Fatalf("PendingJournals: %v", err)
+	}
+	if len(journals) != 1 || journals[0].LabID != "dead" {
+		t.Errorf("expected only the abandoned journal, got %d", len(journals))
+	}
+}
+
+func TestPendingJournalsSkipsCorruptJournal(t *testing.T) {
+	baseDir := t.TempDir()
+	t.Setenv("CLAUDEUP_HOME", t.TempDir())
+	mgr := lab.NewManager(baseDir)
+
+	writeJournal(t, baseDir, lab.Journal{LabID: "dead"})
+	corrupt := filepath.Join(baseDir, "journal", "torn.json")
+	if err := os.WriteFile(corrupt, []byte(`{"lab_id": "to`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	journals, err := mgr.PendingJournals()
+	if err != nil {
+		t.Fatalf("PendingJournals: %v", err)
+	}
+	if len(journals) != 1 || journals[0].LabID != "dead" {
+		t.Errorf("expected the readable journal despite a corrupt one, got %d", len(journals))
+	}
+}
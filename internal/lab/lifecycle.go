@@ -0,0 +1,90 @@
+package lab
+
+import (
+	"fmt"
+	"time"
+)
+
+// LabState is the persisted lifecycle state of a lab.
+type LabState string
+
+const (
+	StateCreating     LabState = "creating"
+	StateProvisioning LabState = "provisioning"
+	StateReady        LabState = "ready"
+	StateStopped      LabState = "stopped"
+	StateFailed       LabState = "failed"
+	StateRemoving     LabState = "removing"
+)
+
+// validTransitions lists the states each state may move to. The empty state
+// covers labs recorded before states were persisted. Moving to the current
+// state is always allowed and just records a new timestamp.
+var validTransitions = map[LabState][]LabState{
+	"":                {StateCreating, StateProvisioning, StateReady, StateStopped, StateFailed, StateRemoving},
+	StateCreating:     {StateProvisioning, StateFailed, StateRemoving},
+	StateProvisioning: {StateReady, StateFailed, StateRemoving},
+	StateReady:        {StateStopped, StateProvisioning, StateFailed, StateRemoving},
+	StateStopped:      {StateReady, StateProvisioning, StateFailed, StateRemoving},
+	StateFailed:       {StateProvisioning, StateRemoving},
+	StateRemoving:     {StateFailed},
+}
+
+// StateTransition records when a lab entered a state and, for failures, why.
+type StateTransition struct {
+	State LabState  `json:"state"`
+	At    time.Time `json:"at"`
+	Error string    `json:"error,omitempty"`
+}
+
+// InvalidTransitionError is returned when a lab is asked to move to a state
+// that cannot follow its current one.
+type InvalidTransitionError struct {
+	From LabState
+	To   LabState
+}
+
+func (e *InvalidTransitionError) Error() string {
+	from := e.From
+	if from == "" {
+		from = "unknown"
+	}
+	return fmt.Sprintf("invalid lab state transition: %s -> %s", from, e.To)
+}
+
+// CanTransition reports whether a lab in state from may move to state to.
+func CanTransition(from, to LabState) bool {
+	if from == to {
+		return true
+	}
+	for _, s := range validTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition moves a lab to a new state, appends it to the lab's history and
+// persists the metadata. A non-nil cause is recorded as the lab's last error
+// when moving to StateFailed; reaching StateReady clears it.
+func (s *StateStore) Transition(meta *Metadata, to LabState, cause error) error {
+	if !CanTransition(meta.State, to) {
+		return &InvalidTransitionError{From: meta.State, To: to}
+	}
+
+	t := StateTransition{State: to, At: time.Now().UTC()}
+	switch to {
+	case StateFailed:
+		if cause != nil {
+			t.Error = cause.Error()
+			meta.LastError = t.Error
+		}
+	case StateReady:
+		meta.LastError = ""
+	}
+
+	meta.State = to
+	meta.History = append(meta.History, t)
+	return s.Save(meta)
+}
@@ -0,0 +1,88 @@
+package lab_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to lab.LabState
+		want     bool
+	}{
+		{"", lab.StateCreating, true},
+		{"", lab.StateStopped, true},
+		{lab.StateCreating, lab.StateProvisioning, true},
+		{lab.StateProvisioning, lab.StateReady, true},
+		{lab.StateReady, lab.StateStopped, true},
+		{lab.StateStopped, lab.StateProvisioning, true},
+		{lab.StateFailed, lab.StateProvisioning, true},
+		{lab.StateReady, lab.StateReady, true},
+		{lab.StateCreating, lab.StateReady, false},
+		{lab.StateFailed, lab.StateReady, false},
+		{lab.StateRemoving, lab.StateReady, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := lab.CanTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransitionPersistsHistory(t *testing.T) {
+	store := lab.NewStateStore(t.TempDir())
+	meta := &lab.Metadata{ID: "abc-123", DisplayName: "myapp-base"}
+
+	if err := store.Transition(meta, lab.StateCreating, nil); err != nil {
+		t.Fatalf("Transition creating: %v", err)
+	}
+	if err := store.Transition(meta, lab.StateProvisioning, nil); err != nil {
+		t.Fatalf("Transition provisioning: %v", err)
+	}
+	if err := store.Transition(meta, lab.StateFailed, errors.New("devcontainer up: exit status 1")); err != nil {
+		t.Fatalf("Transition failed: %v", err)
+	}
+
+	loaded, err := store.Load("abc-123")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.State != lab.StateFailed {
+		t.Errorf("State = %q, want %q", loaded.State, lab.StateFailed)
+	}
+	if loaded.LastError != "devcontainer up: exit status 1" {
+		t.Errorf("LastError = %q", loaded.LastError)
+	}
+	if len(loaded.History) != 3 {
+		t.Fatalf("History has %d entries, want 3", len(loaded.History))
+	}
+	if loaded.History[0].At.IsZero() {
+		t.Error("transitions should be timestamped")
+	}
+
+	// Recovering to ready clears the last error
+	store.Transition(loaded, lab.StateProvisioning, nil)
+	store.Transition(loaded, lab.StateReady, nil)
+	if loaded.LastError != "" {
+		t.Errorf("LastError should be cleared on ready, got %q", loaded.LastError)
+	}
+}
+
+func TestTransitionRejectsInvalid(t *testing.T) {
+	store := lab.NewStateStore(t.TempDir())
+	meta := &lab.Metadata{ID: "abc-123", State: lab.StateRemoving}
+
+	err := store.Transition(meta, lab.StateReady, nil)
+	var invalid *lab.InvalidTransitionError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected InvalidTransitionError, got %v", err)
+	}
+	if meta.State != lab.StateRemoving {
+		t.Error("state should be unchanged after a rejected transition")
+	}
+}
@@ -0,0 +1,82 @@
+package lab_test
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/claudeup/claudeup-lab/internal/runner"
+)
+
+func TestAcquireLockIsExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "test.lock")
+
+	first, err := lab.AcquireLock(path)
+	if err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		second, err := lab.AcquireLock(path)
+		if err != nil {
+			t.Errorf("second AcquireLock: %v", err)
+		}
+		close(acquired)
+		second.Release()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second lock acquired while first was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	first.Release()
+
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("second lock not acquired after release")
+	}
+}
+
+func TestReleaseNilLock(t *testing.T) {
+	var l *lab.FileLock
+	if err := l.Release(); err != nil {
+		t.Errorf("Release on nil lock: %v", err)
+	}
+}
+
+func TestParallelCreateWorktreeSameBranch(t *testing.T) {
+	source := initTestRepo(t)
+	reposDir := filepath.Join(t.TempDir(), "repos")
+	wsDir := filepath.Join(t.TempDir(), "workspaces")
+
+	wt := lab.NewWorktreeManager(reposDir, runner.Default)
+	barePath, _, _ := wt.EnsureBareRepo(source, "testproject")
+
+	var wg sync.WaitGroup
+	branches := make([]string, 2)
+	errs := make([]error, 2)
+	for i := range branches {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path := filepath.Join(wsDir, []string{"lab1", "lab2"}[i])
+			branches[i], _, errs[i] = wt.CreateWorktree(barePath, path, "lab/test")
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("CreateWorktree %d: %v", i, err)
+		}
+	}
+	if branches[0] == branches[1] {
+		t.Errorf("parallel worktrees got the same branch %q", branches[0])
+	}
+}
@@ -0,0 +1,125 @@
+package lab
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Provisioning output -- the devcontainer engine's progress and everything
+// postCreateCommand prints -- is shown on the terminal and appended to a
+// per-lab log under <baseDir>/logs. Each line is stored with the time it
+// was written, so the log can be filtered with --since like container logs.
+
+// LogPath returns the provisioning log of a lab.
+func (m *Manager) LogPath(labID string) string {
+	return filepath.Join(m.baseDir, "logs", labID+".log")
+}
+
+// removeLog deletes a lab's provisioning log, if any.
+func (m *Manager) removeLog(labID string) error {
+	if err := os.Remove(m.LogPath(labID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove provisioning log: %w", err)
+	}
+	return nil
+}
+
+// openLog opens a lab's provisioning log for appending. The log may hold
+// anything the provisioning scripts print, so it is readable by the owner
+// only.
+func (m *Manager) openLog(labID string) (*os.File, error) {
+	path := m.LogPath(labID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open provisioning log: %w", err)
+	}
+	return f, nil
+}
+
+// timestampWriter prefixes every line written through it with the current
+// time in RFC 3339 format.
+type timestampWriter struct {
+	w       io.Writer
+	now     func() time.Time
+	midLine bool
+}
+
+func (t *timestampWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if !t.midLine {
+			if _, err := io.WriteString(t.w, t.now().UTC().Format(time.RFC3339Nano)+" "); err != nil {
+				return 0, err
+			}
+			t.midLine = true
+		}
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line = p[:i+1]
+			t.midLine = false
+		}
+		if _, err := t.w.Write(line); err != nil {
+			return 0, err
+		}
+		p = p[len(line):]
+	}
+	return n, nil
+}
+
+// LogEntry is one line of a provisioning log.
+type LogEntry struct {
+	Time time.Time `json:"time"`
+	Text string    `json:"text"`
+}
+
+// ReadLog returns the lines of a lab's provisioning log written at or
+// after since (all of them when since is zero), keeping only the last tail
+// lines when tail is positive. A lab that has never been provisioned has
+// no log and no lines.
+func (m *Manager) ReadLog(labID string, since time.Time, tail int) ([]LogEntry, error) {
+	f, err := os.Open(m.LogPath(labID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open provisioning log: %w", err)
+	}
+	defer f.Close()
+
+	var entries []LogEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry := parseLogLine(scanner.Text())
+		if !since.IsZero() && entry.Time.Before(since) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read provisioning log: %w", err)
+	}
+
+	if tail > 0 && len(entries) > tail {
+		entries = entries[len(entries)-tail:]
+	}
+	return entries, nil
+}
+
+func parseLogLine(line string) LogEntry {
+	stamp, text, ok := strings.Cut(line, " ")
+	if ok {
+		if t, err := time.Parse(time.RFC3339Nano, stamp); err == nil {
+			return LogEntry{Time: t, Text: text}
+		}
+	}
+	return LogEntry{Text: line}
+}
@@ -2,40 +2,118 @@ package lab
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/claudeup/claudeup-lab/internal/config"
+	"github.com/claudeup/claudeup-lab/internal/devcontainer"
 	"github.com/claudeup/claudeup-lab/internal/docker"
+	"github.com/claudeup/claudeup-lab/internal/runner"
 	"github.com/google/uuid"
 )
 
 // Manager orchestrates lab lifecycle operations.
 type Manager struct {
 	baseDir   string
+	config    *config.Config
+	configErr error
+	runtime   string
 	store     *StateStore
 	worktrees *WorktreeManager
 	profiles  *ProfileManager
-	docker    *docker.Client
+	docker    docker.Runtime
 	images    *docker.ImageManager
+	networks  *docker.NetworkManager
+	engine    devcontainer.Engine
+	run       runner.Runner
+	out       io.Writer
+
+	// spawn starts a detached port forwarder, which inherits its locked
+	// PID file, and returns its PID.
+	spawn func(labID string, p PortMapping, log, pidFile *os.File) (int, error)
 }
 
+// NewManager creates a Manager rooted at baseDir, reading the user config
+// from there. Config problems are reported by ConfigError and stop Start
+// and Resume; other commands fall back to defaults.
 func NewManager(baseDir string) *Manager {
+	return NewManagerWithRunner(baseDir, runner.Default)
+}
+
+// NewManagerWithRunner is NewManager with every external command (git,
+// the container runtime, claudeup, the devcontainer CLI) run through r.
+func NewManagerWithRunner(baseDir string, r runner.Runner) *Manager {
+	cfg, cfgErr := config.Load(baseDir)
+
+	runtime, rtErr := docker.SelectRuntime(cfg.Runtime)
+	if cfgErr == nil {
+		cfgErr = rtErr
+	}
+	engineName, engineErr := devcontainer.SelectEngine(cfg.Engine)
+	if cfgErr == nil {
+		cfgErr = engineErr
+	}
+	if cfgErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", cfgErr)
+	}
+
+	rt := docker.NewRuntime(runtime, r)
+	var engine devcontainer.Engine = devcontainer.NewBuiltinEngine(runtime, rt, r)
+	if engineName == devcontainer.EngineCLI {
+		engine = devcontainer.NewCLIEngine(runtime, r)
+	}
+
 	return &Manager{
 		baseDir:   baseDir,
+		config:    cfg,
+		configErr: cfgErr,
+		runtime:   runtime,
 		store:     NewStateStore(filepath.Join(baseDir, "state")),
-		worktrees: NewWorktreeManager(filepath.Join(baseDir, "repos")),
-		profiles:  NewProfileManager(filepath.Join(ClaudeupHome(), "profiles")),
-		docker:    docker.NewClient(),
-		images:    docker.NewImageManager(),
+		worktrees: NewWorktreeManager(filepath.Join(baseDir, "repos"), r),
+		profiles:  NewProfileManager(filepath.Join(ClaudeupHome(), "profiles"), r),
+		docker:    rt,
+		images:    docker.NewImageManagerFor(runtime, r),
+		networks:  docker.NewNetworkManagerFor(runtime, r),
+		engine:    engine,
+		run:       r,
+		out:       os.Stdout,
+		spawn:     spawnForwarder,
 	}
 }
 
-func (m *Manager) Store() *StateStore          { return m.store }
-func (m *Manager) Docker() *docker.Client      { return m.docker }
-func (m *Manager) Worktrees() *WorktreeManager { return m.worktrees }
+// Lock takes the global claudeup-lab lock, which serializes changes that
+// span several labs, such as choosing a unique display name.
+func (m *Manager) Lock() (*FileLock, error) {
+	return AcquireLock(filepath.Join(m.baseDir, "lab.lock"))
+}
+
+func (m *Manager) Store() *StateStore           { return m.store }
+func (m *Manager) Docker() docker.Runtime       { return m.docker }
+func (m *Manager) Images() *docker.ImageManager { return m.images }
+func (m *Manager) Worktrees() *WorktreeManager  { return m.worktrees }
+func (m *Manager) Config() *config.Config       { return m.config }
+func (m *Manager) ConfigError() error           { return m.configErr }
+
+// Runtime returns the name of the active container runtime.
+func (m *Manager) Runtime() string { return m.runtime }
+
+// SetProgress sends progress messages, including output from image pulls
+// and container setup, to w instead of stdout.
+func (m *Manager) SetProgress(w io.Writer) {
+	m.out = w
+	m.images.SetProgress(w)
+}
+
+// Runner returns the runner used for external commands.
+func (m *Manager) Runner() runner.Runner { return m.run }
+
+// Engine returns the devcontainer engine used to bring labs up and run
+// commands in them.
+func (m *Manager) Engine() devcontainer.Engine { return m.engine }
 
 // StartOptions configures a new lab.
 type StartOptions struct {
@@ -45,13 +123,77 @@ type StartOptions struct {
 	Name        string
 	Features    []string
 	BaseProfile string
+	Image       string // base image; empty means DefaultImage
+	Offline     bool   // use only local images and skip claude upgrade
+	Resources   Resources
+	Network     string   // NetworkFull, NetworkNone or NetworkAllowlist; empty means full
+	Allowlist   []string // hosts reachable in allowlist mode
+	Ports       []PortMapping
+	Env         map[string]string // the lab's own variables, from ResolveEnv
+
+	Isolation       string   // IsolationStandard or IsolationStrict; empty means standard
+	NoDefaultMounts []string // default host mounts to leave out, by name
+	Mounts          []MountSpec
+
+	// ClaudeVersion and ClaudeupVersion pin what provisioning installs;
+	// empty means the config file's default, else the latest.
+	ClaudeVersion   string
+	ClaudeupVersion string
 }
 
-// Start creates and launches a new lab environment.
-func (m *Manager) Start(opts *StartOptions) (*Metadata, error) {
+// Start creates and launches a new lab environment. Every side effect is
+// recorded in a journal first, so an error, SIGINT or SIGTERM undoes all
+// completed steps, and a crash is undone by the next Start.
+func (m *Manager) Start(opts *StartOptions) (meta *Metadata, err error) {
 	if err := m.checkPrerequisites(); err != nil {
 		return nil, err
 	}
+	if err := ValidateNetworkMode(opts.Network); err != nil {
+		return nil, err
+	}
+	network := opts.Network
+	if network == "" {
+		network = NetworkFull
+	}
+	if network == NetworkAllowlist {
+		if err := validateAllowlist(opts.Allowlist); err != nil {
+			return nil, err
+		}
+	}
+
+	ports, err := m.checkPorts(opts.Ports, network)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEnv(opts.Env, network); err != nil {
+		return nil, err
+	}
+	if err := checkMounts(opts); err != nil {
+		return nil, err
+	}
+	isolation := opts.Isolation
+	if isolation == "" {
+		isolation = IsolationStandard
+	}
+
+	// Versions to install
+	claudeVersion, claudeupVersion := m.DefaultVersions()
+	if opts.ClaudeVersion != "" {
+		claudeVersion = opts.ClaudeVersion
+	}
+	if opts.ClaudeupVersion != "" {
+		claudeupVersion = opts.ClaudeupVersion
+	}
+	if err := ValidateVersion("Claude Code", claudeVersion); err != nil {
+		return nil, err
+	}
+	if err := ValidateVersion("claudeup", claudeupVersion); err != nil {
+		return nil, err
+	}
+
+	if err := m.RecoverJournals(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
 
 	projectPath, err := filepath.Abs(opts.Project)
 	if err != nil {
@@ -60,16 +202,54 @@ func (m *Manager) Start(opts *StartOptions) (*Metadata, error) {
 	projectName := filepath.Base(projectPath)
 
 	// Verify it's a git repo
-	cmd := exec.Command("git", "-C", projectPath, "rev-parse", "--git-dir")
-	if err := cmd.Run(); err != nil {
+	if err := runner.Run(m.run, "git", "-C", projectPath, "rev-parse", "--git-dir"); err != nil {
 		return nil, fmt.Errorf("%s is not a git repository", projectPath)
 	}
 
+	registry, err := m.FeatureRegistry(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := registry.ResolveAll(opts.Features); err != nil {
+		return nil, err
+	}
+
+	labID := uuid.New().String()
+
+	journal, err := m.beginJournal(labID)
+	if err != nil {
+		return nil, err
+	}
+
+	interrupted := notifyInterrupt()
+	defer signal.Stop(interrupted)
+
+	// record is the lab's metadata once it has been persisted. On failure it
+	// stays behind in StateFailed so list can show what went wrong.
+	var record *Metadata
+
+	defer func() {
+		if err == nil {
+			return
+		}
+		if rbErr := m.rollback(journal); rbErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", rbErr)
+		}
+		if record != nil {
+			if tErr := m.store.Transition(record, StateFailed, err); tErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: record failed state: %v\n", tErr)
+			}
+		}
+	}()
+
 	// Handle profile snapshotting
 	profile := opts.Profile
 	var snapshotName string
 	if profile == "" {
 		id := uuid.New().String()[:8]
+		if err := journal.Record(JournalStep{Action: stepSnapshot, Profile: snapshotPrefix + id}); err != nil {
+			return nil, err
+		}
 		snapshotName, err = m.profiles.Snapshot(id)
 		if err != nil {
 			return nil, fmt.Errorf("snapshot current config: %w", err)
@@ -77,19 +257,62 @@ func (m *Manager) Start(opts *StartOptions) (*Metadata, error) {
 		profile = snapshotName
 	}
 
-	labID := uuid.New().String()
-
 	// Ensure base image
-	image := docker.ImageTag()
-	if err := m.images.EnsureImage(image); err != nil {
+	image := opts.Image
+	if image == "" {
+		image = m.DefaultImage()
+	}
+	// Only an offline start that was asked for is recorded; one that fell
+	// back because the registry could not be reached applies to this run
+	forcedOffline := opts.Offline
+	if env, set := offlineSetting(); set && env {
+		forcedOffline = true
+	}
+	offline, err := m.prepareImage(image, forcedOffline)
+	if err != nil {
 		return nil, fmt.Errorf("ensure base image: %w", err)
 	}
+	if offline && os.Getenv("CLAUDE_CONFIG_REPO") != "" {
+		return nil, fmt.Errorf("CLAUDE_CONFIG_REPO is cloned into new labs, which needs the network; unset it to start offline")
+	}
+	imageDigest, err := m.imageDigest(image)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	// Build the project's own Dockerfile on top of it
+	dockerfile, err := ProjectDockerfile(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	containerImage, layerImage := image, ""
+	if dockerfile != "" {
+		layerImage, err = m.images.BuildLayer(image, imageDigest, dockerfile, projectPath)
+		if err != nil && offline {
+			return nil, offlineError(err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		containerImage = layerImage
+	}
+	if err := checkInterrupted(interrupted); err != nil {
+		return nil, err
+	}
 
 	// Ensure bare clone
-	barePath, err := m.worktrees.EnsureBareRepo(projectPath, projectName)
+	barePath, cloned, err := m.worktrees.EnsureBareRepo(projectPath, projectName)
 	if err != nil {
 		return nil, fmt.Errorf("ensure bare repo: %w", err)
 	}
+	if cloned {
+		if err := journal.Record(JournalStep{Action: stepBareClone, BareRepo: barePath}); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkInterrupted(interrupted); err != nil {
+		return nil, err
+	}
 
 	// Compute display name
 	displayName := ComputeDisplayName(projectName, profile, opts.Name)
@@ -97,9 +320,25 @@ func (m *Manager) Start(opts *StartOptions) (*Metadata, error) {
 		return nil, err
 	}
 
+	// Hold the global lock from reading existing names until this lab's
+	// record is saved, so parallel starts cannot pick the same name.
+	lock, err := m.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
 	existingNames := make(map[string]bool)
 	if labs, _ := m.store.List(); labs != nil {
 		for _, l := range labs {
+			// A failed creation that was rolled back leaves only its record
+			// behind; a new lab with the same name supersedes it.
+			if l.DisplayName == displayName && l.State == StateFailed && !dirExists(l.Worktree) {
+				m.store.Delete(l.ID)
+				m.removeLog(l.ID)
+				m.removeAudit(l.ID)
+				continue
+			}
 			existingNames[l.DisplayName] = true
 		}
 	}
@@ -111,77 +350,330 @@ func (m *Manager) Start(opts *StartOptions) (*Metadata, error) {
 		branch = "lab/" + profile
 	}
 
-	// Create worktree
 	worktreePath := filepath.Join(m.baseDir, "workspaces", displayName)
-	branch, err = m.worktrees.CreateWorktree(barePath, worktreePath, branch)
+
+	record = &Metadata{
+		ID:          labID,
+		DisplayName: displayName,
+		Project:     projectPath,
+		ProjectName: projectName,
+		Profile:     profile,
+		BareRepo:    barePath,
+		Worktree:    worktreePath,
+		Branch:      branch,
+		Created:     time.Now().UTC(),
+		Snapshot:    snapshotName,
+		BaseProfile: opts.BaseProfile,
+		Features:    opts.Features,
+		Image:       image,
+		ImageDigest: imageDigest,
+		Offline:     forcedOffline,
+		Dockerfile:  dockerfile,
+		LayerImage:  layerImage,
+		Resources:   opts.Resources,
+		Network:     network,
+		Ports:       ports,
+
+		Isolation:       isolation,
+		NoDefaultMounts: opts.NoDefaultMounts,
+		CustomMounts:    opts.Mounts,
+
+		ClaudeVersion:   claudeVersion,
+		ClaudeupVersion: claudeupVersion,
+	}
+	if len(opts.Env) > 0 {
+		record.Env = sortedEnvKeys(opts.Env)
+	}
+	if network == NetworkAllowlist {
+		record.Allowlist = opts.Allowlist
+	}
+	if err := m.store.Transition(record, StateCreating, nil); err != nil {
+		record = nil
+		return nil, fmt.Errorf("save metadata: %w", err)
+	}
+	lock.Release()
+
+	// Create worktree. Whatever already sits at the path is not this lab's,
+	// so refuse it here rather than let a rollback delete it.
+	if _, err := os.Stat(worktreePath); err == nil {
+		return nil, fmt.Errorf("workspace %s already exists; remove it or choose another --name", worktreePath)
+	}
+	if err := journal.Record(JournalStep{Action: stepWorktree, BareRepo: barePath, Worktree: worktreePath}); err != nil {
+		return nil, err
+	}
+	branch, newBranch, err := m.worktrees.CreateWorktree(barePath, worktreePath, branch)
 	if err != nil {
 		return nil, fmt.Errorf("create worktree: %w", err)
 	}
+	if newBranch {
+		if err := journal.Amend(func(s *JournalStep) { s.Branch = branch }); err != nil {
+			return nil, err
+		}
+	}
+	record.Branch = branch
+	if err := checkInterrupted(interrupted); err != nil {
+		return nil, err
+	}
 
 	// Render devcontainer.json
-	dcConfig := &DevcontainerConfig{
-		ProjectName:  projectName,
-		Profile:      profile,
-		ID:           labID,
-		DisplayName:  displayName,
-		Image:        image,
-		BareRepoPath: barePath,
-		HomeDir:      os.Getenv("HOME"),
-		ClaudeupHome: ClaudeupHome(),
-		GitUserName:  gitConfig("user.name"),
-		GitUserEmail: gitConfig("user.email"),
-		GitHubToken:  os.Getenv("GITHUB_TOKEN"),
-		Context7Key:  os.Getenv("CONTEXT7_API_KEY"),
-		ConfigRepo:   os.Getenv("CLAUDE_CONFIG_REPO"),
-		ConfigBranch: envOrDefault("CLAUDE_CONFIG_BRANCH", "main"),
-		BaseProfile:  opts.BaseProfile,
-		Features:     opts.Features,
-	}
+	dcConfig := m.devcontainerConfig(record, containerImage)
+	dcConfig.Env = opts.Env
+	dcConfig.Offline = offline
 	if err := RenderDevcontainer(dcConfig, worktreePath); err != nil {
-		m.worktrees.RemoveWorktree(barePath, worktreePath)
 		return nil, fmt.Errorf("render devcontainer: %w", err)
 	}
 
+	// Fingerprint the host paths the lab can see before it sees them
+	if err := m.fingerprintHost(record); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: host fingerprint: %v\n", err)
+	}
+
+	// Private network and egress proxy
+	if network == NetworkAllowlist {
+		if err := journal.Record(JournalStep{Action: stepNetwork}); err != nil {
+			return nil, err
+		}
+		if err := m.startProxy(record, image); err != nil {
+			return nil, fmt.Errorf("start egress proxy: %w", err)
+		}
+	}
+
 	// Launch container
-	fmt.Println("Starting devcontainer...")
-	devCmd := exec.Command("devcontainer", "up", "--workspace-folder", worktreePath)
-	devCmd.Stdout = os.Stdout
-	devCmd.Stderr = os.Stderr
-	if err := devCmd.Run(); err != nil {
-		m.worktrees.RemoveWorktree(barePath, worktreePath)
-		return nil, fmt.Errorf("devcontainer up: %w", err)
+	if err := journal.Record(JournalStep{Action: stepVolumes}); err != nil {
+		return nil, err
+	}
+	if err := journal.Record(JournalStep{Action: stepContainer, Worktree: worktreePath}); err != nil {
+		return nil, err
+	}
+	if err := m.store.Transition(record, StateProvisioning, nil); err != nil {
+		return nil, fmt.Errorf("save metadata: %w", err)
+	}
+	fmt.Fprintln(m.out, "Starting devcontainer...")
+	if err := m.devcontainerUp(record, "start"); err != nil {
+		if checkInterrupted(interrupted) != nil {
+			return nil, ErrInterrupted
+		}
+		if offline {
+			return nil, offlineError(err)
+		}
+		return nil, err
+	}
+	if err := checkInterrupted(interrupted); err != nil {
+		return nil, err
 	}
 
-	// Save metadata
-	meta := &Metadata{
-		ID:          labID,
-		DisplayName: displayName,
-		Project:     projectPath,
-		ProjectName: projectName,
-		Profile:     profile,
-		BareRepo:    barePath,
-		Worktree:    worktreePath,
-		Branch:      branch,
-		Created:     time.Now().UTC(),
-		Snapshot:    snapshotName,
+	// Record what was installed, so labs can be compared
+	claude, claudeup := m.installedVersions(record)
+	if claude != "" {
+		record.ClaudeVersion = claude
 	}
-	if err := m.store.Save(meta); err != nil {
+	if claudeup != "" {
+		record.ClaudeupVersion = claudeup
+	}
+
+	if err := m.store.Transition(record, StateReady, nil); err != nil {
 		return nil, fmt.Errorf("save metadata: %w", err)
 	}
+	m.startForwarders(record)
+
+	if err := journal.Commit(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
 
-	return meta, nil
+	return record, nil
 }
 
-// LabStatus returns the running status of a lab.
+// Resume brings a stopped lab back up. It reuses the existing worktree and
+// per-lab volumes instead of creating new ones, and re-renders
+// devcontainer.json only if it has gone missing.
+func (m *Manager) Resume(meta *Metadata) error {
+	if err := m.checkPrerequisites(); err != nil {
+		return err
+	}
+
+	if id, _ := m.docker.FindContainer(meta.Worktree); id != "" {
+		if meta.State != StateReady {
+			m.store.Transition(meta, StateReady, nil)
+		}
+		return &AlreadyRunningError{DisplayName: meta.DisplayName}
+	}
+
+	if !m.CanResume(meta) {
+		return fmt.Errorf("lab %s cannot be resumed: worktree or bare repo is missing (remove it with: claudeup-lab rm --lab %s)",
+			meta.DisplayName, meta.DisplayName)
+	}
+
+	if err := m.store.Transition(meta, StateProvisioning, nil); err != nil {
+		return err
+	}
+
+	if err := m.resume(meta); err != nil {
+		if tErr := m.store.Transition(meta, StateFailed, err); tErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: record failed state: %v\n", tErr)
+		}
+		return err
+	}
+
+	return m.store.Transition(meta, StateReady, nil)
+}
+
+func (m *Manager) resume(meta *Metadata) error {
+	dcPath := devcontainer.ConfigPath(meta.Worktree)
+	image := meta.Image
+	if image == "" {
+		image = m.DefaultImage()
+	}
+	if _, err := os.Stat(dcPath); os.IsNotExist(err) {
+		offline, err := m.prepareImage(image, meta.Offline)
+		if err != nil {
+			return fmt.Errorf("ensure base image: %w", err)
+		}
+		if meta.Dockerfile != "" {
+			layer, err := m.layerImage(meta, image)
+			if err != nil {
+				return err
+			}
+			image = layer
+		}
+		fmt.Fprintln(m.out, "Re-rendering missing devcontainer.json...")
+		dcConfig := m.devcontainerConfig(meta, image)
+		dcConfig.Offline = offline
+		if err := RenderDevcontainer(dcConfig, meta.Worktree); err != nil {
+			return fmt.Errorf("render devcontainer: %w", err)
+		}
+	} else if cfg, err := devcontainer.LoadConfig(meta.Worktree); err == nil {
+		image = cfg.Image
+		if names, err := m.SecretNames(); err == nil {
+			if err := scrubDevcontainer(meta.Worktree, names); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: remove secrets from devcontainer.json: %v\n", err)
+			}
+		}
+	}
+
+	// Labs started before host fingerprints were taken get one now
+	if err := m.ensureFingerprint(meta); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: host fingerprint: %v\n", err)
+	}
+
+	if meta.Network == NetworkAllowlist {
+		if err := m.startProxy(meta, image); err != nil {
+			return fmt.Errorf("start egress proxy: %w", err)
+		}
+	}
+
+	fmt.Fprintln(m.out, "Starting devcontainer...")
+	if err := m.devcontainerUp(meta, "resume"); err != nil {
+		return err
+	}
+	m.startForwarders(meta)
+	return nil
+}
+
+// CanResume reports whether a lab has everything needed to be brought back
+// up with Resume: a worktree on disk and the bare repo it was created from.
+func (m *Manager) CanResume(meta *Metadata) bool {
+	if _, err := os.Stat(meta.Worktree); err != nil {
+		return false
+	}
+	if _, err := os.Stat(meta.BareRepo); err != nil {
+		return false
+	}
+	return true
+}
+
+// AlreadyRunningError is returned by Resume when the lab's container is
+// already up.
+type AlreadyRunningError struct {
+	DisplayName string
+}
+
+func (e *AlreadyRunningError) Error() string {
+	return fmt.Sprintf("lab %s is already running", e.DisplayName)
+}
+
+// LabStatus returns the status of a lab for display. Transitional and
+// failed states are reported as recorded. Ready and stopped labs are
+// reported as "running" or "stopped" from what Docker shows now, and any
+// drift (a container that exited or was started outside claudeup-lab) is
+// persisted. A lab whose worktree has disappeared is reported as orphaned.
 func (m *Manager) LabStatus(meta *Metadata) string {
-	id, _ := m.docker.FindContainer(meta.Worktree)
-	if id != "" {
+	switch meta.State {
+	case StateCreating, StateProvisioning, StateFailed, StateRemoving:
+		return string(meta.State)
+	}
+
+	if !dirExists(meta.Worktree) {
+		return "orphaned"
+	}
+
+	observed := StateStopped
+	if id, _ := m.docker.FindContainer(meta.Worktree); id != "" {
+		observed = StateReady
+	}
+	if meta.State != observed {
+		if err := m.reconcileState(meta, observed); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: reconcile state of %s: %v\n", meta.DisplayName, err)
+		}
+	}
+	if observed == StateReady {
 		return "running"
 	}
-	if _, err := os.Stat(meta.Worktree); err == nil {
-		return "stopped"
+	return string(StateStopped)
+}
+
+// reconcileState records the state Docker shows for a ready or stopped lab.
+// The record is reloaded under the global lock, so a lifecycle command that
+// moved the lab on in the meantime is not overwritten.
+func (m *Manager) reconcileState(meta *Metadata, observed LabState) error {
+	lock, err := m.Lock()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	current, err := m.store.Load(meta.ID)
+	if err != nil {
+		return err
+	}
+	if current.State != meta.State {
+		return nil
+	}
+	if err := m.store.Transition(current, observed, nil); err != nil {
+		return err
 	}
-	return "orphaned"
+	*meta = *current
+	return nil
+}
+
+// Stop stops the lab's running container. It returns false if no running
+// container was found, recording a ready lab whose container exited on its
+// own as stopped.
+func (m *Manager) Stop(meta *Metadata) (bool, error) {
+	containerID, err := m.docker.FindContainer(meta.Worktree)
+	if err != nil {
+		return false, err
+	}
+	if err := m.stopForwarders(meta); err != nil {
+		return false, fmt.Errorf("stop port forwarders: %w", err)
+	}
+	if containerID == "" {
+		if err := m.stopProxy(meta.ID); err != nil {
+			return false, err
+		}
+		if meta.State == StateReady {
+			return false, m.store.Transition(meta, StateStopped, nil)
+		}
+		return false, nil
+	}
+
+	if err := m.docker.StopContainer(containerID); err != nil {
+		return false, err
+	}
+	if err := m.stopProxy(meta.ID); err != nil {
+		return true, fmt.Errorf("stop egress proxy: %w", err)
+	}
+
+	return true, m.store.Transition(meta, StateStopped, nil)
 }
 
 // Remove performs a full teardown of a lab.
@@ -192,35 +684,57 @@ func (m *Manager) Remove(meta *Metadata, confirmed bool) error {
 
 	var errs []string
 
+	if err := m.store.Transition(meta, StateRemoving, nil); err != nil {
+		errs = append(errs, fmt.Sprintf("record removing state: %v", err))
+	}
+
+	if err := m.stopForwarders(meta); err != nil {
+		errs = append(errs, fmt.Sprintf("stop port forwarders: %v", err))
+	}
+
 	// Stop and remove container
 	containerID, _ := m.docker.FindContainerIncludingStopped(meta.Worktree)
 	if containerID != "" {
-		fmt.Println("Removing container...")
+		fmt.Fprintln(m.out, "Removing container...")
 		if err := m.docker.RemoveContainer(containerID); err != nil {
 			errs = append(errs, fmt.Sprintf("remove container: %v", err))
 		}
 	}
 
+	// Remove egress proxy and private network
+	if meta.Network == NetworkAllowlist {
+		fmt.Fprintln(m.out, "Removing egress proxy...")
+		if err := m.removeProxy(meta.ID); err != nil {
+			errs = append(errs, fmt.Sprintf("remove egress proxy: %v", err))
+		}
+	}
+
 	// Remove volumes
-	fmt.Println("Removing Docker volumes...")
+	fmt.Fprintln(m.out, "Removing Docker volumes...")
 	volumes, _ := m.docker.ListVolumes(meta.ID)
 	if len(volumes) > 0 {
-		if err := m.docker.RemoveVolumes(volumes); err != nil {
+		if err := m.docker.RemoveVolumes(docker.VolumeNames(volumes)); err != nil {
 			errs = append(errs, fmt.Sprintf("remove volumes: %v", err))
 		}
 	}
 
 	// Remove worktree
-	fmt.Println("Removing worktree...")
+	fmt.Fprintln(m.out, "Removing worktree...")
 	if err := m.worktrees.RemoveWorktree(meta.BareRepo, meta.Worktree); err != nil {
 		errs = append(errs, fmt.Sprintf("remove worktree: %v", err))
 	}
 
 	// Remove metadata
-	fmt.Println("Removing metadata...")
+	fmt.Fprintln(m.out, "Removing metadata...")
 	if err := m.store.Delete(meta.ID); err != nil {
 		errs = append(errs, fmt.Sprintf("remove metadata: %v", err))
 	}
+	if err := m.removeLog(meta.ID); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if err := m.removeAudit(meta.ID); err != nil {
+		errs = append(errs, err.Error())
+	}
 
 	// Clean up snapshot profile if applicable
 	if meta.Snapshot != "" {
@@ -231,7 +745,7 @@ func (m *Manager) Remove(meta *Metadata, confirmed bool) error {
 		fmt.Fprintf(os.Stderr, "Warning: partial cleanup errors: %s\n", strings.Join(errs, "; "))
 	}
 
-	fmt.Printf("Removed lab: %s\n", meta.DisplayName)
+	fmt.Fprintf(m.out, "Removed lab: %s\n", meta.DisplayName)
 
 	// Check if bare repo has remaining worktrees
 	count, err := m.worktrees.WorktreeCount(meta.BareRepo)
@@ -251,26 +765,109 @@ func (e *BareRepoCleanupPrompt) Error() string {
 	return fmt.Sprintf("bare repo %s has no remaining worktrees", e.BareRepo)
 }
 
+// devcontainerConfig builds the render parameters for a lab from its
+// metadata and the host environment.
+func (m *Manager) devcontainerConfig(meta *Metadata, image string) *DevcontainerConfig {
+	return &DevcontainerConfig{
+		ProjectName:  meta.ProjectName,
+		Profile:      meta.Profile,
+		ID:           meta.ID,
+		DisplayName:  meta.DisplayName,
+		Image:        image,
+		BareRepoPath: meta.BareRepo,
+		HomeDir:      os.Getenv("HOME"),
+		ClaudeupHome: ClaudeupHome(),
+		GitUserName:  m.gitConfig("user.name"),
+		GitUserEmail: m.gitConfig("user.email"),
+		ConfigRepo:   os.Getenv("CLAUDE_CONFIG_REPO"),
+		ConfigBranch: envOrDefault("CLAUDE_CONFIG_BRANCH", "main"),
+		BaseProfile:  meta.BaseProfile,
+		Features:     meta.Features,
+		Registry:     m.labFeatures(meta),
+		Runtime:      m.runtime,
+		Resources:    meta.Resources,
+		Network:      meta.Network,
+		Offline:      meta.Offline,
+		Ports:        meta.Ports,
+		Env:          m.labEnv(meta),
+
+		Isolation:       meta.Isolation,
+		NoDefaultMounts: meta.NoDefaultMounts,
+		Mounts:          meta.CustomMounts,
+
+		ClaudeVersion:   meta.ClaudeVersion,
+		ClaudeupVersion: meta.ClaudeupVersion,
+	}
+}
+
+// devcontainerUp creates or restarts the devcontainer for a lab. Both
+// engines find an existing container by its local_folder label, so calling
+// this on a stopped lab starts the same container again. The engine's
+// output is also appended to the lab's provisioning log, between markers
+// naming the action and how it ended. Secret values are redacted from both.
+func (m *Manager) devcontainerUp(meta *Metadata, action string) error {
+	secrets, err := m.Secrets()
+	if err != nil {
+		return err
+	}
+	redactor := NewRedactor(secrets)
+
+	f, err := m.openLog(meta.ID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		out := redactor.Writer(m.out)
+		defer out.Close()
+		return m.engine.Up(meta.Worktree, secrets, out)
+	}
+	defer f.Close()
+
+	log := redactor.Writer(&timestampWriter{w: f, now: time.Now})
+	defer log.Close()
+	out := redactor.Writer(m.out)
+	defer out.Close()
+
+	fmt.Fprintf(log, "=== %s %s ===\n", action, meta.DisplayName)
+	err = m.engine.Up(meta.Worktree, secrets, io.MultiWriter(out, log))
+	if err != nil {
+		fmt.Fprintf(log, "=== %s failed: %v ===\n", action, err)
+		return err
+	}
+	fmt.Fprintf(log, "=== %s finished ===\n", action)
+	return nil
+}
+
 func (m *Manager) checkPrerequisites() error {
+	if m.configErr != nil {
+		return m.configErr
+	}
 	if !m.docker.IsRunning() {
+		if m.runtime == docker.RuntimePodman {
+			return fmt.Errorf("podman is not available (install podman; on macOS run: podman machine start)")
+		}
 		return fmt.Errorf("Docker is not running (start Docker Desktop or the docker daemon)")
 	}
-	if _, err := exec.LookPath("devcontainer"); err != nil {
-		return fmt.Errorf("devcontainer CLI not found (install: npm install -g @devcontainers/cli)")
+	if m.engine.Name() == devcontainer.EngineCLI {
+		if _, err := m.run.LookPath("devcontainer"); err != nil {
+			return fmt.Errorf("devcontainer CLI not found (install: npm install -g @devcontainers/cli, or use the built-in engine)")
+		}
 	}
-	if _, err := exec.LookPath("git"); err != nil {
+	if _, err := m.run.LookPath("git"); err != nil {
 		return fmt.Errorf("git not found on PATH")
 	}
 	return nil
 }
 
-func gitConfig(key string) string {
-	cmd := exec.Command("git", "config", key)
-	out, err := cmd.Output()
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func (m *Manager) gitConfig(key string) string {
+	out, err := runner.Output(m.run, "git", "config", key)
 	if err != nil {
 		return ""
 	}
-	return string(out[:len(out)-1]) // trim newline
+	return strings.TrimSuffix(string(out), "\n")
 }
 
 func envOrDefault(key, def string) string {
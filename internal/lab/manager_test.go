@@ -0,0 +1,71 @@
+package lab_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+)
+
+func TestCanResume(t *testing.T) {
+	mgr := lab.NewManager(t.TempDir())
+
+	worktree := t.TempDir()
+	bare := t.TempDir()
+
+	meta := &lab.Metadata{ID: "abc-123", DisplayName: "myapp-base", Worktree: worktree, BareRepo: bare}
+	if !mgr.CanResume(meta) {
+		t.Error("lab with worktree and bare repo should be resumable")
+	}
+
+	os.RemoveAll(worktree)
+	if mgr.CanResume(meta) {
+		t.Error("lab with missing worktree should not be resumable")
+	}
+
+	meta.Worktree = t.TempDir()
+	meta.BareRepo = filepath.Join(t.TempDir(), "missing.git")
+	if mgr.CanResume(meta) {
+		t.Error("lab with missing bare repo should not be resumable")
+	}
+}
+
+func TestManagerRuntimeFromConfig(t *testing.T) {
+	baseDir := t.TempDir()
+	t.Setenv("CLAUDEUP_LAB_RUNTIME", "")
+	os.WriteFile(filepath.Join(baseDir, "config.json"), []byte(`{"runtime": "podman"}`), 0o644)
+
+	mgr := lab.NewManager(baseDir)
+	if mgr.Runtime() != "podman" {
+		t.Fatalf("Runtime = %q, want %q", mgr.Runtime(), "podman")
+	}
+}
+
+func TestManagerEngineFromConfig(t *testing.T) {
+	t.Setenv("CLAUDEUP_LAB_ENGINE", "")
+
+	mgr := lab.NewManager(t.TempDir())
+	if mgr.Engine().Name() != "builtin" {
+		t.Errorf("default engine = %q, want %q", mgr.Engine().Name(), "builtin")
+	}
+
+	baseDir := t.TempDir()
+	os.WriteFile(filepath.Join(baseDir, "config.json"), []byte(`{"engine": "cli"}`), 0o644)
+	mgr = lab.NewManager(baseDir)
+	if mgr.Engine().Name() != "cli" {
+		t.Errorf("configured engine = %q, want %q", mgr.Engine().Name(), "cli")
+	}
+}
+
+func TestManagerUnknownRuntime(t *testing.T) {
+	t.Setenv("CLAUDEUP_LAB_RUNTIME", "containerd")
+
+	mgr := lab.NewManager(t.TempDir())
+	if mgr.ConfigError() == nil {
+		t.Error("unknown runtime should be reported as a config error")
+	}
+	if mgr.Runtime() != "docker" {
+		t.Errorf("Runtime = %q, want fallback %q", mgr.Runtime(), "docker")
+	}
+}
@@ -0,0 +1,177 @@
+package lab
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Isolation levels. Standard bind-mounts the host configuration a lab
+// needs, some of it writable; strict leaves only the lab's own worktree
+// and bare repo writable on the host, leaving out ~/.claude-mem and giving
+// the lab a copy of ~/.claude.json instead of the file itself.
+const (
+	IsolationStandard = "standard"
+	IsolationStrict   = "strict"
+)
+
+// Names of the default host mounts, as accepted by --no-default-mount.
+const (
+	MountProfiles   = "profiles"
+	MountExt        = "ext"
+	MountClaudeMem  = "claude-mem"
+	MountSSH        = "ssh"
+	MountSettings   = "settings"
+	MountClaudeJSON = "claude-json"
+)
+
+// DefaultMountNames lists the default host mounts in plan order.
+var DefaultMountNames = []string{MountProfiles, MountExt, MountClaudeMem, MountSSH, MountSettings, MountClaudeJSON}
+
+// hostClaudeJSON is where strict labs see the host's ~/.claude.json before
+// it is copied into the config volume.
+const hostClaudeJSON = "/tmp/host-claude.json"
+
+// ValidateIsolation checks an --isolation value. Empty means standard.
+func ValidateIsolation(level string) error {
+	switch level {
+	case "", IsolationStandard, IsolationStrict:
+		return nil
+	}
+	return fmt.Errorf("unknown isolation level %q (supported: %s, %s)", level, IsolationStandard, IsolationStrict)
+}
+
+// validateMountNames rejects names that are not default mounts.
+func validateMountNames(names []string) error {
+	for _, name := range names {
+		if !slices.Contains(DefaultMountNames, name) {
+			return fmt.Errorf("unknown default mount %q (supported: %s)", name, strings.Join(DefaultMountNames, ", "))
+		}
+	}
+	return nil
+}
+
+// MountSpec is a bind mount a user added to a lab with --mount.
+type MountSpec struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+	Relabel  bool   `json:"relabel,omitempty"` // relabel for SELinux under podman
+}
+
+// String formats the mount as accepted by ParseMountSpec.
+func (s MountSpec) String() string {
+	var opts []string
+	if s.ReadOnly {
+		opts = append(opts, "ro")
+	}
+	if s.Relabel {
+		opts = append(opts, "z")
+	}
+	if len(opts) == 0 {
+		return s.Source + ":" + s.Target
+	}
+	return s.Source + ":" + s.Target + ":" + strings.Join(opts, ",")
+}
+
+// ParseMountSpec parses a --mount value: "src:dst" or "src:dst:opts",
+// where opts is a comma-separated list of ro or rw and z, which relabels
+// the source for SELinux under podman. A relative source is taken from
+// the current directory; the target must be absolute.
+func ParseMountSpec(s string) (MountSpec, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return MountSpec{}, fmt.Errorf("invalid mount %q (want src:dst or src:dst:ro)", s)
+	}
+	var spec MountSpec
+	if len(parts) == 3 {
+		rw := false
+		for _, opt := range strings.Split(parts[2], ",") {
+			switch opt {
+			case "ro":
+				spec.ReadOnly = true
+			case "rw":
+				rw = true
+			case "z":
+				spec.Relabel = true
+			default:
+				return MountSpec{}, fmt.Errorf("invalid mount %q: options must be ro or rw, and z", s)
+			}
+		}
+		if spec.ReadOnly && rw {
+			return MountSpec{}, fmt.Errorf("invalid mount %q: ro and rw are exclusive", s)
+		}
+	}
+
+	source, err := filepath.Abs(parts[0])
+	if err != nil {
+		return MountSpec{}, fmt.Errorf("invalid mount %q: %w", s, err)
+	}
+	spec.Source = source
+	if !filepath.IsAbs(parts[1]) {
+		return MountSpec{}, fmt.Errorf("invalid mount %q: target must be an absolute path", s)
+	}
+	spec.Target = filepath.Clean(parts[1])
+
+	// devcontainer.json mount strings are comma-separated
+	if strings.Contains(spec.Source, ",") || strings.Contains(spec.Target, ",") {
+		return MountSpec{}, fmt.Errorf("invalid mount %q: paths cannot contain commas", s)
+	}
+	return spec, nil
+}
+
+// DefaultMountPolicy returns the isolation level and the default mounts
+// to leave out of new labs, from the config file.
+func (m *Manager) DefaultMountPolicy() (string, []string, error) {
+	isolation := m.config.Isolation
+	if err := ValidateIsolation(isolation); err != nil {
+		return "", nil, fmt.Errorf("config: %w", err)
+	}
+	if isolation == "" {
+		isolation = IsolationStandard
+	}
+	if err := validateMountNames(m.config.NoDefaultMounts); err != nil {
+		return "", nil, fmt.Errorf("config: %w", err)
+	}
+	return isolation, m.config.NoDefaultMounts, nil
+}
+
+// checkMounts validates a new lab's mount policy: the default mounts it
+// leaves out must be known by name, and its own mounts need a source on the host and
+// a target no other mount uses. Strict labs may only add read-only mounts.
+func checkMounts(opts *StartOptions) error {
+	if err := ValidateIsolation(opts.Isolation); err != nil {
+		return err
+	}
+	if err := validateMountNames(opts.NoDefaultMounts); err != nil {
+		return err
+	}
+
+	config := &DevcontainerConfig{
+		HomeDir:         os.Getenv("HOME"),
+		ClaudeupHome:    ClaudeupHome(),
+		Isolation:       opts.Isolation,
+		NoDefaultMounts: opts.NoDefaultMounts,
+	}
+	targets := make(map[string]bool)
+	for _, mount := range planMounts(config) {
+		if mount.Applied {
+			targets[mount.Target] = true
+		}
+	}
+	for _, spec := range opts.Mounts {
+		if opts.Isolation == IsolationStrict && !spec.ReadOnly {
+			return fmt.Errorf("mount %s: strict isolation only allows read-only mounts (add :ro)", spec)
+		}
+		if _, err := os.Stat(spec.Source); err != nil {
+			return fmt.Errorf("mount %s: source does not exist", spec)
+		}
+		if targets[spec.Target] {
+			return fmt.Errorf("mount %s: %s is already mounted (drop the default with --no-default-mount)", spec, spec.Target)
+		}
+		targets[spec.Target] = true
+	}
+	return nil
+}
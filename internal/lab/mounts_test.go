@@ -0,0 +1,129 @@
+package lab_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+)
+
+func TestParseMountSpec(t *testing.T) {
+	m, err := lab.ParseMountSpec("/data:/mnt/data:ro")
+	if err != nil || m.Source != "/data" || m.Target != "/mnt/data" || !m.ReadOnly {
+		t.Errorf("ParseMountSpec(ro) = %+v, %v", m, err)
+	}
+	m, err = lab.ParseMountSpec("/data:/mnt/data/")
+	if err != nil || m.Target != "/mnt/data" || m.ReadOnly {
+		t.Errorf("ParseMountSpec(rw) = %+v, %v", m, err)
+	}
+	if m.String() != "/data:/mnt/data" {
+		t.Errorf("String = %q", m.String())
+	}
+
+	m, err = lab.ParseMountSpec("/data:/mnt/data:ro,z")
+	if err != nil || !m.ReadOnly || !m.Relabel {
+		t.Errorf("ParseMountSpec(ro,z) = %+v, %v", m, err)
+	}
+	if m.String() != "/data:/mnt/data:ro,z" {
+		t.Errorf("String = %q", m.String())
+	}
+
+	wd, _ := os.Getwd()
+	m, err = lab.ParseMountSpec("testdata:/mnt/testdata:rw")
+	if err != nil || m.Source != filepath.Join(wd, "testdata") {
+		t.Errorf("relative source = %+v, %v; want it resolved against %s", m, err, wd)
+	}
+
+	for _, bad := range []string{"/data", "/data:", ":/mnt", "/data:mnt", "/data:/mnt:rx", "/a:/b:ro:x", "/a:/b:ro,rw", "/a:/b:Z", "/a,b:/mnt"} {
+		if _, err := lab.ParseMountSpec(bad); err == nil {
+			t.Errorf("ParseMountSpec(%q) should fail", bad)
+		}
+	}
+}
+
+func TestMountPolicy(t *testing.T) {
+	home := t.TempDir()
+	os.MkdirAll(filepath.Join(home, ".ssh"), 0o700)
+	os.MkdirAll(filepath.Join(home, ".claude-mem"), 0o755)
+	os.WriteFile(filepath.Join(home, ".claude.json"), []byte("{}"), 0o600)
+	data := t.TempDir()
+
+	render := func(config *lab.DevcontainerConfig) (mounts []string, postCreate string) {
+		t.Helper()
+		config.ProjectName, config.Profile, config.ID, config.DisplayName = "myapp", "base", "abc-123", "myapp-base"
+		config.Image, config.BareRepoPath, config.HomeDir = "test:latest", "/tmp/bare.git", home
+		dir := t.TempDir()
+		if err := lab.RenderDevcontainer(config, dir); err != nil {
+			t.Fatalf("RenderDevcontainer: %v", err)
+		}
+		raw, _ := os.ReadFile(filepath.Join(dir, ".devcontainer", "devcontainer.json"))
+		var parsed struct {
+			Mounts     []string `json:"mounts"`
+			PostCreate string   `json:"postCreateCommand"`
+		}
+		json.Unmarshal(raw, &parsed)
+		return parsed.Mounts, parsed.PostCreate
+	}
+	has := func(mounts []string, substr string) bool {
+		for _, m := range mounts {
+			if strings.Contains(m, substr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	mounts, postCreate := render(&lab.DevcontainerConfig{})
+	if !has(mounts, "target=/home/node/.claude.json,type=bind") || !has(mounts, "target=/home/node/.claude-mem,type=bind") {
+		t.Errorf("standard labs should bind-mount ~/.claude.json and ~/.claude-mem writable: %v", mounts)
+	}
+	if strings.Contains(postCreate, "host-claude.json") {
+		t.Errorf("standard labs need no copy of ~/.claude.json: %q", postCreate)
+	}
+
+	mounts, _ = render(&lab.DevcontainerConfig{
+		NoDefaultMounts: []string{lab.MountSSH},
+		Mounts:          []lab.MountSpec{{Source: data, Target: "/data", ReadOnly: true}},
+	})
+	if has(mounts, ".ssh") {
+		t.Errorf("ssh mount should be left out: %v", mounts)
+	}
+	if !has(mounts, "source="+data+",target=/data,type=bind,readonly") {
+		t.Errorf("custom mount missing: %v", mounts)
+	}
+
+	mounts, postCreate = render(&lab.DevcontainerConfig{Network: lab.NetworkNone, Isolation: lab.IsolationStrict})
+	if has(mounts, ".claude-mem") || has(mounts, "target=/home/node/.claude.json") {
+		t.Errorf("strict labs should not bind-mount writable host state: %v", mounts)
+	}
+	if !has(mounts, "target=/tmp/host-claude.json,type=bind,readonly") {
+		t.Errorf("strict labs should see ~/.claude.json read-only: %v", mounts)
+	}
+	if !strings.Contains(postCreate, "cp /tmp/host-claude.json /home/node/.claude/.claude.json") ||
+		strings.Contains(postCreate, "claude upgrade") {
+		t.Errorf("postCreateCommand = %q, want the copy and no upgrade", postCreate)
+	}
+}
+
+func TestDefaultMountPolicyFromConfig(t *testing.T) {
+	isolation, skip, err := lab.NewManager(t.TempDir()).DefaultMountPolicy()
+	if err != nil || isolation != lab.IsolationStandard || len(skip) != 0 {
+		t.Errorf("DefaultMountPolicy = %q, %v, %v; want standard with every default mount", isolation, skip, err)
+	}
+
+	baseDir := t.TempDir()
+	os.WriteFile(filepath.Join(baseDir, "config.json"), []byte(`{"isolation": "strict", "no_default_mounts": ["ssh"]}`), 0o644)
+	isolation, skip, err = lab.NewManager(baseDir).DefaultMountPolicy()
+	if err != nil || isolation != lab.IsolationStrict || strings.Join(skip, ",") != "ssh" {
+		t.Errorf("DefaultMountPolicy = %q, %v, %v", isolation, skip, err)
+	}
+
+	baseDir = t.TempDir()
+	os.WriteFile(filepath.Join(baseDir, "config.json"), []byte(`{"no_default_mounts": ["gnupg"]}`), 0o644)
+	if _, _, err := lab.NewManager(baseDir).DefaultMountPolicy(); err == nil {
+		t.Error("an unknown default mount should be rejected")
+	}
+}
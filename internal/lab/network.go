@@ -0,0 +1,199 @@
+package lab
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	assets "github.com/claudeup/claudeup-lab/embed"
+	"github.com/claudeup/claudeup-lab/internal/docker"
+)
+
+// Network modes. Full access is the default; none cuts the lab off
+// entirely; allowlist attaches the lab to a private network whose only way
+// out is an egress proxy that forwards to allowed hosts.
+const (
+	NetworkFull      = "full"
+	NetworkNone      = "none"
+	NetworkAllowlist = "allowlist"
+)
+
+// proxyPort is where the egress proxy listens.
+const proxyPort = 3128
+
+// DefaultAllowlist is what labs in allowlist mode may reach when the config
+// file does not say otherwise: the model API, package registries and git
+// hosts.
+var DefaultAllowlist = []string{
+	"*.anthropic.com",
+	"claude.ai",
+	"registry.npmjs.org",
+	"pypi.org",
+	"files.pythonhosted.org",
+	"proxy.golang.org",
+	"sum.golang.org",
+	"crates.io",
+	"*.crates.io",
+	"github.com",
+	"*.github.com",
+	"*.githubusercontent.com",
+	"gitlab.com",
+	"bitbucket.org",
+}
+
+// ValidateNetworkMode checks a --network value. Empty means full.
+func ValidateNetworkMode(mode string) error {
+	switch mode {
+	case "", NetworkFull, NetworkNone, NetworkAllowlist:
+		return nil
+	}
+	return fmt.Errorf("unknown network mode %q (supported: %s, %s, %s)", mode, NetworkFull, NetworkNone, NetworkAllowlist)
+}
+
+// validateAllowlist rejects entries the proxy could not match: URLs,
+// ports and wildcards anywhere but the leading label.
+func validateAllowlist(hosts []string) error {
+	for _, h := range hosts {
+		name := strings.TrimPrefix(h, "*.")
+		if name == "" || strings.ContainsAny(name, "*/: ") {
+			return fmt.Errorf("invalid network allowlist entry %q (want a host such as example.com or *.example.com)", h)
+		}
+	}
+	return nil
+}
+
+// DefaultNetwork returns the network mode and allowlist for new labs from
+// the config file.
+func (m *Manager) DefaultNetwork() (string, []string, error) {
+	mode := m.config.Network
+	if err := ValidateNetworkMode(mode); err != nil {
+		return "", nil, fmt.Errorf("config: %w", err)
+	}
+	if mode == "" {
+		mode = NetworkFull
+	}
+	allowlist := m.config.NetworkAllowlist
+	if len(allowlist) == 0 {
+		allowlist = DefaultAllowlist
+	}
+	if err := validateAllowlist(allowlist); err != nil {
+		return "", nil, fmt.Errorf("config: %w", err)
+	}
+	return mode, allowlist, nil
+}
+
+// proxyURL is the address labs in allowlist mode send traffic to.
+func proxyURL(labID string) string {
+	return "http://" + docker.ProxyContainer(labID) + ":" + strconv.Itoa(proxyPort)
+}
+
+// startProxy makes sure an allowlist lab's private network and egress
+// proxy exist and are running, creating them from image if needed.
+func (m *Manager) startProxy(meta *Metadata, image string) error {
+	network := docker.LabNetwork(meta.ID)
+	proxy := docker.ProxyContainer(meta.ID)
+
+	if !m.networks.NetworkExists(network) {
+		if err := m.networks.CreateInternalNetwork(network, meta.ID); err != nil {
+			return err
+		}
+	}
+
+	c, err := m.docker.InspectContainer(proxy)
+	switch {
+	case errors.Is(err, docker.ErrNotFound):
+		fmt.Fprintln(m.out, "Starting egress proxy...")
+		spec := docker.ProxySpec{
+			Name:   proxy,
+			LabID:  meta.ID,
+			Image:  image,
+			Script: assets.EgressProxy,
+			Env: map[string]string{
+				"ALLOWED_HOSTS": strings.Join(meta.Allowlist, ","),
+				"PROXY_PORT":    strconv.Itoa(proxyPort),
+			},
+		}
+		if err := m.networks.RunProxy(spec); err != nil {
+			return err
+		}
+		return m.networks.Connect(network, proxy)
+	case err != nil:
+		return err
+	case !c.Running():
+		return m.networks.StartContainer(proxy)
+	}
+	return nil
+}
+
+// stopProxy stops a lab's egress proxy, if it has one.
+func (m *Manager) stopProxy(labID string) error {
+	c, err := m.docker.InspectContainer(docker.ProxyContainer(labID))
+	if errors.Is(err, docker.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !c.Running() {
+		return nil
+	}
+	return m.docker.StopContainer(c.ID)
+}
+
+// removeProxy removes a lab's egress proxy and private network, if any.
+func (m *Manager) removeProxy(labID string) error {
+	c, err := m.docker.InspectContainer(docker.ProxyContainer(labID))
+	switch {
+	case errors.Is(err, docker.ErrNotFound):
+	case err != nil:
+		return err
+	default:
+		if err := m.docker.RemoveContainer(c.ID); err != nil {
+			return err
+		}
+	}
+	return m.networks.RemoveNetwork(docker.LabNetwork(labID))
+}
+
+// BlockedRequest is a request the egress proxy of an allowlist lab refused.
+type BlockedRequest struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Target string    `json:"target"`
+}
+
+// BlockedRequests returns what an allowlist lab tried to reach and was
+// refused, oldest first, from the proxy's log. Labs in other modes, and
+// labs whose proxy has been removed, have none.
+func (m *Manager) BlockedRequests(meta *Metadata, since time.Time) ([]BlockedRequest, error) {
+	if meta.Network != NetworkAllowlist {
+		return nil, nil
+	}
+	c, err := m.docker.InspectContainer(docker.ProxyContainer(meta.ID))
+	if errors.Is(err, docker.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := m.docker.ContainerLogs(c.ID, docker.LogOptions{Since: since}, &buf); err != nil {
+		return nil, err
+	}
+
+	var blocked []BlockedRequest
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var r BlockedRequest
+		if json.Unmarshal(scanner.Bytes(), &r) == nil && r.Target != "" {
+			blocked = append(blocked, r)
+		}
+	}
+	return blocked, scanner.Err()
+}
@@ -0,0 +1,143 @@
+package lab_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	assets "github.com/claudeup/claudeup-lab/embed"
+	"github.com/claudeup/claudeup-lab/internal/lab"
+)
+
+func TestValidateNetworkMode(t *testing.T) {
+	for _, mode := range []string{"", "full", "none", "allowlist"} {
+		if err := lab.ValidateNetworkMode(mode); err != nil {
+			t.Errorf("ValidateNetworkMode(%q) = %v", mode, err)
+		}
+	}
+	if err := lab.ValidateNetworkMode("bridge"); err == nil {
+		t.Error("unknown mode should be rejected")
+	}
+}
+
+func TestDefaultNetworkFromConfig(t *testing.T) {
+	mgr := lab.NewManager(t.TempDir())
+	mode, allowlist, err := mgr.DefaultNetwork()
+	if err != nil || mode != lab.NetworkFull || len(allowlist) != len(lab.DefaultAllowlist) {
+		t.Errorf("DefaultNetwork = %q, %v, %v; want full with the built-in allowlist", mode, allowlist, err)
+	}
+
+	baseDir := t.TempDir()
+	os.WriteFile(filepath.Join(baseDir, "config.json"),
+		[]byte(`{"network": "allowlist", "network_allowlist": ["api.anthropic.com", "*.internal.example"]}`), 0o644)
+	mode, allowlist, err = lab.NewManager(baseDir).DefaultNetwork()
+	if err != nil || mode != lab.NetworkAllowlist || strings.Join(allowlist, ",") != "api.anthropic.com,*.internal.example" {
+		t.Errorf("DefaultNetwork = %q, %v, %v", mode, allowlist, err)
+	}
+
+	baseDir = t.TempDir()
+	os.WriteFile(filepath.Join(baseDir, "config.json"), []byte(`{"network_allowlist": ["https://github.com"]}`), 0o644)
+	if _, _, err := lab.NewManager(baseDir).DefaultNetwork(); err == nil {
+		t.Error("a URL in the allowlist should be rejected")
+	}
+}
+
+// startEgressProxy runs the embedded proxy script with node, allowing
+// hosts, and returns its URL and a reader for its stdout.
+func startEgressProxy(t *testing.T, hosts string) (*url.URL, *bufio.Scanner) {
+	t.Helper()
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("skipping: node is not available")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	cmd := exec.Command("node", "-e", assets.EgressProxy)
+	cmd.Env = append(os.Environ(), "ALLOWED_HOSTS="+hosts, fmt.Sprintf("PROXY_PORT=%d", port))
+	stdout, _ := cmd.StdoutPipe()
+	stderr, _ := cmd.StderrPipe()
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cmd.Process.Kill(); cmd.Wait() })
+
+	// Wait for the listening message
+	if !bufio.NewScanner(stderr).Scan() {
+		t.Fatal("egress proxy exited before listening")
+	}
+	go io.Copy(io.Discard, stderr)
+
+	proxyURL, _ := url.Parse(fmt.Sprintf("http://127.0.0.1:%d", port))
+	return proxyURL, bufio.NewScanner(stdout)
+}
+
+func TestEgressProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer upstream.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(upstream.URL, "http://"))
+
+	proxyURL, blockedLog := startEgressProxy(t, "localhost,*.allowed.test")
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 10 * time.Second}
+
+	resp, err := client.Get("http://localhost:" + port + "/")
+	if err != nil {
+		t.Fatalf("allowed request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || string(body) != "hello" {
+		t.Errorf("allowed request = %d %q, want the upstream response", resp.StatusCode, body)
+	}
+
+	resp, err = client.Get("http://evil.test/exfiltrate")
+	if err != nil {
+		t.Fatalf("blocked request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("blocked request status = %d, want 403", resp.StatusCode)
+	}
+
+	// CONNECT, as used for HTTPS
+	conn, err := net.Dial("tcp", proxyURL.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "CONNECT allowed.test.evil.test:443 HTTP/1.1\r\nHost: allowed.test.evil.test:443\r\n\r\n")
+	status, _ := bufio.NewReader(conn).ReadString('\n')
+	if !strings.Contains(status, "403") {
+		t.Errorf("CONNECT to a look-alike host = %q, want 403", status)
+	}
+
+	var blocked []lab.BlockedRequest
+	for len(blocked) < 2 && blockedLog.Scan() {
+		var r lab.BlockedRequest
+		if err := json.Unmarshal(blockedLog.Bytes(), &r); err != nil {
+			t.Fatalf("blocked log line %q: %v", blockedLog.Text(), err)
+		}
+		blocked = append(blocked, r)
+	}
+	if len(blocked) != 2 || blocked[0].Target != "evil.test" || blocked[1].Method != "CONNECT" ||
+		blocked[1].Target != "allowed.test.evil.test:443" || blocked[0].Time.IsZero() {
+		t.Errorf("blocked log = %+v", blocked)
+	}
+}
@@ -0,0 +1,86 @@
+package lab
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/claudeup/claudeup-lab/internal/docker"
+)
+
+// registryProbeTimeout bounds the check for a reachable registry.
+const registryProbeTimeout = 3 * time.Second
+
+// offlineSetting returns what CLAUDEUP_LAB_OFFLINE asks for, and whether
+// it is set: 1 always starts labs offline, 0 never does.
+func offlineSetting() (offline, set bool) {
+	switch os.Getenv("CLAUDEUP_LAB_OFFLINE") {
+	case "1", "true":
+		return true, true
+	case "0", "false":
+		return false, true
+	}
+	return false, false
+}
+
+// proxyEnv lists the variables that route the runtime's pulls through a
+// proxy, which a direct connection to the registry bypasses.
+var proxyEnv = []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy"}
+
+// registryUnreachable reports whether a direct connection to the registry
+// image is pulled from fails. Behind a proxy that says nothing about what
+// the runtime can reach, so no connection is attempted.
+func registryUnreachable(image string) bool {
+	for _, key := range proxyEnv {
+		if os.Getenv(key) != "" {
+			return false
+		}
+	}
+	conn, err := net.DialTimeout("tcp", docker.RegistryHost(image), registryProbeTimeout)
+	if err != nil {
+		return true
+	}
+	conn.Close()
+	return false
+}
+
+// prepareImage makes a lab's base image available and reports whether the
+// lab is provisioned offline. A forced offline start uses only the local
+// image. Otherwise an unreachable registry is only a hint, since the runtime
+// may pull through a mirror: the image is pulled anyway, and only when that
+// fails does the lab fall back to a local copy and provision offline.
+func (m *Manager) prepareImage(image string, forced bool) (bool, error) {
+	if forced {
+		return true, m.ensureImage(image, true)
+	}
+	if _, set := offlineSetting(); !set && registryUnreachable(image) {
+		fmt.Fprintf(m.out, "Registry of %s looks unreachable, pulling anyway...\n", image)
+		if err := m.images.Pull(image); err != nil {
+			if localErr := m.images.EnsureLocalImage(image); localErr != nil {
+				return false, fmt.Errorf("%w; %v", err, localErr)
+			}
+			fmt.Fprintln(m.out, "Pull failed, provisioning offline from the local image (no claude upgrade)...")
+			return true, nil
+		}
+	}
+	return false, m.ensureImage(image, false)
+}
+
+// ensureImage makes image available locally: pulled or built as needed,
+// or, offline, only if it is already present.
+func (m *Manager) ensureImage(image string, offline bool) error {
+	if !offline {
+		return m.images.EnsureImage(image)
+	}
+	if err := m.images.EnsureLocalImage(image); err != nil {
+		return fmt.Errorf("%w, and cannot be pulled offline (run claudeup-lab image pull once connected)", err)
+	}
+	return nil
+}
+
+// offlineError explains that a step failed for an offline lab, which may
+// have needed the network.
+func offlineError(err error) error {
+	return fmt.Errorf("%w (the lab is offline; this step may need the network)", err)
+}
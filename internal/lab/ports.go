@@ -0,0 +1,435 @@
+package lab
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/claudeup/claudeup-lab/internal/runner"
+)
+
+// PortMapping makes a port inside a lab reachable on the host's loopback
+// interface. Published ports are part of the container, set when it was
+// created; the rest are relayed by a host-side forwarder process that runs
+// while the lab does.
+type PortMapping struct {
+	Host      int  `json:"host"`
+	Container int  `json:"container"`
+	Published bool `json:"published,omitempty"`
+}
+
+func (p PortMapping) String() string {
+	return fmt.Sprintf("%d:%d", p.Host, p.Container)
+}
+
+// ParsePortMapping parses "host:container", or a single port used on both
+// sides.
+func ParsePortMapping(s string) (PortMapping, error) {
+	host, container, ok := strings.Cut(s, ":")
+	if !ok {
+		container = host
+	}
+	h, err1 := parsePort(host)
+	c, err2 := parsePort(container)
+	if err1 != nil || err2 != nil {
+		return PortMapping{}, fmt.Errorf("invalid port mapping %q (want host:container, e.g. 8080:3000)", s)
+	}
+	return PortMapping{Host: h, Container: c}, nil
+}
+
+func parsePort(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return n, nil
+}
+
+// appPort is the devcontainer.json appPort entry publishing p on the host's
+// loopback interface only.
+func (p PortMapping) appPort() string {
+	return "127.0.0.1:" + p.String()
+}
+
+// checkPorts validates the ports of a new lab. With full network access
+// they are published by the container itself; otherwise the container has
+// no route to the host and they are forwarded.
+func (m *Manager) checkPorts(ports []PortMapping, network string) ([]PortMapping, error) {
+	seen := make(map[int]bool)
+	var checked []PortMapping
+	for _, p := range ports {
+		if seen[p.Host] {
+			return nil, fmt.Errorf("host port %d is mapped more than once", p.Host)
+		}
+		seen[p.Host] = true
+		if err := checkHostPort(p.Host); err != nil {
+			return nil, err
+		}
+		p.Published = network == NetworkFull
+		checked = append(checked, p)
+	}
+	return checked, nil
+}
+
+// PortStatus is a lab's port mapping with whether it currently works.
+type PortStatus struct {
+	PortMapping
+	Mode   string `json:"mode"` // "published" or "forwarded"
+	Active bool   `json:"active"`
+	PID    int    `json:"pid,omitempty"` // forwarder process
+}
+
+// Ports reports a lab's port mappings. Published ports are active while
+// the container runs; forwarded ones while their forwarder does.
+func (m *Manager) Ports(meta *Metadata) []PortStatus {
+	running := false
+	if id, _ := m.docker.FindContainer(meta.Worktree); id != "" {
+		running = true
+	}
+
+	statuses := []PortStatus{}
+	for _, p := range meta.Ports {
+		s := PortStatus{PortMapping: p, Mode: "forwarded"}
+		if p.Published {
+			s.Mode = "published"
+			s.Active = running
+		} else if pid := m.forwarderPID(meta.ID, p.Host); pid != 0 {
+			s.PID = pid
+			s.Active = true
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// AddPort adds a port mapping to an existing lab. Its container already
+// exists, so the port is forwarded rather than published; the forwarder
+// starts now if the lab is running, otherwise when it is resumed. The lab's
+// record is reloaded and saved under the global lock, so concurrent port
+// changes are not lost.
+func (m *Manager) AddPort(meta *Metadata, p PortMapping) error {
+	lock, err := m.Lock()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	current, err := m.store.Load(meta.ID)
+	if err != nil {
+		return err
+	}
+	for _, existing := range current.Ports {
+		if existing.Host == p.Host {
+			return fmt.Errorf("host port %d is already mapped to %d in lab %s", p.Host, existing.Container, current.DisplayName)
+		}
+	}
+	if err := checkHostPort(p.Host); err != nil {
+		return err
+	}
+
+	p.Published = false
+	if id, _ := m.docker.FindContainer(current.Worktree); id != "" {
+		if err := m.startForwarder(current.ID, p); err != nil {
+			return err
+		}
+	}
+
+	current.Ports = append(current.Ports, p)
+	if err := m.store.Save(current); err != nil {
+		return fmt.Errorf("save metadata: %w", err)
+	}
+	*meta = *current
+	return nil
+}
+
+// RemovePort removes the mapping of a host port and stops its forwarder.
+// A published port is part of the container and cannot be removed from it.
+// Like AddPort, it holds the global lock from reloading the lab's record
+// until it is saved.
+func (m *Manager) RemovePort(meta *Metadata, hostPort int) error {
+	lock, err := m.Lock()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	current, err := m.store.Load(meta.ID)
+	if err != nil {
+		return err
+	}
+	for i, p := range current.Ports {
+		if p.Host != hostPort {
+			continue
+		}
+		if p.Published {
+			return fmt.Errorf("port %s was published when the container of lab %s was created and cannot be removed from it", p, current.DisplayName)
+		}
+		if err := m.stopForwarder(current.ID, hostPort); err != nil {
+			return err
+		}
+		current.Ports = append(current.Ports[:i], current.Ports[i+1:]...)
+		if err := m.store.Save(current); err != nil {
+			return fmt.Errorf("save metadata: %w", err)
+		}
+		*meta = *current
+		return nil
+	}
+	return fmt.Errorf("lab %s has no mapping for host port %d", current.DisplayName, hostPort)
+}
+
+// checkHostPort fails if something on the host already listens on port.
+func checkHostPort(port int) error {
+	l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return fmt.Errorf("host port %d is not available: %w", port, err)
+	}
+	return l.Close()
+}
+
+// startForwarders starts the forwarders of a running lab's unpublished
+// ports. A port that cannot be forwarded is reported and skipped.
+func (m *Manager) startForwarders(meta *Metadata) {
+	for _, p := range meta.Ports {
+		if p.Published || m.forwarderPID(meta.ID, p.Host) != 0 {
+			continue
+		}
+		if err := m.startForwarder(meta.ID, p); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: forward port %s: %v\n", p, err)
+		}
+	}
+}
+
+// stopForwarders stops every forwarder of a lab.
+func (m *Manager) stopForwarders(meta *Metadata) error {
+	var errs []string
+	for _, p := range meta.Ports {
+		if err := m.stopForwarder(meta.ID, p.Host); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (m *Manager) forwarderPIDPath(labID string, hostPort int) string {
+	return filepath.Join(m.baseDir, "ports", fmt.Sprintf("%s-%d.pid", labID, hostPort))
+}
+
+// forwarderPID returns the PID of a running forwarder, or 0. A forwarder
+// holds a lock on its PID file for as long as it runs, so a PID left behind
+// by one that died, and since reused by another process, is not trusted.
+func (m *Manager) forwarderPID(labID string, hostPort int) int {
+	f, err := os.Open(m.forwarderPIDPath(labID, hostPort))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err == nil {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		return 0
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || !processAlive(pid) {
+		return 0
+	}
+	return pid
+}
+
+// startForwarder starts a detached forwarder process for a port and
+// records its PID. The PID file is locked before the forwarder starts and
+// the forwarder inherits the lock, which the kernel drops when it exits.
+func (m *Manager) startForwarder(labID string, p PortMapping) error {
+	pidPath := m.forwarderPIDPath(labID, p.Host)
+	if err := os.MkdirAll(filepath.Dir(pidPath), 0o755); err != nil {
+		return fmt.Errorf("create ports directory: %w", err)
+	}
+	pidFile, err := os.OpenFile(pidPath, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("record port forwarder: %w", err)
+	}
+	defer pidFile.Close()
+	if err := syscall.Flock(int(pidFile.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return fmt.Errorf("host port %d is already forwarded", p.Host)
+		}
+		return fmt.Errorf("lock %s: %w", pidPath, err)
+	}
+
+	logFile, err := m.openLog(labID)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	pid, err := m.spawn(labID, p, logFile, pidFile)
+	if err != nil {
+		return fmt.Errorf("start port forwarder: %w", err)
+	}
+	if err := pidFile.Truncate(0); err != nil {
+		return fmt.Errorf("record port forwarder: %w", err)
+	}
+	if _, err := pidFile.WriteAt([]byte(strconv.Itoa(pid)+"\n"), 0); err != nil {
+		return fmt.Errorf("record port forwarder: %w", err)
+	}
+	fmt.Fprintf(m.out, "Forwarding 127.0.0.1:%d to port %d of the lab\n", p.Host, p.Container)
+	return nil
+}
+
+// stopForwarder terminates a port's forwarder, if it is running.
+func (m *Manager) stopForwarder(labID string, hostPort int) error {
+	if pid := m.forwarderPID(labID, hostPort); pid != 0 {
+		if err := syscall.Kill(pid, syscall.SIGTERM); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return fmt.Errorf("stop port forwarder %d: %w", hostPort, err)
+		}
+	}
+	if err := os.Remove(m.forwarderPIDPath(labID, hostPort)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// spawnForwarder runs `claudeup-lab ports serve` for a port in a new
+// session, so it outlives the command that started it. Its output goes to
+// the lab's provisioning log. It keeps pidFile, and the lock on it, open
+// until it exits.
+func spawnForwarder(labID string, p PortMapping, log, pidFile *os.File) (int, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	cmd := exec.Command(self, "ports", "serve", "--lab", labID,
+		"--host-port", strconv.Itoa(p.Host), "--container-port", strconv.Itoa(p.Container))
+	cmd.Stdout = log
+	cmd.Stderr = log
+	cmd.ExtraFiles = []*os.File{pidFile}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	pid := cmd.Process.Pid
+	return pid, cmd.Process.Release()
+}
+
+// relayScript copies stdin to a TCP port on the container's loopback
+// interface and the replies to stdout. It runs with the image's node, so
+// it works without any network access from the container.
+const relayScript = `const s=require('net').connect(+process.argv[1],'127.0.0.1');` +
+	`process.stdin.pipe(s);s.pipe(process.stdout);` +
+	`s.on('error',e=>{console.error(e.message);process.exit(1)});`
+
+// Forwarder relays TCP connections from the host to a port inside a lab
+// container. Each connection runs the relay script with `exec -i`, so it
+// reaches the container whatever its network mode.
+type Forwarder struct {
+	Runtime   string                 // container runtime CLI
+	Run       runner.Runner          // runs the runtime CLI
+	Container func() (string, error) // returns the running container's ID
+	Port      int                    // port inside the container
+	Log       io.Writer              // connection errors; written from several goroutines
+}
+
+// Serve accepts connections on l until it is closed.
+func (f *Forwarder) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go f.relay(conn)
+	}
+}
+
+func (f *Forwarder) relay(conn net.Conn) {
+	defer conn.Close()
+
+	id, err := f.Container()
+	if err == nil && id == "" {
+		err = errors.New("lab is not running")
+	}
+	if err != nil {
+		fmt.Fprintf(f.Log, "port %d: %v\n", f.Port, err)
+		return
+	}
+
+	// Hand the command a pipe rather than the connection: with an *os.File
+	// as stdin, it does not wait for the client to hang up after the relay
+	// exits.
+	stdin, pw, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintf(f.Log, "port %d: %v\n", f.Port, err)
+		return
+	}
+	defer stdin.Close()
+	go func() {
+		io.Copy(pw, conn)
+		pw.Close()
+	}()
+
+	err = f.Run.Run(&runner.Cmd{
+		Name:   f.Runtime,
+		Args:   []string{"exec", "-i", id, "node", "-e", relayScript, strconv.Itoa(f.Port)},
+		Stdin:  stdin,
+		Stdout: conn,
+		Stderr: f.Log,
+	})
+	if err != nil {
+		fmt.Fprintf(f.Log, "port %d: relay: %v\n", f.Port, err)
+	}
+}
+
+// ServePort forwards a host port of a lab until the process is stopped.
+// It is what the detached forwarder process started by AddPort, Start and
+// Resume runs.
+func (m *Manager) ServePort(labID string, p PortMapping) error {
+	meta, err := m.store.Load(labID)
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(p.Host)))
+	if err != nil {
+		return fmt.Errorf("listen on host port %d: %w", p.Host, err)
+	}
+	defer l.Close()
+
+	log := &lockedWriter{w: &timestampWriter{w: os.Stderr, now: time.Now}}
+	fmt.Fprintf(log, "forwarding 127.0.0.1:%d to port %d of lab %s\n", p.Host, p.Container, meta.DisplayName)
+	f := &Forwarder{
+		Runtime:   m.runtime,
+		Run:       m.run,
+		Container: func() (string, error) { return m.docker.FindContainer(meta.Worktree) },
+		Port:      p.Container,
+		Log:       log,
+	}
+	return f.Serve(l)
+}
+
+// lockedWriter serializes writes from concurrent connections.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,162 @@
+package lab_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/claudeup/claudeup-lab/internal/runner"
+)
+
+func TestParsePortMapping(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want lab.PortMapping
+	}{
+		{"8080:3000", lab.PortMapping{Host: 8080, Container: 3000}},
+		{"3000", lab.PortMapping{Host: 3000, Container: 3000}},
+	} {
+		got, err := lab.ParsePortMapping(tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("ParsePortMapping(%q) = %+v, %v; want %+v", tc.in, got, err, tc.want)
+		}
+	}
+	for _, bad := range []string{"", "http", "0:3000", "8080:70000", "127.0.0.1:8080:3000", "8080:"} {
+		if _, err := lab.ParsePortMapping(bad); err == nil {
+			t.Errorf("ParsePortMapping(%q) should fail", bad)
+		}
+	}
+}
+
+// echoServer stands in for a service inside a lab: it answers every line
+// with the line upper-cased.
+func echoServer(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					fmt.Fprintln(conn, strings.ToUpper(scanner.Text()))
+				}
+			}()
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestForwarder(t *testing.T) {
+	service := echoServer(t)
+
+	// The fake runtime relays exec's stdin and stdout to the port named by
+	// the relay script's argument, as node does inside the container.
+	fake := runner.NewFake()
+	fake.On("docker", "exec", "-i", "abc123", "node", "-e").Do(func(cmd *runner.Cmd) error {
+		port := cmd.Args[len(cmd.Args)-1]
+		conn, err := net.Dial("tcp", "127.0.0.1:"+port)
+		if err != nil {
+			return &runner.ExitError{Code: 1}
+		}
+		defer conn.Close()
+		go func() {
+			io.Copy(conn, cmd.Stdin)
+			conn.(*net.TCPConn).CloseWrite()
+		}()
+		io.Copy(cmd.Stdout, conn)
+		return nil
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log strings.Builder
+	f := &lab.Forwarder{
+		Runtime:   "docker",
+		Run:       fake,
+		Container: func() (string, error) { return "abc123", nil },
+		Port:      service,
+		Log:       &log,
+	}
+	done := make(chan error, 1)
+	go func() { done <- f.Serve(l) }()
+
+	// Two connections at once, each with its own relay
+	var conns []net.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	for i, conn := range conns {
+		fmt.Fprintf(conn, "hello %d\n", i)
+		reply, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil || reply != "HELLO "+strconv.Itoa(i)+"\n" {
+			t.Errorf("reply %d = %q, %v", i, reply, err)
+		}
+	}
+	conns[0].Close()
+
+	if !fake.Ran("docker exec -i abc123 node -e") {
+		t.Errorf("relay not run in the container: %v", fake.Commands())
+	}
+
+	l.Close()
+	if err := <-done; err != nil {
+		t.Errorf("Serve = %v after the listener closed", err)
+	}
+}
+
+func TestForwarderLabNotRunning(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	logs := make(chan string, 1)
+	f := &lab.Forwarder{
+		Runtime:   "docker",
+		Run:       runner.NewFake(),
+		Container: func() (string, error) { return "", nil },
+		Port:      3000,
+		Log:       writerFunc(func(p []byte) { logs <- string(p) }),
+	}
+	go f.Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("connection to a stopped lab should be closed, got %v", err)
+	}
+	if msg := <-logs; !strings.Contains(msg, "not running") {
+		t.Errorf("log = %q", msg)
+	}
+}
+
+type writerFunc func(p []byte)
+
+func (w writerFunc) Write(p []byte) (int, error) {
+	w(p)
+	return len(p), nil
+}
@@ -3,9 +3,10 @@ package lab
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/claudeup/claudeup-lab/internal/runner"
 )
 
 const snapshotPrefix = "_lab-snapshot-"
@@ -13,10 +14,13 @@ const snapshotPrefix = "_lab-snapshot-"
 // ProfileManager handles snapshotting and cleaning up claudeup profiles.
 type ProfileManager struct {
 	profilesDir string
+	run         runner.Runner
 }
 
-func NewProfileManager(profilesDir string) *ProfileManager {
-	return &ProfileManager{profilesDir: profilesDir}
+// NewProfileManager returns a ProfileManager that keeps snapshots in
+// profilesDir and runs claudeup with r.
+func NewProfileManager(profilesDir string, r runner.Runner) *ProfileManager {
+	return &ProfileManager{profilesDir: profilesDir, run: r}
 }
 
 // Snapshot saves the current claudeup config as a temporary profile.
@@ -25,8 +29,7 @@ func (pm *ProfileManager) Snapshot(labShortID string) (string, error) {
 	name := snapshotPrefix + labShortID
 	path := filepath.Join(pm.profilesDir, name+".json")
 
-	cmd := exec.Command("claudeup", "profile", "save", name)
-	if out, err := cmd.CombinedOutput(); err != nil {
+	if out, err := runner.CombinedOutput(pm.run, "claudeup", "profile", "save", name); err != nil {
 		// claudeup not available -- write a minimal empty profile
 		if writeErr := os.WriteFile(path, []byte("{}"), 0o644); writeErr != nil {
 			return "", fmt.Errorf("claudeup profile save failed (%v: %s) and fallback write failed: %w",
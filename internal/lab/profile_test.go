@@ -6,13 +6,14 @@ import (
 	"testing"
 
 	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/claudeup/claudeup-lab/internal/runner"
 )
 
 func TestSnapshotProfile(t *testing.T) {
 	profilesDir := filepath.Join(t.TempDir(), "profiles")
 	os.MkdirAll(profilesDir, 0o755)
 
-	pm := lab.NewProfileManager(profilesDir)
+	pm := lab.NewProfileManager(profilesDir, runner.Default)
 	name, err := pm.Snapshot("test-123")
 	if err != nil {
 		t.Fatalf("Snapshot: %v", err)
@@ -33,7 +34,7 @@ func TestCleanupSnapshot(t *testing.T) {
 	profilesDir := filepath.Join(t.TempDir(), "profiles")
 	os.MkdirAll(profilesDir, 0o755)
 
-	pm := lab.NewProfileManager(profilesDir)
+	pm := lab.NewProfileManager(profilesDir, runner.Default)
 	name, _ := pm.Snapshot("test-456")
 
 	err := pm.CleanupSnapshot(name)
@@ -52,7 +53,7 @@ func TestCleanupNonSnapshot(t *testing.T) {
 	os.MkdirAll(profilesDir, 0o755)
 	os.WriteFile(filepath.Join(profilesDir, "real-profile.json"), []byte("{}"), 0o644)
 
-	pm := lab.NewProfileManager(profilesDir)
+	pm := lab.NewProfileManager(profilesDir, runner.Default)
 	err := pm.CleanupSnapshot("real-profile")
 	if err != nil {
 		t.Fatalf("CleanupSnapshot: %v", err)
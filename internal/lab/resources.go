@@ -0,0 +1,220 @@
+package lab
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/claudeup/claudeup-lab/internal/devcontainer"
+	"github.com/claudeup/claudeup-lab/internal/docker"
+)
+
+// Resources are the limits a lab's container runs with. A zero field means
+// unlimited.
+type Resources struct {
+	CPUs      float64 `json:"cpus,omitempty"`
+	Memory    int64   `json:"memory,omitempty"` // bytes
+	PidsLimit int64   `json:"pids_limit,omitempty"`
+}
+
+// minMemory is the smallest memory limit the container runtimes accept.
+const minMemory = 6 << 20
+
+// defaultNodeHeap is Node's old-space limit in MiB for labs without a
+// memory limit.
+const defaultNodeHeap = 4096
+
+// IsZero reports whether no limit is set.
+func (r Resources) IsZero() bool {
+	return r == Resources{}
+}
+
+// String describes the limits for humans, e.g. "2 CPUs, 4g memory".
+func (r Resources) String() string {
+	var parts []string
+	if r.CPUs > 0 {
+		parts = append(parts, strconv.FormatFloat(r.CPUs, 'f', -1, 64)+" CPUs")
+	}
+	if r.Memory > 0 {
+		parts = append(parts, FormatMemory(r.Memory)+" memory")
+	}
+	if r.PidsLimit > 0 {
+		parts = append(parts, strconv.FormatInt(r.PidsLimit, 10)+" PIDs")
+	}
+	if len(parts) == 0 {
+		return "unlimited"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// runArgs returns the container runtime flags that apply the limits.
+func (r Resources) runArgs() []string {
+	var args []string
+	if r.CPUs > 0 {
+		args = append(args, "--cpus="+strconv.FormatFloat(r.CPUs, 'f', -1, 64))
+	}
+	if r.Memory > 0 {
+		args = append(args, "--memory="+strconv.FormatInt(r.Memory, 10))
+	}
+	if r.PidsLimit > 0 {
+		args = append(args, "--pids-limit="+strconv.FormatInt(r.PidsLimit, 10))
+	}
+	return args
+}
+
+// hostRequirements returns the devcontainer.json hostRequirements matching
+// the limits, or nil if there are none worth stating.
+func (r Resources) hostRequirements() map[string]interface{} {
+	req := map[string]interface{}{}
+	if r.CPUs > 0 {
+		req["cpus"] = int(math.Ceil(r.CPUs))
+	}
+	if r.Memory > 0 {
+		req["memory"] = fmt.Sprintf("%dmb", r.Memory>>20)
+	}
+	if len(req) == 0 {
+		return nil
+	}
+	return req
+}
+
+// nodeHeap returns the --max-old-space-size for Node in MiB: three
+// quarters of the memory limit, leaving room for the rest of the
+// container, or the default when memory is unlimited.
+func (r Resources) nodeHeap() int64 {
+	if r.Memory <= 0 {
+		return defaultNodeHeap
+	}
+	heap := r.Memory / 4 * 3 >> 20
+	if heap < 256 {
+		heap = 256
+	}
+	return heap
+}
+
+// limits converts the resources for docker.Runtime.UpdateLimits. An
+// unlimited PID count is lifted explicitly; CPUs and memory cannot be.
+func (r Resources) limits() docker.Limits {
+	l := docker.Limits{CPUs: r.CPUs, Memory: r.Memory, PidsLimit: r.PidsLimit}
+	if l.PidsLimit == 0 {
+		l.PidsLimit = -1
+	}
+	return l
+}
+
+// ParseCPUs parses a CPU limit such as "2" or "1.5". "0" means unlimited.
+func ParseCPUs(s string) (float64, error) {
+	cpus, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || cpus < 0 || math.IsInf(cpus, 0) || math.IsNaN(cpus) {
+		return 0, fmt.Errorf("invalid CPU limit %q (want a number such as 2 or 1.5)", s)
+	}
+	return cpus, nil
+}
+
+// ParseMemory parses a memory limit in bytes or with a binary unit suffix
+// (k, m, g, optionally followed by b), as docker run --memory does. "0"
+// means unlimited.
+func ParseMemory(s string) (int64, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	v = strings.TrimSuffix(v, "b")
+
+	shift := 0
+	switch {
+	case strings.HasSuffix(v, "k"):
+		shift = 10
+	case strings.HasSuffix(v, "m"):
+		shift = 20
+	case strings.HasSuffix(v, "g"):
+		shift = 30
+	}
+	if shift > 0 {
+		v = v[:len(v)-1]
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64>>shift {
+		return 0, fmt.Errorf("invalid memory limit %q (want a size such as 512m or 4g)", s)
+	}
+	n <<= shift
+	if n > 0 && n < minMemory {
+		return 0, fmt.Errorf("memory limit %q is below the 6m minimum", s)
+	}
+	return n, nil
+}
+
+// FormatMemory formats a byte count in the largest unit ParseMemory
+// accepts that represents it exactly.
+func FormatMemory(n int64) string {
+	switch {
+	case n == 0:
+		return "0"
+	case n%(1<<30) == 0:
+		return fmt.Sprintf("%dg", n>>30)
+	case n%(1<<20) == 0:
+		return fmt.Sprintf("%dm", n>>20)
+	case n%(1<<10) == 0:
+		return fmt.Sprintf("%dk", n>>10)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// DefaultResources returns the limits for new labs from the config file.
+func (m *Manager) DefaultResources() (Resources, error) {
+	r := Resources{CPUs: m.config.CPUs, PidsLimit: m.config.PidsLimit}
+	if r.CPUs < 0 || r.PidsLimit < 0 {
+		return Resources{}, fmt.Errorf("config: cpus and pids_limit cannot be negative")
+	}
+	if m.config.Memory != "" {
+		mem, err := ParseMemory(m.config.Memory)
+		if err != nil {
+			return Resources{}, fmt.Errorf("config: %w", err)
+		}
+		r.Memory = mem
+	}
+	return r, nil
+}
+
+// UpdateResources changes the limits of an existing lab. They are recorded
+// in its metadata and devcontainer.json, and applied to its container,
+// running or stopped, if there is one. Runtimes can lower and raise CPU and
+// memory limits in place but not lift them, so removing one of those from
+// a lab with a container is refused.
+func (m *Manager) UpdateResources(meta *Metadata, r Resources) error {
+	if r.CPUs < 0 || r.Memory < 0 || r.PidsLimit < 0 {
+		return fmt.Errorf("resource limits cannot be negative")
+	}
+
+	containerID, err := m.docker.FindContainerIncludingStopped(meta.Worktree)
+	if err != nil {
+		return err
+	}
+	if containerID != "" {
+		if meta.Resources.CPUs > 0 && r.CPUs == 0 {
+			return fmt.Errorf("cannot lift the CPU limit of lab %s in place (create a new lab without --cpus)", meta.DisplayName)
+		}
+		if meta.Resources.Memory > 0 && r.Memory == 0 {
+			return fmt.Errorf("cannot lift the memory limit of lab %s in place (create a new lab without --memory)", meta.DisplayName)
+		}
+		if err := m.docker.UpdateLimits(containerID, r.limits()); err != nil {
+			return fmt.Errorf("update container: %w", err)
+		}
+	}
+
+	meta.Resources = r
+	if err := m.store.Save(meta); err != nil {
+		return fmt.Errorf("save metadata: %w", err)
+	}
+
+	// Re-render with the image the lab already uses, so a container
+	// recreated from devcontainer.json gets the new limits. A lab that fell
+	// back to offline provisioning on its own stays offline.
+	if cfg, err := devcontainer.LoadConfig(meta.Worktree); err == nil {
+		dcConfig := m.devcontainerConfig(meta, cfg.Image)
+		dcConfig.Offline = meta.Offline || cfg.ContainerEnv["CLAUDEUP_LAB_OFFLINE"] == "1"
+		if err := RenderDevcontainer(dcConfig, meta.Worktree); err != nil {
+			return fmt.Errorf("render devcontainer: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package lab_test
+
+import (
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+)
+
+func TestParseMemory(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"512m", 512 << 20, false},
+		{"4g", 4 << 30, false},
+		{"4GB", 4 << 30, false},
+		{"8192k", 8 << 20, false},
+		{"104857600", 100 << 20, false},
+		{"1m", 0, true},
+		{"-1g", 0, true},
+		{"lots", 0, true},
+		{"4t", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := lab.ParseMemory(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMemory(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseCPUs(t *testing.T) {
+	if got, err := lab.ParseCPUs("1.5"); err != nil || got != 1.5 {
+		t.Errorf("ParseCPUs(1.5) = %v, %v", got, err)
+	}
+	for _, bad := range []string{"-1", "two", "Inf"} {
+		if _, err := lab.ParseCPUs(bad); err == nil {
+			t.Errorf("ParseCPUs(%q) should fail", bad)
+		}
+	}
+}
+
+func TestFormatMemory(t *testing.T) {
+	for n, want := range map[int64]string{4 << 30: "4g", 1536 << 20: "1536m", 100 << 10: "100k", 1000: "1000"} {
+		if got := lab.FormatMemory(n); got != want {
+			t.Errorf("FormatMemory(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestResourcesString(t *testing.T) {
+	if got := (lab.Resources{}).String(); got != "unlimited" {
+		t.Errorf("zero Resources = %q, want unlimited", got)
+	}
+	r := lab.Resources{CPUs: 2, Memory: 4 << 30, PidsLimit: 512}
+	if got := r.String(); got != "2 CPUs, 4g memory, 512 PIDs" {
+		t.Errorf("String = %q", got)
+	}
+}
@@ -0,0 +1,133 @@
+package lab
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CurrentSchemaVersion is the metadata schema written by this build. Files
+// without a schema_version predate versioning and are treated as version 1.
+//
+// History:
+//
+//	1: original layout
+//	2: adds lifecycle state and transition history
+const CurrentSchemaVersion = 2
+
+// migration upgrades a raw metadata document from one schema version to the
+// next. Working on the raw document lets a migration rename or reshape
+// fields that no longer exist on Metadata.
+type migration func(doc map[string]interface{}) error
+
+// migrations[i] upgrades version i+1 to version i+2.
+var migrations = []migration{
+	migrateV1ToV2,
+}
+
+// migrateV1ToV2 records version 1 labs as ready. Before states were
+// persisted, metadata was only written once devcontainer up had succeeded.
+// LabStatus reconciles the state against Docker, and records any drift,
+// the first time the lab is listed.
+func migrateV1ToV2(doc map[string]interface{}) error {
+	if _, ok := doc["state"]; ok {
+		return nil
+	}
+	doc["state"] = string(StateReady)
+	if created, ok := doc["created"].(string); ok {
+		doc["history"] = []interface{}{
+			map[string]interface{}{"state": string(StateReady), "at": created},
+		}
+	}
+	return nil
+}
+
+// UnsupportedSchemaError is returned for metadata written by a newer
+// claudeup-lab than this one.
+type UnsupportedSchemaError struct {
+	Version int
+}
+
+func (e *UnsupportedSchemaError) Error() string {
+	return fmt.Sprintf("schema version %d is newer than supported version %d (upgrade claudeup-lab)",
+		e.Version, CurrentSchemaVersion)
+}
+
+// schemaVersion returns the version recorded in a raw metadata document.
+func schemaVersion(doc map[string]interface{}) (int, error) {
+	raw, ok := doc["schema_version"]
+	if !ok {
+		return 1, nil
+	}
+	v, ok := raw.(float64)
+	if !ok || v < 1 || v != float64(int(v)) {
+		return 0, fmt.Errorf("invalid schema_version %v", raw)
+	}
+	return int(v), nil
+}
+
+// decodeMetadata parses a metadata file, running any migrations needed to
+// bring it to CurrentSchemaVersion. It returns the version the file was
+// written with.
+func decodeMetadata(data []byte) (*Metadata, int, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, 0, err
+	}
+	if doc == nil {
+		return nil, 0, fmt.Errorf("metadata is not a JSON object")
+	}
+
+	version, err := schemaVersion(doc)
+	if err != nil {
+		return nil, 0, err
+	}
+	if version > CurrentSchemaVersion {
+		return nil, version, &UnsupportedSchemaError{Version: version}
+	}
+
+	for v := version; v < CurrentSchemaVersion; v++ {
+		if err := migrations[v-1](doc); err != nil {
+			return nil, version, fmt.Errorf("migrate schema %d -> %d: %w", v, v+1, err)
+		}
+	}
+	doc["schema_version"] = CurrentSchemaVersion
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, version, err
+	}
+
+	var meta Metadata
+	if err := json.Unmarshal(migrated, &meta); err != nil {
+		return nil, version, err
+	}
+	if meta.ID == "" {
+		return nil, version, fmt.Errorf("metadata has no id")
+	}
+
+	return &meta, version, nil
+}
+
+// StateFileReport describes the health of one metadata file.
+type StateFileReport struct {
+	File    string
+	ID      string
+	Version int
+	Err     error
+
+	meta *Metadata
+}
+
+// NeedsMigration reports whether the file is readable but written with an
+// older schema.
+func (r *StateFileReport) NeedsMigration() bool {
+	return r.Err == nil && r.Version < CurrentSchemaVersion
+}
+
+// backupSuffix is appended to a metadata file's name when Migrate rewrites it.
+const backupSuffix = ".bak"
+
+func backupName(file string, version int) string {
+	return fmt.Sprintf("%s.v%d.%s%s", file, version, time.Now().UTC().Format("20060102T150405"), backupSuffix)
+}
@@ -0,0 +1,127 @@
+package lab_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/lab"
+)
+
+const v1Metadata = `{
+  "id": "abc-123",
+  "display_name": "myapp-base",
+  "project": "/home/user/code/myapp",
+  "project_name": "myapp",
+  "profile": "base",
+  "bare_repo": "/home/user/.claudeup-lab/repos/myapp.git",
+  "worktree": "/home/user/.claudeup-lab/workspaces/myapp-base",
+  "branch": "lab/base",
+  "created": "2026-02-10T12:00:00Z"
+}`
+
+func TestLoadMigratesV1(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "abc-123.json"), []byte(v1Metadata), 0o644)
+
+	store := lab.NewStateStore(dir)
+	meta, err := store.Load("abc-123")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if meta.SchemaVersion != lab.CurrentSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", meta.SchemaVersion, lab.CurrentSchemaVersion)
+	}
+	if meta.State != lab.StateReady {
+		t.Errorf("State = %q, want %q", meta.State, lab.StateReady)
+	}
+	if len(meta.History) != 1 || !meta.History[0].At.Equal(meta.Created) {
+		t.Errorf("History should record the creation time, got %+v", meta.History)
+	}
+}
+
+func TestLoadRejectsNewerSchema(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "abc-123.json"), []byte(`{"schema_version": 99, "id": "abc-123"}`), 0o644)
+
+	_, err := lab.NewStateStore(dir).Load("abc-123")
+	var unsupported *lab.UnsupportedSchemaError
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("expected UnsupportedSchemaError, got %v", err)
+	}
+}
+
+func TestLoadRejectsIDMismatch(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "abc-123.json"), []byte(`{"schema_version": 2, "id": "other"}`), 0o644)
+
+	if _, err := lab.NewStateStore(dir).Load("abc-123"); err == nil {
+		t.Error("expected error for metadata whose id does not match its file name")
+	}
+}
+
+func TestScanReportsCorruptFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := lab.NewStateStore(dir)
+	store.Save(&lab.Metadata{ID: "good", DisplayName: "good"})
+	os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"id": `), 0o644)
+
+	labs, problems, err := store.Scan()
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(labs) != 1 || labs[0].ID != "good" {
+		t.Errorf("expected only the good lab, got %d labs", len(labs))
+	}
+	if len(problems) != 1 || problems[0].ID != "broken" || problems[0].Err == nil {
+		t.Fatalf("expected broken.json to be reported, got %+v", problems)
+	}
+
+	// List keeps working in the presence of a corrupt file
+	listed, err := store.List()
+	if err != nil || len(listed) != 1 {
+		t.Errorf("List = %d labs, %v; want 1 lab", len(listed), err)
+	}
+}
+
+func TestMigrateRewritesWithBackup(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "abc-123.json"), []byte(v1Metadata), 0o644)
+	store := lab.NewStateStore(dir)
+
+	migrated, problems, err := store.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if len(migrated) != 1 || len(problems) != 0 {
+		t.Fatalf("migrated %d, problems %d; want 1, 0", len(migrated), len(problems))
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "abc-123.json"))
+	var doc map[string]interface{}
+	json.Unmarshal(data, &doc)
+	if doc["schema_version"] != float64(lab.CurrentSchemaVersion) {
+		t.Errorf("file schema_version = %v, want %d", doc["schema_version"], lab.CurrentSchemaVersion)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	backups := 0
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "abc-123.json.v1.") {
+			backups++
+		}
+	}
+	if backups != 1 {
+		t.Errorf("expected one v1 backup, found %d", backups)
+	}
+
+	// A second run has nothing to do
+	migrated, _, _ = store.Migrate()
+	if len(migrated) != 0 {
+		t.Errorf("second Migrate rewrote %d files, want 0", len(migrated))
+	}
+}
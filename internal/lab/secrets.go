@@ -0,0 +1,267 @@
+package lab
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/claudeup/claudeup-lab/internal/devcontainer"
+)
+
+// DefaultSecrets are the host secrets labs receive when the config file
+// does not list any.
+var DefaultSecrets = []string{"GITHUB_TOKEN", "CONTEXT7_API_KEY"}
+
+// secretsFileName is the default secrets file in the base directory.
+const secretsFileName = "secrets.env"
+
+// redacted replaces secret values in inspect and log output.
+const redacted = "[redacted]"
+
+// SecretsPath returns the env file secret values are read from.
+func (m *Manager) SecretsPath() string {
+	if m.config.SecretsFile != "" {
+		return m.config.SecretsFile
+	}
+	return filepath.Join(m.baseDir, secretsFileName)
+}
+
+// SecretNames returns the names of the host secrets labs may receive.
+func (m *Manager) SecretNames() ([]string, error) {
+	names := m.config.Secrets
+	if len(names) == 0 {
+		names = DefaultSecrets
+	}
+	for _, name := range names {
+		if !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("config: invalid secret name %q", name)
+		}
+	}
+	return names, nil
+}
+
+// Secret sources.
+const (
+	SecretFromEnvironment = "environment"
+	SecretFromFile        = "secrets file"
+)
+
+// SecretStatus tells where a secret's value comes from, without the value.
+// Source is empty for a secret that is not set anywhere.
+type SecretStatus struct {
+	Name   string `json:"name"`
+	Source string `json:"source,omitempty"`
+}
+
+// SecretStatuses reports where each allowed secret would be read from.
+func (m *Manager) SecretStatuses() ([]SecretStatus, error) {
+	_, statuses, err := m.resolveSecrets()
+	return statuses, err
+}
+
+// Secrets resolves the values of the secrets labs may receive, for
+// lifecycle commands and exec sessions. The environment takes precedence
+// over the secrets file; secrets set in neither are left out.
+func (m *Manager) Secrets() (devcontainer.Secrets, error) {
+	secrets, _, err := m.resolveSecrets()
+	return secrets, err
+}
+
+func (m *Manager) resolveSecrets() (devcontainer.Secrets, []SecretStatus, error) {
+	names, err := m.SecretNames()
+	if err != nil {
+		return nil, nil, err
+	}
+	stored, err := readSecretsFile(m.SecretsPath())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secrets := devcontainer.Secrets{}
+	statuses := []SecretStatus{}
+	for _, name := range names {
+		status := SecretStatus{Name: name}
+		if v := os.Getenv(name); v != "" {
+			secrets[name] = v
+			status.Source = SecretFromEnvironment
+		} else if v := stored[name]; v != "" {
+			secrets[name] = v
+			status.Source = SecretFromFile
+		}
+		statuses = append(statuses, status)
+	}
+	return secrets, statuses, nil
+}
+
+// readSecretsFile reads the secrets env file. A missing file holds no
+// secrets; one that others can read is refused rather than trusted.
+func readSecretsFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read secrets file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("read secrets file: %w", err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("secrets file %s is accessible by other users (run: chmod 600 %s)", path, path)
+	}
+	return parseEnvFile(f, path)
+}
+
+// Exec runs a command in a lab's running container with the lab's secrets
+// in its environment.
+func (m *Manager) Exec(meta *Metadata, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	secrets, err := m.Secrets()
+	if err != nil {
+		return err
+	}
+	return m.engine.Exec(meta.Worktree, secrets, command, stdin, stdout, stderr)
+}
+
+// Redactor replaces secret values in text shown to the user or kept in
+// logs.
+type Redactor struct {
+	values []string
+}
+
+// NewRedactor redacts the values of secrets. Values shorter than four
+// characters would redact too much unrelated text and are left alone.
+func NewRedactor(secrets devcontainer.Secrets) *Redactor {
+	r := &Redactor{}
+	for _, v := range secrets {
+		if len(v) >= 4 {
+			r.values = append(r.values, v)
+		}
+	}
+	// Longest first, so a secret containing another is redacted whole
+	sort.Slice(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+	return r
+}
+
+// Redactor returns a Redactor for the current secret values. Secrets that
+// cannot be resolved are reported and redact nothing.
+func (m *Manager) Redactor() *Redactor {
+	secrets, err := m.Secrets()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return NewRedactor(secrets)
+}
+
+// String returns s with every secret value replaced.
+func (r *Redactor) String(s string) string {
+	for _, v := range r.values {
+		s = strings.ReplaceAll(s, v, redacted)
+	}
+	return s
+}
+
+// Writer returns a writer that redacts what it passes on to w. It works
+// line by line so a secret split across writes is still caught; Close
+// writes out a final unterminated line and leaves w open.
+func (r *Redactor) Writer(w io.Writer) io.WriteCloser {
+	return &redactWriter{r: r, w: w}
+}
+
+type redactWriter struct {
+	r   *Redactor
+	w   io.Writer
+	buf []byte
+}
+
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	rw.buf = append(rw.buf, p...)
+	if i := bytes.LastIndexByte(rw.buf, '\n'); i >= 0 {
+		if _, err := io.WriteString(rw.w, rw.r.String(string(rw.buf[:i+1]))); err != nil {
+			return 0, err
+		}
+		rw.buf = append(rw.buf[:0], rw.buf[i+1:]...)
+	}
+	return len(p), nil
+}
+
+func (rw *redactWriter) Close() error {
+	if len(rw.buf) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(rw.w, rw.r.String(string(rw.buf)))
+	rw.buf = nil
+	return err
+}
+
+// redactDevcontainer hides the values of secrets in a devcontainer.json,
+// both as currently set and, for files rendered before secrets were kept
+// out of them, any containerEnv or remoteEnv entry named after one.
+func redactDevcontainer(data []byte, names []string, r *Redactor) []byte {
+	var dc map[string]interface{}
+	if json.Unmarshal(data, &dc) != nil {
+		return []byte(r.String(string(data)))
+	}
+	if !removeSecretEnv(dc, names, true) {
+		return []byte(r.String(string(data)))
+	}
+	out, err := json.MarshalIndent(dc, "", "  ")
+	if err != nil {
+		return []byte(r.String(string(data)))
+	}
+	return []byte(r.String(string(out)))
+}
+
+// removeSecretEnv deletes, or with mask replaces, the containerEnv and
+// remoteEnv entries of dc named in names. It reports whether it changed
+// anything.
+func removeSecretEnv(dc map[string]interface{}, names []string, mask bool) bool {
+	changed := false
+	for _, field := range []string{"containerEnv", "remoteEnv"} {
+		env, ok := dc[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, name := range names {
+			if v, ok := env[name]; ok && v != "" && v != redacted {
+				if mask {
+					env[name] = redacted
+				} else {
+					delete(env, name)
+				}
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// scrubDevcontainer removes secrets from a devcontainer.json rendered
+// before they were kept out of it. The container was created with them
+// already, so removing them from the file changes nothing in the lab.
+func scrubDevcontainer(worktree string, names []string) error {
+	path := devcontainer.ConfigPath(worktree)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var dc map[string]interface{}
+	if err := json.Unmarshal(data, &dc); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	if !removeSecretEnv(dc, names, false) {
+		return nil
+	}
+	out, err := json.MarshalIndent(dc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0o644)
+}
@@ -0,0 +1,104 @@
+package lab_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/devcontainer"
+	"github.com/claudeup/claudeup-lab/internal/lab"
+)
+
+func writeSecrets(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(path, mode)
+}
+
+func TestSecretsResolve(t *testing.T) {
+	for _, name := range lab.DefaultSecrets {
+		t.Setenv(name, "")
+	}
+	baseDir := t.TempDir()
+	writeSecrets(t, filepath.Join(baseDir, "secrets.env"), `# lab secrets
+export GITHUB_TOKEN=ghp_fromfile
+CONTEXT7_API_KEY="ctx7 key"
+
+UNLISTED=never-sent
+`, 0o600)
+	mgr := lab.NewManager(baseDir)
+
+	secrets, err := mgr.Secrets()
+	if err != nil {
+		t.Fatalf("Secrets: %v", err)
+	}
+	if secrets["GITHUB_TOKEN"] != "ghp_fromfile" || secrets["CONTEXT7_API_KEY"] != "ctx7 key" || len(secrets) != 2 {
+		t.Errorf("Secrets = %v", secrets)
+	}
+
+	// The environment wins over the file
+	t.Setenv("GITHUB_TOKEN", "ghp_fromenv")
+	secrets, _ = mgr.Secrets()
+	statuses, _ := mgr.SecretStatuses()
+	if secrets["GITHUB_TOKEN"] != "ghp_fromenv" || statuses[0].Source != lab.SecretFromEnvironment || statuses[1].Source != lab.SecretFromFile {
+		t.Errorf("Secrets = %v, statuses = %+v", secrets, statuses)
+	}
+}
+
+func TestSecretsFromConfig(t *testing.T) {
+	baseDir := t.TempDir()
+	secretsFile := filepath.Join(t.TempDir(), "lab.env")
+	writeSecrets(t, secretsFile, "ANTHROPIC_API_KEY=sk-ant-test\nGITHUB_TOKEN=ghp_notlisted\n", 0o600)
+	os.WriteFile(filepath.Join(baseDir, "config.json"),
+		[]byte(`{"secrets": ["ANTHROPIC_API_KEY"], "secrets_file": "`+secretsFile+`"}`), 0o644)
+	t.Setenv("GITHUB_TOKEN", "ghp_notlisted")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	secrets, err := lab.NewManager(baseDir).Secrets()
+	if err != nil || len(secrets) != 1 || secrets["ANTHROPIC_API_KEY"] != "sk-ant-test" {
+		t.Errorf("Secrets = %v, %v; want only the listed secret", secrets, err)
+	}
+
+	os.WriteFile(filepath.Join(baseDir, "config.json"), []byte(`{"secrets": ["NOT-A-NAME"]}`), 0o644)
+	if _, err := lab.NewManager(baseDir).Secrets(); err == nil {
+		t.Error("an invalid secret name should be rejected")
+	}
+}
+
+func TestSecretsFileMustBePrivate(t *testing.T) {
+	baseDir := t.TempDir()
+	writeSecrets(t, filepath.Join(baseDir, "secrets.env"), "GITHUB_TOKEN=ghp_x\n", 0o644)
+
+	_, err := lab.NewManager(baseDir).Secrets()
+	if err == nil || !strings.Contains(err.Error(), "chmod 600") {
+		t.Errorf("Secrets with a world-readable file = %v", err)
+	}
+
+	writeSecrets(t, filepath.Join(baseDir, "secrets.env"), "not an assignment\n", 0o600)
+	if _, err := lab.NewManager(baseDir).Secrets(); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("Secrets with a malformed file = %v", err)
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	r := lab.NewRedactor(devcontainer.Secrets{"A": "ghp_secret", "B": "ghp_secret_longer", "C": "no"})
+
+	got := r.String("token=ghp_secret_longer other=ghp_secret say no")
+	if got != "token=[redacted] other=[redacted] say no" {
+		t.Errorf("String = %q", got)
+	}
+
+	// A secret split across writes is still caught
+	var out strings.Builder
+	w := r.Writer(&out)
+	w.Write([]byte("cloning https://ghp_se"))
+	w.Write([]byte("cret@github.com\npartial ghp_"))
+	w.Write([]byte("secret"))
+	w.Close()
+	if out.String() != "cloning https://[redacted]@github.com\npartial [redacted]" {
+		t.Errorf("Writer output = %q", out.String())
+	}
+}
@@ -11,16 +11,44 @@ import (
 
 // Metadata holds persisted state for a single lab instance.
 type Metadata struct {
-	ID          string    `json:"id"`
-	DisplayName string    `json:"display_name"`
-	Project     string    `json:"project"`
-	ProjectName string    `json:"project_name"`
-	Profile     string    `json:"profile"`
-	BareRepo    string    `json:"bare_repo"`
-	Worktree    string    `json:"worktree"`
-	Branch      string    `json:"branch"`
-	Created     time.Time `json:"created"`
-	Snapshot    string    `json:"snapshot,omitempty"`
+	SchemaVersion int `json:"schema_version"`
+
+	ID          string        `json:"id"`
+	DisplayName string        `json:"display_name"`
+	Project     string        `json:"project"`
+	ProjectName string        `json:"project_name"`
+	Profile     string        `json:"profile"`
+	BareRepo    string        `json:"bare_repo"`
+	Worktree    string        `json:"worktree"`
+	Branch      string        `json:"branch"`
+	Created     time.Time     `json:"created"`
+	Snapshot    string        `json:"snapshot,omitempty"`
+	BaseProfile string        `json:"base_profile,omitempty"`
+	Features    []string      `json:"features,omitempty"`
+	Image       string        `json:"image,omitempty"`
+	ImageDigest string        `json:"image_digest,omitempty"` // registry digest, or local ID for images built here
+	Offline     bool          `json:"offline,omitempty"`      // asked to provision without the network
+	Dockerfile  string        `json:"dockerfile,omitempty"`   // project Dockerfile built on top of Image
+	LayerImage  string        `json:"layer_image,omitempty"`  // the image built from Dockerfile
+	Resources   Resources     `json:"resources,omitzero"`
+	Network     string        `json:"network,omitempty"`
+	Allowlist   []string      `json:"allowlist,omitempty"`
+	Ports       []PortMapping `json:"ports,omitempty"`
+	Env         []string      `json:"env,omitempty"` // names only; values live in devcontainer.json
+
+	Isolation       string      `json:"isolation,omitempty"`
+	NoDefaultMounts []string    `json:"no_default_mounts,omitempty"`
+	CustomMounts    []MountSpec `json:"custom_mounts,omitempty"`
+
+	// ClaudeVersion and ClaudeupVersion are the versions installed in the
+	// lab: pinned at start, or whatever the latest was. A re-created
+	// container installs the same ones.
+	ClaudeVersion   string `json:"claude_version,omitempty"`
+	ClaudeupVersion string `json:"claudeup_version,omitempty"`
+
+	State     LabState          `json:"state,omitempty"`
+	LastError string            `json:"last_error,omitempty"`
+	History   []StateTransition `json:"history,omitempty"`
 }
 
 // StateStore reads and writes lab metadata JSON files in a directory.
@@ -37,38 +65,79 @@ func (s *StateStore) Save(meta *Metadata) error {
 		return fmt.Errorf("create state directory: %w", err)
 	}
 
+	meta.SchemaVersion = CurrentSchemaVersion
 	data, err := json.MarshalIndent(meta, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshal metadata: %w", err)
 	}
 
 	path := filepath.Join(s.dir, meta.ID+".json")
-	if err := os.WriteFile(path, data, 0o644); err != nil {
+	if err := writeFileAtomic(path, data, 0o644); err != nil {
 		return fmt.Errorf("write metadata: %w", err)
 	}
 
 	return nil
 }
 
+// Load reads a lab's metadata, migrating it in memory to the current
+// schema. The file itself is only rewritten by Save or Migrate.
 func (s *StateStore) Load(id string) (*Metadata, error) {
+	meta, _, err := s.load(id)
+	return meta, err
+}
+
+func (s *StateStore) load(id string) (*Metadata, int, error) {
 	if err := validateID(id); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	path := filepath.Join(s.dir, id+".json")
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("read metadata for %s: %w", id, err)
+		return nil, 0, fmt.Errorf("read metadata for %s: %w", id, err)
 	}
 
-	var meta Metadata
-	if err := json.Unmarshal(data, &meta); err != nil {
-		return nil, fmt.Errorf("parse metadata for %s: %w", id, err)
+	meta, version, err := decodeMetadata(data)
+	if err != nil {
+		return nil, version, fmt.Errorf("parse metadata for %s: %w", id, err)
+	}
+	if meta.ID != id {
+		return nil, version, fmt.Errorf("parse metadata for %s: file contains lab %q", id, meta.ID)
 	}
 
-	return &meta, nil
+	return meta, version, nil
 }
 
+// List returns every readable lab. Files that cannot be read are left out;
+// use Scan or Check to find them.
 func (s *StateStore) List() ([]*Metadata, error) {
+	labs, _, err := s.Scan()
+	return labs, err
+}
+
+// Scan returns every readable lab together with a report for each metadata
+// file that could not be read.
+func (s *StateStore) Scan() ([]*Metadata, []*StateFileReport, error) {
+	reports, err := s.Check()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var labs []*Metadata
+	var problems []*StateFileReport
+	for _, r := range reports {
+		if r.Err != nil {
+			problems = append(problems, r)
+			continue
+		}
+		labs = append(labs, r.meta)
+	}
+
+	return labs, problems, nil
+}
+
+// Check reports on every metadata file in the store: its schema version and
+// whether it can be parsed.
+func (s *StateStore) Check() ([]*StateFileReport, error) {
 	entries, err := os.ReadDir(s.dir)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -77,20 +146,63 @@ func (s *StateStore) List() ([]*Metadata, error) {
 		return nil, fmt.Errorf("read state directory: %w", err)
 	}
 
-	var labs []*Metadata
+	var reports []*StateFileReport
 	for _, entry := range entries {
 		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
 			continue
 		}
 		id := strings.TrimSuffix(entry.Name(), ".json")
-		meta, err := s.Load(id)
+		meta, version, err := s.load(id)
+		reports = append(reports, &StateFileReport{
+			File:    filepath.Join(s.dir, entry.Name()),
+			ID:      id,
+			Version: version,
+			Err:     err,
+			meta:    meta,
+		})
+	}
+
+	return reports, nil
+}
+
+// Migrate rewrites every metadata file with an older schema at the current
+// version, keeping a backup of the original next to it. It returns the
+// reports of the files it migrated and of the files it could not read.
+func (s *StateStore) Migrate() (migrated, problems []*StateFileReport, err error) {
+	reports, err := s.Check()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, r := range reports {
+		if r.Err != nil {
+			problems = append(problems, r)
+			continue
+		}
+		if !r.NeedsMigration() {
+			continue
+		}
+
+		original, err := os.ReadFile(r.File)
 		if err != nil {
+			r.Err = err
+			problems = append(problems, r)
+			continue
+		}
+		if err := writeFileAtomic(backupName(r.File, r.Version), original, 0o644); err != nil {
+			r.Err = fmt.Errorf("back up %s: %w", filepath.Base(r.File), err)
+			problems = append(problems, r)
+			continue
+		}
+		if err := s.Save(r.meta); err != nil {
+			r.Err = err
+			problems = append(problems, r)
 			continue
 		}
-		labs = append(labs, meta)
+		migrated = append(migrated, r)
 	}
 
-	return labs, nil
+	return migrated, problems, nil
 }
 
 func validateID(id string) error {
@@ -23,6 +23,7 @@ func TestSaveAndLoad(t *testing.T) {
 		Worktree:    "/home/user/.claudeup-lab/workspaces/myapp-base",
 		Branch:      "lab/base",
 		Created:     time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC),
+		Features:    []string{"go:1.23"},
 	}
 
 	if err := store.Save(meta); err != nil {
@@ -40,6 +41,9 @@ func TestSaveAndLoad(t *testing.T) {
 	if loaded.Branch != "lab/base" {
 		t.Errorf("Branch = %q, want %q", loaded.Branch, "lab/base")
 	}
+	if len(loaded.Features) != 1 || loaded.Features[0] != "go:1.23" {
+		t.Errorf("Features = %v, want [go:1.23]", loaded.Features)
+	}
 }
 
 func TestList(t *testing.T) {
@@ -109,3 +113,25 @@ func TestSaveCreatesDirectory(t *testing.T) {
 		t.Error("Save should create state directory")
 	}
 }
+
+func TestSaveLeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := lab.NewStateStore(dir)
+
+	store.Save(&lab.Metadata{ID: "abc-123", DisplayName: "one"})
+	store.Save(&lab.Metadata{ID: "abc-123", DisplayName: "two"})
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "abc-123.json" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("state dir should only hold abc-123.json, got %v", names)
+	}
+
+	loaded, _ := store.Load("abc-123")
+	if loaded.DisplayName != "two" {
+		t.Errorf("DisplayName = %q, want %q", loaded.DisplayName, "two")
+	}
+}
@@ -0,0 +1,60 @@
+package lab
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// versionPattern matches the versions start --claude-version and
+// --claudeup-version accept: a release such as 2.0.14 or v1.3.0, or a
+// channel such as stable.
+var versionPattern = regexp.MustCompile(`^v?[0-9A-Za-z][0-9A-Za-z.+-]*$`)
+
+// ValidateVersion checks a version to install of tool.
+func ValidateVersion(tool, version string) error {
+	if version != "" && !versionPattern.MatchString(version) {
+		return fmt.Errorf("invalid %s version %q (want a release such as 2.0.14)", tool, version)
+	}
+	return nil
+}
+
+// DefaultVersions returns the Claude Code and claudeup versions new labs
+// install when start does not name them. Empty means the latest.
+func (m *Manager) DefaultVersions() (claude, claudeup string) {
+	return m.config.ClaudeVersion, m.config.ClaudeupVersion
+}
+
+// claudeVersionCheck fails provisioning when Claude Code in the image is
+// not the pinned version, for labs that cannot install it.
+func claudeVersionCheck(version string) string {
+	v := strings.TrimPrefix(version, "v")
+	return fmt.Sprintf(`{ [ "$(claude --version | awk '{print $1}')" = '%s' ] || { echo "[FAIL] Claude Code %s is not in the image and cannot be installed without the network"; exit 1; }; }`, v, v)
+}
+
+// installedVersions asks a lab's container which Claude Code and claudeup
+// versions provisioning left it with. Versions it cannot tell are empty.
+func (m *Manager) installedVersions(meta *Metadata) (claude, claudeup string) {
+	version := func(tool string) string {
+		var out bytes.Buffer
+		if err := m.Exec(meta, []string{tool, "--version"}, nil, &out, io.Discard); err != nil {
+			return ""
+		}
+		return parseVersion(out.String())
+	}
+	return version("claude"), version("claudeup")
+}
+
+// parseVersion finds the version number in --version output such as
+// "2.0.14 (Claude Code)" or "claudeup version v1.3.0".
+func parseVersion(out string) string {
+	for _, field := range strings.Fields(out) {
+		v := strings.TrimPrefix(field, "v")
+		if v != "" && v[0] >= '0' && v[0] <= '9' {
+			return v
+		}
+	}
+	return ""
+}
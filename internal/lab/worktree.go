@@ -2,120 +2,157 @@ package lab
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
+
+	"github.com/claudeup/claudeup-lab/internal/runner"
 )
 
 // WorktreeManager handles bare clone creation/refresh and git worktree operations.
 type WorktreeManager struct {
 	reposDir string
+	run      runner.Runner
 }
 
-func NewWorktreeManager(reposDir string) *WorktreeManager {
-	return &WorktreeManager{reposDir: reposDir}
+// NewWorktreeManager returns a WorktreeManager that keeps bare clones in
+// reposDir and runs git with r.
+func NewWorktreeManager(reposDir string, r runner.Runner) *WorktreeManager {
+	return &WorktreeManager{reposDir: reposDir, run: r}
 }
 
-// EnsureBareRepo creates or refreshes a bare clone of the source project.
-// Returns the path to the bare repo.
-func (w *WorktreeManager) EnsureBareRepo(sourceProject, projectName string) (string, error) {
-	barePath := filepath.Join(w.reposDir, projectName+".git")
-	markerFile := "lab-source-project"
+const markerFile = "lab-source-project"
+
+// errOtherProject is returned by ensureBareRepoAt when the repo at the path
+// was cloned from another project with the same name.
+var errOtherProject = errors.New("bare repo belongs to another project")
 
+// EnsureBareRepo creates or refreshes a bare clone of the source project.
+// Returns the path to the bare repo and whether this call created it, as
+// decided under the repo lock. A clone of another project with the same
+// name keeps the plain path, and this one gets a hash suffix.
+func (w *WorktreeManager) EnsureBareRepo(sourceProject, projectName string) (string, bool, error) {
 	if err := os.MkdirAll(w.reposDir, 0o755); err != nil {
-		return "", fmt.Errorf("create repos directory: %w", err)
+		return "", false, fmt.Errorf("create repos directory: %w", err)
 	}
 
-	// Check for source mismatch if bare repo exists
+	barePath, created, err := w.ensureBareRepoAt(filepath.Join(w.reposDir, projectName+".git"), sourceProject)
+	if !errors.Is(err, errOtherProject) {
+		return barePath, created, err
+	}
+	hashed := filepath.Join(w.reposDir, fmt.Sprintf("%s-%s.git", projectName, hashPrefix(sourceProject)))
+	return w.ensureBareRepoAt(hashed, sourceProject)
+}
+
+// ensureBareRepoAt refreshes the clone of the source project at barePath,
+// or clones it there, holding the repo lock from the ownership check on.
+func (w *WorktreeManager) ensureBareRepoAt(barePath, sourceProject string) (string, bool, error) {
+	lock, err := w.lockBareRepo(barePath)
+	if err != nil {
+		return "", false, err
+	}
+	defer lock.Release()
+
 	if info, err := os.Stat(barePath); err == nil && info.IsDir() {
 		stored, _ := os.ReadFile(filepath.Join(barePath, markerFile))
 		if string(stored) != sourceProject {
-			// Different project with same name -- use hash suffix
-			hash := hashPrefix(sourceProject)
-			barePath = filepath.Join(w.reposDir, fmt.Sprintf("%s-%s.git", projectName, hash))
+			return "", false, fmt.Errorf("%s: %w", barePath, errOtherProject)
 		}
-	}
-
-	if info, err := os.Stat(barePath); err == nil && info.IsDir() {
 		// Refresh existing bare clone
 		w.refreshBareRepo(barePath, sourceProject)
-		return barePath, nil
+		return barePath, false, nil
 	}
 
 	// Create new bare clone
-	if err := w.createBareRepo(barePath, sourceProject, markerFile); err != nil {
-		return "", err
+	if err := w.createBareRepo(barePath, sourceProject); err != nil {
+		return "", false, err
 	}
 
-	return barePath, nil
+	return barePath, true, nil
 }
 
 func (w *WorktreeManager) refreshBareRepo(barePath, sourceProject string) {
-	exec.Command("git", "-C", barePath, "fetch", "--all", "--prune").Run()
-	exec.Command("git", "-C", barePath, "fetch", sourceProject,
-		"+refs/heads/*:refs/heads/*").Run()
+	runner.Run(w.run, "git", "-C", barePath, "fetch", "--all", "--prune")
+	runner.Run(w.run, "git", "-C", barePath, "fetch", sourceProject,
+		"+refs/heads/*:refs/heads/*")
 }
 
-func (w *WorktreeManager) createBareRepo(barePath, sourceProject, markerFile string) error {
+// createBareRepo clones the source project next to barePath, marks the
+// clone with the project it came from and only then renames it into place,
+// so a repo at barePath always carries its marker.
+func (w *WorktreeManager) createBareRepo(barePath, sourceProject string) error {
+	tmpPath := barePath + ".tmp-" + randomSuffix()
+	defer os.RemoveAll(tmpPath)
+
 	// Try upstream first
-	upstreamCmd := exec.Command("git", "-C", sourceProject, "remote", "get-url", "origin")
-	upstreamOut, err := upstreamCmd.Output()
+	upstreamOut, err := runner.Output(w.run, "git", "-C", sourceProject, "remote", "get-url", "origin")
 	upstream := strings.TrimSpace(string(upstreamOut))
 
 	if err == nil && upstream != "" {
-		cmd := exec.Command("git", "clone", "--bare", upstream, barePath)
-		if cmd.Run() == nil {
+		if runner.Run(w.run, "git", "clone", "--bare", upstream, tmpPath) == nil {
 			// Fetch local branches not yet pushed
-			exec.Command("git", "-C", barePath, "fetch", sourceProject,
-				"+refs/heads/*:refs/heads/*").Run()
+			runner.Run(w.run, "git", "-C", tmpPath, "fetch", sourceProject,
+				"+refs/heads/*:refs/heads/*")
 		} else {
 			// Upstream clone failed, fall back to local
-			os.RemoveAll(barePath)
-			cmd = exec.Command("git", "clone", "--bare", sourceProject, barePath)
-			if err := cmd.Run(); err != nil {
+			os.RemoveAll(tmpPath)
+			if err := runner.Run(w.run, "git", "clone", "--bare", sourceProject, tmpPath); err != nil {
 				return fmt.Errorf("clone bare repo from %s: %w", sourceProject, err)
 			}
 		}
 	} else {
-		cmd := exec.Command("git", "clone", "--bare", sourceProject, barePath)
-		if err := cmd.Run(); err != nil {
+		if err := runner.Run(w.run, "git", "clone", "--bare", sourceProject, tmpPath); err != nil {
 			return fmt.Errorf("clone bare repo from %s: %w", sourceProject, err)
 		}
 	}
 
-	os.WriteFile(filepath.Join(barePath, markerFile), []byte(sourceProject), 0o644)
+	if err := os.WriteFile(filepath.Join(tmpPath, markerFile), []byte(sourceProject), 0o644); err != nil {
+		return fmt.Errorf("mark bare repo: %w", err)
+	}
+	if err := os.Rename(tmpPath, barePath); err != nil {
+		return fmt.Errorf("move bare repo into place: %w", err)
+	}
 	return nil
 }
 
 // CreateWorktree creates a git worktree from the bare repo. If the branch
 // is already checked out in another worktree, a random suffix is appended.
-func (w *WorktreeManager) CreateWorktree(barePath, worktreePath, branch string) (string, error) {
+// Returns the branch checked out and whether this call created it.
+func (w *WorktreeManager) CreateWorktree(barePath, worktreePath, branch string) (string, bool, error) {
 	if err := os.MkdirAll(filepath.Dir(worktreePath), 0o755); err != nil {
-		return "", fmt.Errorf("create workspace parent: %w", err)
+		return "", false, fmt.Errorf("create workspace parent: %w", err)
 	}
 
+	// Hold the repo lock from the in-use check until the worktree exists, so
+	// a parallel start cannot check out the same branch in between.
+	lock, err := w.lockBareRepo(barePath)
+	if err != nil {
+		return "", false, err
+	}
+	defer lock.Release()
+
 	// Check if branch is already checked out in another worktree
 	if w.branchInUse(barePath, branch) {
 		branch = branch + "-" + randomSuffix()
 	}
 
 	// Check if branch already exists in the bare repo
-	checkCmd := exec.Command("git", "-C", barePath, "show-ref", "--verify",
-		"--quiet", "refs/heads/"+branch)
-	if checkCmd.Run() == nil {
-		cmd := exec.Command("git", "-C", barePath, "worktree", "add",
+	created := !w.BranchExists(barePath, branch)
+	if !created {
+		out, err := runner.CombinedOutput(w.run, "git", "-C", barePath, "worktree", "add",
 			worktreePath, branch)
-		if out, err := cmd.CombinedOutput(); err != nil {
-			return "", fmt.Errorf("create worktree (existing branch): %w\n%s", err, out)
+		if err != nil {
+			return "", false, fmt.Errorf("create worktree (existing branch): %w\n%s", err, out)
 		}
 	} else {
-		cmd := exec.Command("git", "-C", barePath, "worktree", "add",
+		out, err := runner.CombinedOutput(w.run, "git", "-C", barePath, "worktree", "add",
 			worktreePath, "-b", branch)
-		if out, err := cmd.CombinedOutput(); err != nil {
-			return "", fmt.Errorf("create worktree (new branch): %w\n%s", err, out)
+		if err != nil {
+			return "", false, fmt.Errorf("create worktree (new branch): %w\n%s", err, out)
 		}
 	}
 
@@ -123,31 +160,37 @@ func (w *WorktreeManager) CreateWorktree(barePath, worktreePath, branch string)
 	gitDir := w.worktreeGitDir(worktreePath)
 	excludeFile := filepath.Join(gitDir, "info", "exclude")
 	if err := os.MkdirAll(filepath.Dir(excludeFile), 0o755); err != nil {
-		return "", fmt.Errorf("create git info directory: %w", err)
+		return "", false, fmt.Errorf("create git info directory: %w", err)
 	}
 	content, _ := os.ReadFile(excludeFile)
 	if !strings.Contains(string(content), ".devcontainer/") {
 		f, err := os.OpenFile(excludeFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
 		if err != nil {
-			return "", fmt.Errorf("open git exclude file: %w", err)
+			return "", false, fmt.Errorf("open git exclude file: %w", err)
 		}
 		_, writeErr := f.WriteString(".devcontainer/\n")
 		closeErr := f.Close()
 		if writeErr != nil {
-			return "", fmt.Errorf("write git exclude: %w", writeErr)
+			return "", false, fmt.Errorf("write git exclude: %w", writeErr)
 		}
 		if closeErr != nil {
-			return "", fmt.Errorf("close git exclude: %w", closeErr)
+			return "", false, fmt.Errorf("close git exclude: %w", closeErr)
 		}
 	}
 
-	return branch, nil
+	return branch, created, nil
 }
 
 func (w *WorktreeManager) RemoveWorktree(barePath, worktreePath string) error {
-	cmd := exec.Command("git", "-C", barePath, "worktree", "remove",
+	lock, err := w.lockBareRepo(barePath)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	err = runner.Run(w.run, "git", "-C", barePath, "worktree", "remove",
 		worktreePath, "--force")
-	if err := cmd.Run(); err != nil {
+	if err != nil {
 		// Fall back to removing the directory directly
 		if removeErr := os.RemoveAll(worktreePath); removeErr != nil {
 			return fmt.Errorf("remove worktree directory %s: %w (git worktree remove also failed: %v)", worktreePath, removeErr, err)
@@ -156,10 +199,80 @@ func (w *WorktreeManager) RemoveWorktree(barePath, worktreePath string) error {
 	return nil
 }
 
+// BranchExists reports whether the bare repo has a local branch by that name.
+func (w *WorktreeManager) BranchExists(barePath, branch string) bool {
+	return runner.Run(w.run, "git", "-C", barePath, "show-ref", "--verify",
+		"--quiet", "refs/heads/"+branch) == nil
+}
+
+// DeleteBranch force-deletes a branch from the bare repo, pruning stale
+// worktree entries first so a removed worktree no longer pins it.
+func (w *WorktreeManager) DeleteBranch(barePath, branch string) error {
+	lock, err := w.lockBareRepo(barePath)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	runner.Run(w.run, "git", "-C", barePath, "worktree", "prune")
+	if out, err := runner.CombinedOutput(w.run, "git", "-C", barePath, "branch", "-D", branch); err != nil {
+		return fmt.Errorf("delete branch %s: %w\n%s", branch, err, out)
+	}
+	return nil
+}
+
+// RemoveBareRepo deletes a bare repo cloned by a lab creation that is being
+// rolled back. A parallel start may have added its own worktree since, so
+// the repo is kept while any worktree other than except still uses it.
+func (w *WorktreeManager) RemoveBareRepo(barePath, except string) error {
+	lock, err := w.lockBareRepo(barePath)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	if _, err := os.Stat(barePath); os.IsNotExist(err) {
+		return nil
+	}
+	runner.Run(w.run, "git", "-C", barePath, "worktree", "prune")
+	out, err := runner.Output(w.run, "git", "-C", barePath, "worktree", "list", "--porcelain")
+	if err != nil {
+		return fmt.Errorf("list worktrees: %w", err)
+	}
+	for _, entry := range strings.Split(strings.TrimSpace(string(out)), "\n\n") {
+		lines := strings.Split(entry, "\n")
+		path, ok := strings.CutPrefix(lines[0], "worktree ")
+		if !ok || slices.Contains(lines[1:], "bare") || samePath(path, except) {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Keeping bare repo %s: worktree %s still uses it\n", barePath, path)
+		return nil
+	}
+
+	if err := os.RemoveAll(barePath); err != nil {
+		return fmt.Errorf("remove bare repo: %w", err)
+	}
+	return nil
+}
+
+// samePath reports whether two paths name the same location, following
+// symlinks where they exist.
+func samePath(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(a); err == nil {
+		a = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(b); err == nil {
+		b = resolved
+	}
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
 // WorktreeCount returns the number of worktrees associated with the bare repo.
 func (w *WorktreeManager) WorktreeCount(barePath string) (int, error) {
-	cmd := exec.Command("git", "-C", barePath, "worktree", "list", "--porcelain")
-	out, err := cmd.Output()
+	out, err := runner.Output(w.run, "git", "-C", barePath, "worktree", "list", "--porcelain")
 	if err != nil {
 		return 0, fmt.Errorf("list worktrees: %w", err)
 	}
@@ -173,15 +286,53 @@ func (w *WorktreeManager) WorktreeCount(barePath string) (int, error) {
 	return count, nil
 }
 
+// Divergence compares a lab branch with the source project's checked-out
+// branch.
+type Divergence struct {
+	SourceBranch string `json:"source_branch"`
+	Ahead        int    `json:"ahead"`  // commits on the lab branch only
+	Behind       int    `json:"behind"` // commits on the source branch only
+}
+
+// Divergence counts how far a worktree's HEAD and the source project's HEAD
+// have moved apart. The source HEAD is fetched into the worktree first
+// (only FETCH_HEAD is written), so commits made in the project after the
+// lab was created are counted.
+func (w *WorktreeManager) Divergence(worktreePath, sourceProject string) (*Divergence, error) {
+	branch, err := runner.Output(w.run, "git", "-C", sourceProject, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("read source branch: %w", err)
+	}
+
+	if err := runner.Run(w.run, "git", "-C", worktreePath, "fetch", "--quiet", "--no-tags", sourceProject, "HEAD"); err != nil {
+		return nil, fmt.Errorf("fetch source project: %w", err)
+	}
+	out, err := runner.Output(w.run, "git", "-C", worktreePath, "rev-list", "--left-right", "--count", "HEAD...FETCH_HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("compare with source project: %w", err)
+	}
+
+	d := &Divergence{SourceBranch: strings.TrimSpace(string(branch))}
+	if _, err := fmt.Sscanf(string(out), "%d %d", &d.Ahead, &d.Behind); err != nil {
+		return nil, fmt.Errorf("parse rev-list output %q: %w", out, err)
+	}
+	return d, nil
+}
+
+// lockBareRepo takes the per-repo lock that serializes clone, refresh and
+// worktree changes. The lock file sits next to the repo so it can be taken
+// before the repo exists.
+func (w *WorktreeManager) lockBareRepo(barePath string) (*FileLock, error) {
+	return AcquireLock(barePath + ".lock")
+}
+
 func (w *WorktreeManager) branchInUse(barePath, branch string) bool {
-	cmd := exec.Command("git", "-C", barePath, "worktree", "list", "--porcelain")
-	out, _ := cmd.Output()
+	out, _ := runner.Output(w.run, "git", "-C", barePath, "worktree", "list", "--porcelain")
 	return strings.Contains(string(out), "branch refs/heads/"+branch)
 }
 
 func (w *WorktreeManager) worktreeGitDir(worktreePath string) string {
-	cmd := exec.Command("git", "-C", worktreePath, "rev-parse", "--git-dir")
-	out, err := cmd.Output()
+	out, err := runner.Output(w.run, "git", "-C", worktreePath, "rev-parse", "--git-dir")
 	if err != nil {
 		return filepath.Join(worktreePath, ".git")
 	}
@@ -4,9 +4,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/claudeup/claudeup-lab/internal/lab"
+	"github.com/claudeup/claudeup-lab/internal/runner"
 )
 
 func initTestRepo(t *testing.T) string {
@@ -34,42 +36,82 @@ func TestEnsureBareRepo(t *testing.T) {
 	source := initTestRepo(t)
 	reposDir := filepath.Join(t.TempDir(), "repos")
 
-	wt := lab.NewWorktreeManager(reposDir)
-	barePath, err := wt.EnsureBareRepo(source, "testproject")
+	wt := lab.NewWorktreeManager(reposDir, runner.Default)
+	barePath, created, err := wt.EnsureBareRepo(source, "testproject")
 	if err != nil {
 		t.Fatalf("EnsureBareRepo: %v", err)
 	}
+	if !created {
+		t.Error("first call should report the clone as created")
+	}
 
 	if _, err := os.Stat(barePath); os.IsNotExist(err) {
 		t.Error("bare repo should exist")
 	}
 
 	// Second call should refresh, not recreate
-	barePath2, err := wt.EnsureBareRepo(source, "testproject")
+	barePath2, created, err := wt.EnsureBareRepo(source, "testproject")
 	if err != nil {
 		t.Fatalf("EnsureBareRepo (refresh): %v", err)
 	}
+	if created {
+		t.Error("refresh should not report the clone as created")
+	}
 	if barePath != barePath2 {
 		t.Errorf("paths differ: %q vs %q", barePath, barePath2)
 	}
 }
 
+func TestEnsureBareRepoSameNameInParallel(t *testing.T) {
+	sources := []string{initTestRepo(t), initTestRepo(t)}
+	reposDir := filepath.Join(t.TempDir(), "repos")
+	wt := lab.NewWorktreeManager(reposDir, runner.Default)
+
+	paths := make([]string, len(sources))
+	errs := make([]error, len(sources))
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			paths[i], _, errs[i] = wt.EnsureBareRepo(source, "testproject")
+		}()
+	}
+	wg.Wait()
+
+	for i, source := range sources {
+		if errs[i] != nil {
+			t.Fatalf("EnsureBareRepo(%s): %v", source, errs[i])
+		}
+		marker, err := os.ReadFile(filepath.Join(paths[i], "lab-source-project"))
+		if err != nil {
+			t.Fatalf("read marker: %v", err)
+		}
+		if string(marker) != source {
+			t.Errorf("%s is marked %q, want %q", paths[i], marker, source)
+		}
+	}
+	if paths[0] == paths[1] {
+		t.Errorf("both projects share %s", paths[0])
+	}
+}
+
 func TestCreateWorktree(t *testing.T) {
 	source := initTestRepo(t)
 	reposDir := filepath.Join(t.TempDir(), "repos")
 	wsDir := filepath.Join(t.TempDir(), "workspaces")
 
-	wt := lab.NewWorktreeManager(reposDir)
-	barePath, _ := wt.EnsureBareRepo(source, "testproject")
+	wt := lab.NewWorktreeManager(reposDir, runner.Default)
+	barePath, _, _ := wt.EnsureBareRepo(source, "testproject")
 
 	wtPath := filepath.Join(wsDir, "test-lab")
-	branch, err := wt.CreateWorktree(barePath, wtPath, "lab/test")
+	branch, created, err := wt.CreateWorktree(barePath, wtPath, "lab/test")
 	if err != nil {
 		t.Fatalf("CreateWorktree: %v", err)
 	}
 
-	if branch != "lab/test" {
-		t.Errorf("branch = %q, want %q", branch, "lab/test")
+	if branch != "lab/test" || !created {
+		t.Errorf("branch = %q (created %v), want new branch %q", branch, created, "lab/test")
 	}
 
 	readme := filepath.Join(wtPath, "README.md")
@@ -83,14 +125,14 @@ func TestCreateWorktreeBranchCollision(t *testing.T) {
 	reposDir := filepath.Join(t.TempDir(), "repos")
 	wsDir := filepath.Join(t.TempDir(), "workspaces")
 
-	wt := lab.NewWorktreeManager(reposDir)
-	barePath, _ := wt.EnsureBareRepo(source, "testproject")
+	wt := lab.NewWorktreeManager(reposDir, runner.Default)
+	barePath, _, _ := wt.EnsureBareRepo(source, "testproject")
 
 	// Create first worktree on lab/test
 	wt.CreateWorktree(barePath, filepath.Join(wsDir, "lab1"), "lab/test")
 
 	// Second worktree on same branch should get a suffix
-	branch, err := wt.CreateWorktree(barePath, filepath.Join(wsDir, "lab2"), "lab/test")
+	branch, _, err := wt.CreateWorktree(barePath, filepath.Join(wsDir, "lab2"), "lab/test")
 	if err != nil {
 		t.Fatalf("CreateWorktree (collision): %v", err)
 	}
@@ -107,8 +149,8 @@ func TestRemoveWorktree(t *testing.T) {
 	reposDir := filepath.Join(t.TempDir(), "repos")
 	wsDir := filepath.Join(t.TempDir(), "workspaces")
 
-	wt := lab.NewWorktreeManager(reposDir)
-	barePath, _ := wt.EnsureBareRepo(source, "testproject")
+	wt := lab.NewWorktreeManager(reposDir, runner.Default)
+	barePath, _, _ := wt.EnsureBareRepo(source, "testproject")
 
 	wtPath := filepath.Join(wsDir, "test-lab")
 	wt.CreateWorktree(barePath, wtPath, "lab/test")
@@ -128,8 +170,8 @@ func TestWorktreeCount(t *testing.T) {
 	reposDir := filepath.Join(t.TempDir(), "repos")
 	wsDir := filepath.Join(t.TempDir(), "workspaces")
 
-	wt := lab.NewWorktreeManager(reposDir)
-	barePath, _ := wt.EnsureBareRepo(source, "testproject")
+	wt := lab.NewWorktreeManager(reposDir, runner.Default)
+	barePath, _, _ := wt.EnsureBareRepo(source, "testproject")
 
 	count, err := wt.WorktreeCount(barePath)
 	if err != nil {
@@ -146,3 +188,39 @@ func TestWorktreeCount(t *testing.T) {
 		t.Errorf("count = %d, want 2", count)
 	}
 }
+
+func TestRemoveBareRepoKeepsUsedRepo(t *testing.T) {
+	source := initTestRepo(t)
+	reposDir := filepath.Join(t.TempDir(), "repos")
+	wsDir := filepath.Join(t.TempDir(), "workspaces")
+
+	wt := lab.NewWorktreeManager(reposDir, runner.Default)
+	barePath, _, _ := wt.EnsureBareRepo(source, "testproject")
+	mine := filepath.Join(wsDir, "lab1")
+	if _, _, err := wt.CreateWorktree(barePath, mine, "lab/test"); err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+
+	// A worktree added by a parallel start keeps the repo alive
+	other := filepath.Join(wsDir, "lab2")
+	if _, _, err := wt.CreateWorktree(barePath, other, "lab/other"); err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+	if err := wt.RemoveBareRepo(barePath, mine); err != nil {
+		t.Fatalf("RemoveBareRepo: %v", err)
+	}
+	if _, err := os.Stat(barePath); err != nil {
+		t.Fatal("bare repo removed while another worktree uses it")
+	}
+
+	// Only the worktree being rolled back is left: the repo goes
+	if err := wt.RemoveWorktree(barePath, other); err != nil {
+		t.Fatalf("RemoveWorktree: %v", err)
+	}
+	if err := wt.RemoveBareRepo(barePath, mine); err != nil {
+		t.Fatalf("RemoveBareRepo: %v", err)
+	}
+	if _, err := os.Stat(barePath); !os.IsNotExist(err) {
+		t.Error("unused bare repo not removed")
+	}
+}
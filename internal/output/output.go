@@ -0,0 +1,98 @@
+// Package output renders command results in the format picked with the
+// global --output flag. Tables are written by each command; this package
+// handles the machine-readable formats, which all share the JSON schema of
+// the value being written.
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// Format kinds.
+const (
+	Table    = "table"
+	JSON     = "json"
+	YAML     = "yaml"
+	Template = "go-template"
+)
+
+// Format is a parsed --output value.
+type Format struct {
+	Kind string
+	tmpl *template.Template
+}
+
+// Parse parses an --output value: table, json, yaml, or
+// go-template=<template>.
+func Parse(spec string) (*Format, error) {
+	switch spec {
+	case "", Table:
+		return &Format{Kind: Table}, nil
+	case JSON:
+		return &Format{Kind: JSON}, nil
+	case YAML:
+		return &Format{Kind: YAML}, nil
+	case Template:
+		return nil, fmt.Errorf("--output go-template needs a template: --output 'go-template={{...}}'")
+	}
+
+	if text, ok := strings.CutPrefix(spec, Template+"="); ok {
+		tmpl, err := template.New("output").Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parse --output template: %w", err)
+		}
+		return &Format{Kind: Template, tmpl: tmpl}, nil
+	}
+
+	return nil, fmt.Errorf("unknown output format %q (supported: table, json, yaml, go-template=...)", spec)
+}
+
+// Structured reports whether the format is machine-readable. Commands
+// writing a structured format keep stdout free of anything else.
+func (f *Format) Structured() bool {
+	return f.Kind != Table
+}
+
+// Write renders v in a structured format. Templates see the same field
+// names as JSON, e.g. {{.worktree}}.
+func (f *Format) Write(w io.Writer, v interface{}) error {
+	switch f.Kind {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case YAML:
+		return EncodeYAML(w, v)
+	case Template:
+		data, err := generic(v)
+		if err != nil {
+			return err
+		}
+		if err := f.tmpl.Execute(w, data); err != nil {
+			return fmt.Errorf("execute --output template: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("output format %q is not structured", f.Kind)
+}
+
+// generic converts v to the maps, slices and scalars its JSON encoding
+// decodes to.
+func generic(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encode output: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out interface{}
+	if err := dec.Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode output: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,106 @@
+package output_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/output"
+)
+
+type labView struct {
+	ID       string   `json:"id"`
+	Worktree string   `json:"worktree"`
+	Volumes  []string `json:"volumes"`
+	Running  bool     `json:"running"`
+	CPUs     float64  `json:"cpus,omitempty"`
+}
+
+var sample = []labView{
+	{ID: "abc", Worktree: "/labs/myapp", Volumes: []string{"claudeup-lab-config-abc"}, Running: true, CPUs: 1.5},
+	{ID: "def", Worktree: "/labs/other: x", Volumes: []string{}},
+}
+
+func render(t *testing.T, spec string, v interface{}) string {
+	t.Helper()
+	f, err := output.Parse(spec)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", spec, err)
+	}
+	var buf bytes.Buffer
+	if err := f.Write(&buf, v); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	return buf.String()
+}
+
+func TestParse(t *testing.T) {
+	for _, spec := range []string{"", "table", "json", "yaml", "go-template={{.id}}"} {
+		if _, err := output.Parse(spec); err != nil {
+			t.Errorf("Parse(%q): %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"xml", "go-template", "go-template={{.id"} {
+		if _, err := output.Parse(spec); err == nil {
+			t.Errorf("Parse(%q) should fail", spec)
+		}
+	}
+
+	f, _ := output.Parse("table")
+	if f.Structured() {
+		t.Error("table should not be structured")
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	out := render(t, "json", sample)
+
+	var got []labView
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	if len(got) != 2 || got[0].Worktree != "/labs/myapp" {
+		t.Errorf("round trip = %+v", got)
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	out := render(t, "yaml", sample)
+
+	want := `- id: abc
+  worktree: /labs/myapp
+  volumes:
+    - claudeup-lab-config-abc
+  running: true
+  cpus: 1.5
+- id: def
+  worktree: "/labs/other: x"
+  volumes: []
+  running: false
+`
+	if out != want {
+		t.Errorf("yaml =\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestYAMLQuotesAmbiguousStrings(t *testing.T) {
+	out := render(t, "yaml", map[string]string{"a": "yes", "b": "1.0", "c": "", "d": "plain text"})
+	for _, want := range []string{`a: "yes"`, `b: "1.0"`, `c: ""`, `d: plain text`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("yaml missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteTemplateUsesJSONNames(t *testing.T) {
+	out := render(t, `go-template={{range .}}{{.worktree}}{{"\n"}}{{end}}`, sample)
+	if out != "/labs/myapp\n/labs/other: x\n" {
+		t.Errorf("template output = %q", out)
+	}
+
+	f, _ := output.Parse("go-template={{.missing}}")
+	if err := f.Write(&bytes.Buffer{}, sample[0]); err == nil {
+		t.Error("unknown template field should be an error")
+	}
+}
@@ -0,0 +1,153 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// node is a JSON value decoded with its object keys kept in order.
+type node struct {
+	kind   byte // '{', '[', or 0 for a scalar
+	keys   []string
+	values []*node
+	scalar interface{}
+}
+
+// EncodeYAML writes v as YAML. The document mirrors v's JSON encoding,
+// including field names and order, so YAML and JSON output share one
+// schema.
+func EncodeYAML(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode output: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	n, err := decodeNode(dec)
+	if err != nil {
+		return fmt.Errorf("encode output: %w", err)
+	}
+
+	var b strings.Builder
+	writeYAML(&b, n, 0)
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func decodeNode(dec *json.Decoder) (*node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		n := &node{kind: '{'}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, key.(string))
+			n.values = append(n.values, value)
+		}
+		_, err := dec.Token() // closing brace
+		return n, err
+	case json.Delim('['):
+		n := &node{kind: '['}
+		for dec.More() {
+			value, err := decodeNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.values = append(n.values, value)
+		}
+		_, err := dec.Token() // closing bracket
+		return n, err
+	}
+	return &node{scalar: tok}, nil
+}
+
+// writeYAML writes n as the value of a block at the given indent. Scalars
+// and empty collections are written inline by the caller.
+func writeYAML(b *strings.Builder, n *node, indent int) {
+	pad := strings.Repeat("  ", indent)
+
+	switch {
+	case n.kind == 0 || len(n.values) == 0:
+		b.WriteString(inline(n) + "\n")
+	case n.kind == '{':
+		for i, key := range n.keys {
+			b.WriteString(pad + yamlString(key) + ":")
+			writeChild(b, n.values[i], indent+1)
+		}
+	case n.kind == '[':
+		for _, value := range n.values {
+			b.WriteString(pad + "-")
+			if value.kind == '{' && len(value.values) > 0 {
+				// The first key shares the dash's line
+				var item strings.Builder
+				writeYAML(&item, value, indent+1)
+				b.WriteString(" " + strings.TrimPrefix(item.String(), pad+"  "))
+				continue
+			}
+			writeChild(b, value, indent+1)
+		}
+	}
+}
+
+func writeChild(b *strings.Builder, n *node, indent int) {
+	if n.kind == 0 || len(n.values) == 0 {
+		b.WriteString(" " + inline(n) + "\n")
+		return
+	}
+	b.WriteString("\n")
+	writeYAML(b, n, indent)
+}
+
+func inline(n *node) string {
+	switch n.kind {
+	case '{':
+		return "{}"
+	case '[':
+		return "[]"
+	}
+
+	switch v := n.scalar.(type) {
+	case nil:
+		return "null"
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case json.Number:
+		return v.String()
+	case string:
+		return yamlString(v)
+	}
+	return fmt.Sprint(n.scalar)
+}
+
+var (
+	plainString = regexp.MustCompile(`^[A-Za-z0-9_./@+-][A-Za-z0-9_./@+ :-]*$`)
+	ambiguous   = regexp.MustCompile(`^(?i:true|false|yes|no|on|off|y|n|null|~)$|^[-+]?[0-9.]|:$|: | $`)
+)
+
+// yamlString quotes s unless it is unambiguous as a plain scalar. JSON
+// string syntax is valid YAML double-quoted syntax.
+func yamlString(s string) string {
+	if plainString.MatchString(s) && !ambiguous.MatchString(s) {
+		return s
+	}
+	data, _ := json.Marshal(s)
+	return string(data)
+}
@@ -0,0 +1,187 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// callLog is the command history shared by Recorder and Fake.
+type callLog struct {
+	mu    sync.Mutex
+	calls []Cmd
+}
+
+func (l *callLog) record(cmd *Cmd) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, Cmd{Name: cmd.Name, Args: append([]string(nil), cmd.Args...), Dir: cmd.Dir, Env: append([]string(nil), cmd.Env...)})
+}
+
+// Calls returns the recorded commands in order, without their streams.
+func (l *callLog) Calls() []Cmd {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Cmd(nil), l.calls...)
+}
+
+// Commands returns the recorded command lines in order.
+func (l *callLog) Commands() []string {
+	var lines []string
+	for _, c := range l.Calls() {
+		lines = append(lines, c.String())
+	}
+	return lines
+}
+
+// Ran reports whether a command starting with prefix was recorded, where
+// prefix is a command line such as "docker stop".
+func (l *callLog) Ran(prefix string) bool {
+	for _, line := range l.Commands() {
+		if line == prefix || strings.HasPrefix(line, prefix+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// Recorder runs commands with another Runner and remembers each one.
+type Recorder struct {
+	callLog
+	runner Runner
+}
+
+var _ Runner = (*Recorder)(nil)
+
+// NewRecorder records commands run with r.
+func NewRecorder(r Runner) *Recorder {
+	return &Recorder{runner: r}
+}
+
+func (r *Recorder) Run(cmd *Cmd) error {
+	r.record(cmd)
+	return r.runner.Run(cmd)
+}
+
+func (r *Recorder) LookPath(name string) (string, error) {
+	return r.runner.LookPath(name)
+}
+
+// Fake answers commands from scripted rules instead of running them.
+// Commands no rule matches go to Fallback, or fail when it is nil. Every
+// command is recorded, matched or not.
+type Fake struct {
+	callLog
+	Fallback Runner
+
+	rulesMu sync.Mutex
+	rules   []*Rule
+}
+
+var _ Runner = (*Fake)(nil)
+
+// NewFake returns a Fake with no rules and no fallback.
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+// Rule is a scripted response to commands whose arguments start with a
+// given prefix.
+type Rule struct {
+	name   string
+	prefix []string
+	stdout string
+	err    error
+	fn     func(cmd *Cmd) error
+}
+
+// On adds a rule for commands named name whose arguments start with
+// argsPrefix. Later rules take precedence, so a test can override a
+// default set up earlier. The rule succeeds silently until configured.
+func (f *Fake) On(name string, argsPrefix ...string) *Rule {
+	rule := &Rule{name: name, prefix: argsPrefix}
+	f.rulesMu.Lock()
+	f.rules = append(f.rules, rule)
+	f.rulesMu.Unlock()
+	return rule
+}
+
+// Return makes the rule write stdout and return err.
+func (r *Rule) Return(stdout string, err error) *Rule {
+	r.stdout, r.err = stdout, err
+	return r
+}
+
+// Fail makes the rule exit with the given code and error output.
+func (r *Rule) Fail(code int, stderr string) *Rule {
+	return r.Return("", &ExitError{Code: code, Stderr: []byte(stderr)})
+}
+
+// Do makes the rule call fn, for responses with side effects or that
+// depend on the arguments. It replaces Return.
+func (r *Rule) Do(fn func(cmd *Cmd) error) *Rule {
+	r.fn = fn
+	return r
+}
+
+func (r *Rule) matches(cmd *Cmd) bool {
+	if cmd.Name != r.name || len(cmd.Args) < len(r.prefix) {
+		return false
+	}
+	for i, arg := range r.prefix {
+		if cmd.Args[i] != arg {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *Fake) match(cmd *Cmd) *Rule {
+	f.rulesMu.Lock()
+	defer f.rulesMu.Unlock()
+	for i := len(f.rules) - 1; i >= 0; i-- {
+		if f.rules[i].matches(cmd) {
+			return f.rules[i]
+		}
+	}
+	return nil
+}
+
+func (f *Fake) Run(cmd *Cmd) error {
+	f.record(cmd)
+
+	rule := f.match(cmd)
+	if rule == nil {
+		if f.Fallback != nil {
+			return f.Fallback.Run(cmd)
+		}
+		return fmt.Errorf("fake runner: unexpected command: %s", cmd)
+	}
+
+	if rule.fn != nil {
+		return rule.fn(cmd)
+	}
+	if cmd.Stdout != nil && rule.stdout != "" {
+		io.WriteString(cmd.Stdout, rule.stdout)
+	}
+	return rule.err
+}
+
+// LookPath finds any command the fake has rules for, and otherwise asks
+// Fallback.
+func (f *Fake) LookPath(name string) (string, error) {
+	f.rulesMu.Lock()
+	for _, r := range f.rules {
+		if r.name == name {
+			f.rulesMu.Unlock()
+			return "/fake/bin/" + name, nil
+		}
+	}
+	f.rulesMu.Unlock()
+
+	if f.Fallback != nil {
+		return f.Fallback.LookPath(name)
+	}
+	return "", fmt.Errorf("fake runner: %s not found", name)
+}
@@ -0,0 +1,115 @@
+// Package runner runs the external commands claudeup-lab depends on (git,
+// docker/podman, claudeup, the devcontainer CLI). Everything that shells out
+// takes a Runner, so tests can substitute a Recorder or a Fake and exercise
+// whole lab lifecycles without those tools installed.
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Cmd describes one command invocation. Nil streams are discarded (or, for
+// Stdin, empty).
+type Cmd struct {
+	Name   string
+	Args   []string
+	Dir    string
+	Env    []string // KEY=VALUE pairs added to the inherited environment
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// String returns the command line, for logs and test assertions.
+func (c *Cmd) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// Runner runs commands.
+type Runner interface {
+	// Run runs the command to completion. A command that starts but exits
+	// non-zero returns an *ExitError.
+	Run(cmd *Cmd) error
+
+	// LookPath reports where an executable would be found on PATH.
+	LookPath(name string) (string, error)
+}
+
+// Default runs real processes.
+var Default Runner = Exec{}
+
+// ExitError reports a command that ran but exited non-zero. Stderr holds
+// the command's error output when the caller did not capture it itself.
+type ExitError struct {
+	Code   int
+	Stderr []byte
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Exec runs commands as real processes with os/exec.
+type Exec struct{}
+
+func (Exec) Run(cmd *Cmd) error {
+	c := exec.Command(cmd.Name, cmd.Args...)
+	c.Dir = cmd.Dir
+	if len(cmd.Env) > 0 {
+		c.Env = append(os.Environ(), cmd.Env...)
+	}
+	c.Stdin = cmd.Stdin
+	c.Stdout = cmd.Stdout
+	c.Stderr = cmd.Stderr
+
+	var stderr bytes.Buffer
+	if c.Stderr == nil {
+		c.Stderr = &stderr
+	}
+
+	err := c.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Code: exitErr.ExitCode(), Stderr: stderr.Bytes()}
+	}
+	return err
+}
+
+func (Exec) LookPath(name string) (string, error) {
+	return exec.LookPath(name)
+}
+
+// Run runs a command, discarding its output.
+func Run(r Runner, name string, args ...string) error {
+	return r.Run(&Cmd{Name: name, Args: args})
+}
+
+// Output runs a command and returns its standard output.
+func Output(r Runner, name string, args ...string) ([]byte, error) {
+	var stdout bytes.Buffer
+	err := r.Run(&Cmd{Name: name, Args: args, Stdout: &stdout})
+	return stdout.Bytes(), err
+}
+
+// CombinedOutput runs a command and returns its standard output and
+// standard error interleaved.
+func CombinedOutput(r Runner, name string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	err := r.Run(&Cmd{Name: name, Args: args, Stdout: &out, Stderr: &out})
+	return out.Bytes(), err
+}
+
+// Stderr returns the captured error output of a failed command, if any.
+func Stderr(err error) string {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return strings.TrimSpace(string(exitErr.Stderr))
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package runner_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/claudeup/claudeup-lab/internal/runner"
+)
+
+func TestExecExitErrorCapturesStderr(t *testing.T) {
+	err := runner.Run(runner.Default, "sh", "-c", "echo boom >&2; exit 3")
+
+	var exitErr *runner.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("err = %v, want ExitError with code 3", err)
+	}
+	if runner.Stderr(err) != "boom" {
+		t.Errorf("Stderr = %q, want %q", runner.Stderr(err), "boom")
+	}
+}
+
+func TestOutput(t *testing.T) {
+	out, err := runner.Output(runner.Default, "echo", "hello")
+	if err != nil || strings.TrimSpace(string(out)) != "hello" {
+		t.Errorf("Output = %q, %v", out, err)
+	}
+}
+
+func TestFakeLaterRulesWin(t *testing.T) {
+	fake := runner.NewFake()
+	fake.On("docker").Fail(1, "generic failure")
+	fake.On("docker", "ps").Return("abc\n", nil)
+
+	out, err := runner.Output(fake, "docker", "ps", "-a")
+	if err != nil || string(out) != "abc\n" {
+		t.Errorf("docker ps = %q, %v; want scripted output", out, err)
+	}
+	if err := runner.Run(fake, "docker", "stop", "abc"); runner.Stderr(err) != "generic failure" {
+		t.Errorf("docker stop = %v, want generic failure", err)
+	}
+}
+
+func TestFakeUnmatchedCommand(t *testing.T) {
+	fake := runner.NewFake()
+	if err := runner.Run(fake, "git", "status"); err == nil {
+		t.Error("unmatched command without fallback should fail")
+	}
+	if _, err := fake.LookPath("git"); err == nil {
+		t.Error("LookPath should fail for a command with no rules")
+	}
+
+	fake.Fallback = runner.NewFake()
+	fake.Fallback.(*runner.Fake).On("git")
+	if err := runner.Run(fake, "git", "status"); err != nil {
+		t.Errorf("unmatched command should go to fallback: %v", err)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	fake := runner.NewFake()
+	fake.On("git")
+	rec := runner.NewRecorder(fake)
+
+	runner.Run(rec, "git", "-C", "/repo", "fetch")
+	runner.Run(rec, "git", "worktree", "prune")
+
+	want := []string{"git -C /repo fetch", "git worktree prune"}
+	if got := rec.Commands(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Commands = %v, want %v", got, want)
+	}
+	if !rec.Ran("git worktree") || rec.Ran("git work") {
+		t.Error("Ran should match whole-word command prefixes")
+	}
+}
+
+func TestExecEnv(t *testing.T) {
+	t.Setenv("RUNNER_TEST_INHERITED", "kept")
+	out, err := runner.Output(runner.Default, "sh", "-c", `echo "$RUNNER_TEST_INHERITED $RUNNER_TEST_ADDED"`)
+	if err != nil || strings.TrimSpace(string(out)) != "kept" {
+		t.Fatalf("Output = %q, %v", out, err)
+	}
+
+	var stdout strings.Builder
+	err = runner.Default.Run(&runner.Cmd{
+		Name:   "sh",
+		Args:   []string{"-c", `echo "$RUNNER_TEST_INHERITED $RUNNER_TEST_ADDED"`},
+		Env:    []string{"RUNNER_TEST_ADDED=extra"},
+		Stdout: &stdout,
+	})
+	if err != nil || strings.TrimSpace(stdout.String()) != "kept extra" {
+		t.Errorf("with Env = %q, %v", stdout.String(), err)
+	}
+}